@@ -0,0 +1,104 @@
+package billing
+
+// AllocationOrder determines how a payment is split between principal and interest
+type AllocationOrder int
+
+// Allocation orders
+const (
+	// InterestFirst applies payments to outstanding interest before principal
+	InterestFirst AllocationOrder = iota
+
+	// PrincipalFirst applies payments to outstanding principal before interest
+	PrincipalFirst
+)
+
+// PaymentAllocation is the split of a payment between principal and interest
+type PaymentAllocation struct {
+	Principal float64
+	Interest  float64
+}
+
+// AllocatePayment returns how a payment of the given amount would be split
+// between principal and interest under the loan's allocation order, given the
+// payments made so far. It does not record the payment.
+func (l *Loan) AllocatePayment(amount float64) PaymentAllocation {
+	principalPaid, interestPaid := l.allocatedTotals()
+	return l.allocate(amount, l.principal-principalPaid, l.totalInterest()-interestPaid)
+}
+
+// PrincipalCollected returns the principal repaid by the payments recorded so
+// far, split from each payment under the loan's allocation order as it was made
+func (l *Loan) PrincipalCollected() float64 {
+	return l.principalCollected
+}
+
+// InterestCollected returns the interest collected by the payments recorded so
+// far. Together with PrincipalCollected it adds up to the total paid.
+func (l *Loan) InterestCollected() float64 {
+	return l.interestCollected
+}
+
+// recordPayment adds a payment to the history and splits it between the
+// principal and interest ledgers. Any amount carried from earlier partial
+// payments is settled with it.
+func (l *Loan) recordPayment(payment Payment) {
+	payment.Carried = l.partialPaid
+	l.partialPaid = 0
+
+	allocation := l.allocate(payment.Amount, l.principal-l.principalCollected, l.totalInterest()-l.interestCollected)
+	l.principalCollected += allocation.Principal
+	l.interestCollected += allocation.Interest
+	l.payments = append(l.payments, payment)
+}
+
+// totalInterest returns the total interest charged over the billing schedule
+func (l *Loan) totalInterest() float64 {
+	total := 0.0
+	for _, installment := range l.billingSchedule() {
+		total += installment
+	}
+	return total - l.principal
+}
+
+// allocatedTotals replays the payment history and returns the total principal
+// and interest paid so far under the loan's allocation order
+func (l *Loan) allocatedTotals() (principalPaid, interestPaid float64) {
+	totalInterest := l.totalInterest()
+	for _, payment := range l.payments {
+		allocation := l.allocate(payment.Amount, l.principal-principalPaid, totalInterest-interestPaid)
+		principalPaid += allocation.Principal
+		interestPaid += allocation.Interest
+	}
+	return principalPaid, interestPaid
+}
+
+// allocate splits amount between the remaining principal and interest in the
+// loan's allocation order. Any amount beyond both is allocated to principal.
+func (l *Loan) allocate(amount, principalRemaining, interestRemaining float64) PaymentAllocation {
+	if principalRemaining < 0 {
+		principalRemaining = 0
+	}
+	if interestRemaining < 0 {
+		interestRemaining = 0
+	}
+
+	var allocation PaymentAllocation
+	if l.allocationOrder == PrincipalFirst {
+		allocation.Principal = minFloat(amount, principalRemaining)
+		allocation.Interest = minFloat(amount-allocation.Principal, interestRemaining)
+	} else {
+		allocation.Interest = minFloat(amount, interestRemaining)
+		allocation.Principal = minFloat(amount-allocation.Interest, principalRemaining)
+	}
+	allocation.Principal += amount - allocation.Principal - allocation.Interest
+
+	return allocation
+}
+
+// minFloat returns the smaller of a and b
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
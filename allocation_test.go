@@ -0,0 +1,88 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_AllocatePayment(t *testing.T) {
+	tests := []struct {
+		name             string
+		order            AllocationOrder
+		previousPayments int
+		expected         PaymentAllocation
+	}{
+		{"Interest first", InterestFirst, 0, PaymentAllocation{Principal: 0, Interest: 22000}},
+		{"Principal first", PrincipalFirst, 0, PaymentAllocation{Principal: 22000, Interest: 0}},
+		{"Interest first when interest is nearly paid", InterestFirst, 4, PaymentAllocation{Principal: 10000, Interest: 12000}},
+		{"Interest first after interest is paid", InterestFirst, 5, PaymentAllocation{Principal: 22000, Interest: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan(WithLoanConfig(Config{
+				Principal:       1000000,
+				InterestRate:    0.10,
+				TotalWeeks:      50,
+				AllocationOrder: tt.order,
+			}))
+			for i := 0; i < tt.previousPayments; i++ {
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+			}
+
+			allocation := loan.AllocatePayment(22000)
+
+			assert.InDelta(t, tt.expected.Principal, allocation.Principal, 0.01)
+			assert.InDelta(t, tt.expected.Interest, allocation.Interest, 0.01)
+		})
+	}
+}
+
+func TestLoan_AllocatePayment_Overpayment(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	allocation := loan.AllocatePayment(1200000)
+
+	assert.InDelta(t, 100000, allocation.Interest, 0.01)
+	assert.InDelta(t, 1100000, allocation.Principal, 0.01, "Excess should be allocated to principal")
+}
+
+func TestLoan_CollectedLedgers(t *testing.T) {
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 10}
+
+	tests := []struct {
+		name              string
+		order             AllocationOrder
+		expectedPrincipal float64
+		expectedInterest  float64
+	}{
+		{"Interest first", InterestFirst, 230000, 100000},
+		{"Principal first", PrincipalFirst, 330000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config
+			cfg.AllocationOrder = tt.order
+			loan := NewLoan(WithLoanConfig(cfg))
+
+			totalPaid := 0.0
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, loan.MakePayment(110000))
+				totalPaid += 110000
+				assert.InDelta(t, totalPaid, loan.PrincipalCollected()+loan.InterestCollected(), 1e-6)
+			}
+
+			assert.InDelta(t, tt.expectedPrincipal, loan.PrincipalCollected(), 1e-6)
+			assert.InDelta(t, tt.expectedInterest, loan.InterestCollected(), 1e-6)
+
+			assert.NoError(t, loan.ReverseLastPayment())
+			assert.InDelta(t, 220000, loan.PrincipalCollected()+loan.InterestCollected(), 1e-6, "Reversal should come off the ledgers")
+		})
+	}
+}
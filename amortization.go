@@ -0,0 +1,165 @@
+package billing
+
+import "math"
+
+// WeeksPerYear is the number of weekly periods in a year used to derive the
+// periodic rate of declining-balance loans
+const WeeksPerYear = 52
+
+// InterestMethod determines how interest is charged over the term of a loan
+type InterestMethod int
+
+// Interest methods
+const (
+	// FlatInterest charges the annual rate once on the original principal,
+	// spread evenly over the term
+	FlatInterest InterestMethod = iota
+
+	// DecliningBalance charges the weekly rate (annual rate / WeeksPerYear) on
+	// the remaining principal each week, with a constant installment
+	DecliningBalance
+)
+
+// AmortizationEntry is the breakdown of a single installment
+type AmortizationEntry struct {
+	Week      int
+	Payment   float64
+	Interest  float64
+	Principal float64
+	Balance   float64 // principal remaining after the installment
+}
+
+// AmortizationSchedule returns the split of each scheduled installment between
+// interest and principal. Each period's interest is rounded to the loan's minor
+// unit and the principal takes the rest; the final period repays the remaining
+// principal so the balance closes at exactly zero.
+func (l *Loan) AmortizationSchedule() []AmortizationEntry {
+	entries := make([]AmortizationEntry, l.totalWeeks)
+	balance := l.principal
+	weeklyRate := l.interestRate / WeeksPerYear
+
+	for week := range entries {
+		var interest float64
+		if l.interestMethod == DecliningBalance {
+			interest = l.roundToMinorUnit(balance * weeklyRate)
+		} else {
+			interest = l.roundToMinorUnit(l.installmentForWeek(week) - l.principal/float64(l.totalWeeks))
+		}
+
+		payment := l.installmentForWeek(week)
+		principal := payment - interest
+		if week == l.totalWeeks-1 {
+			principal = balance
+			if l.interestMethod == DecliningBalance {
+				payment = principal + interest
+			} else {
+				interest = payment - principal
+			}
+		}
+		balance -= principal
+
+		entries[week] = AmortizationEntry{
+			Week:      week,
+			Payment:   payment,
+			Interest:  interest,
+			Principal: principal,
+			Balance:   balance,
+		}
+	}
+
+	if len(entries) > 0 {
+		entries[len(entries)-1].Balance = 0
+	}
+
+	return entries
+}
+
+// EarnedInterest returns the interest earned by elapsed time: the interest
+// portion of each scheduled installment whose week has fully passed. It is
+// independent of how much has actually been paid.
+func (l *Loan) EarnedInterest() float64 {
+	weeks := l.WeeksElapsed()
+	if weeks >= l.totalWeeks {
+		return l.totalInterest()
+	}
+
+	earned := 0.0
+	for _, entry := range l.AmortizationSchedule()[:weeks] {
+		earned += entry.Interest
+	}
+	return earned
+}
+
+// UnearnedInterest returns the interest not yet earned by elapsed time, which
+// is the amount available to rebate if the loan is repaid early. Earned and
+// unearned interest always add up to the total interest of the schedule.
+func (l *Loan) UnearnedInterest() float64 {
+	return l.totalInterest() - l.EarnedInterest()
+}
+
+// InterestSavingsBiweekly returns how much less interest the rest of the loan
+// would cost on an accelerated biweekly plan than on the weekly schedule. The
+// biweekly plan pays half the monthly equivalent of the weekly installment
+// every two weeks, i.e. one extra month's worth a year, from the next unpaid
+// installment until the loan is repaid. It is only meaningful for
+// declining-balance loans: flat interest is fixed when the loan is originated
+// and cannot be saved by paying faster, so it returns zero for flat loans.
+func (l *Loan) InterestSavingsBiweekly() float64 {
+	if l.interestMethod != DecliningBalance {
+		return 0
+	}
+
+	entries := l.AmortizationSchedule()
+	settled := l.settledInstallments()
+	if settled >= len(entries) {
+		return 0
+	}
+
+	balance := l.principal
+	if settled > 0 {
+		balance = entries[settled-1].Balance
+	}
+
+	weeklyInterest := 0.0
+	for _, entry := range entries[settled:] {
+		weeklyInterest += entry.Interest
+	}
+
+	biweeklyPayment := l.weeklyPayment * WeeksPerYear / 24
+	return weeklyInterest - l.projectInterest(balance, biweeklyPayment, 2)
+}
+
+// projectInterest returns the interest a declining balance would accrue, at
+// the loan's weekly rate and rounded like its schedule, if payment were made
+// every given number of weeks until it is repaid
+func (l *Loan) projectInterest(balance, payment float64, every int) float64 {
+	weeklyRate := l.interestRate / WeeksPerYear
+	total := 0.0
+	for week := 1; balance > paymentTolerance; week++ {
+		interest := l.roundToMinorUnit(balance * weeklyRate)
+		total += interest
+		balance += interest
+		if week%every == 0 {
+			balance -= minFloat(payment, balance)
+		}
+	}
+	return total
+}
+
+// applyDecliningBalance sets the installment, final-installment residual and
+// outstanding debt of a declining-balance loan from its principal, rate and term
+func (l *Loan) applyDecliningBalance() {
+	weeklyRate := l.interestRate / WeeksPerYear
+	installment := l.principal / float64(l.totalWeeks)
+	if weeklyRate > 0 {
+		installment = l.principal * weeklyRate / (1 - math.Pow(1+weeklyRate, -float64(l.totalWeeks)))
+	}
+
+	l.weeklyPayment = l.roundToMinorUnit(installment)
+	l.roundingResidual = 0
+
+	entries := l.AmortizationSchedule()
+	final := entries[len(entries)-1].Payment
+	l.roundingResidual = final - l.weeklyPayment
+	l.outstandingDebt = l.weeklyPayment*float64(l.totalWeeks-1) + final
+}
@@ -0,0 +1,126 @@
+package billing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_AmortizationSchedule_DecliningBalance(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:      1000000,
+		InterestRate:   0.10,
+		TotalWeeks:     50,
+		MinorUnit:      1,
+		InterestMethod: DecliningBalance,
+	}))
+
+	entries := loan.AmortizationSchedule()
+
+	assert.Len(t, entries, 50)
+	assert.Equal(t, 0.0, math.Mod(loan.GetWeeklyPayment(), 1), "Installment should be a whole minor unit")
+
+	totalPaid, totalPrincipal := 0.0, 0.0
+	for i, entry := range entries {
+		assert.Equal(t, 0.0, math.Mod(entry.Interest, 1), "Interest in week %d should be a whole minor unit", i)
+		assert.InDelta(t, entry.Payment, entry.Interest+entry.Principal, 1e-6)
+		if i > 0 {
+			assert.Less(t, entry.Interest, entries[i-1].Interest+1, "Interest should decline with the balance")
+		}
+		if i < len(entries)-1 {
+			assert.Equal(t, loan.GetWeeklyPayment(), entry.Payment)
+		}
+		totalPaid += entry.Payment
+		totalPrincipal += entry.Principal
+	}
+
+	assert.Equal(t, 0.0, entries[49].Balance, "Final balance should be exactly zero")
+	assert.InDelta(t, 1000000, totalPrincipal, 1e-6)
+	assert.InDelta(t, loan.GetOutstanding(), totalPaid, 1e-6, "Outstanding should equal the scheduled payments")
+	assert.InDelta(t, entries[49].Payment, loan.GetBillingSchedule()[49], 1e-6, "Billing schedule should include the final residual")
+	assert.Less(t, loan.GetOutstanding(), 1100000.0, "Declining balance should charge less than flat interest")
+}
+
+func TestLoan_AmortizationSchedule_Flat(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	for _, entry := range loan.AmortizationSchedule() {
+		assert.InDelta(t, 22000, entry.Payment, 1e-6)
+		assert.InDelta(t, 2000, entry.Interest, 1e-6)
+		assert.InDelta(t, 20000, entry.Principal, 1e-6)
+	}
+}
+
+func TestLoan_ChangeInterestRate_DecliningBalance(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:      1000000,
+		InterestRate:   0.10,
+		TotalWeeks:     50,
+		InterestMethod: DecliningBalance,
+	}))
+
+	assert.EqualError(t, loan.ChangeInterestRate(0.20, 10), "interest rate changes are only supported for flat-interest loans")
+}
+
+func TestLoan_EarnedInterest(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	for _, method := range []InterestMethod{FlatInterest, DecliningBalance} {
+		clock := newMockClock()
+		loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+			Principal:      1000000,
+			InterestRate:   0.10,
+			TotalWeeks:     50,
+			InterestMethod: method,
+		}))
+		total := loan.totalInterest()
+
+		assert.Equal(t, 0.0, loan.EarnedInterest(), "Nothing should be earned at the start")
+		assert.InDelta(t, total, loan.UnearnedInterest(), 1e-6)
+
+		elapsed := 0
+		for _, weeks := range []int{1, 10, 25, 49, 50, 60} {
+			clock.Advance(time.Duration(weeks-elapsed) * week)
+			elapsed = weeks
+			assert.InDelta(t, total, loan.EarnedInterest()+loan.UnearnedInterest(), 1e-6,
+				"Earned and unearned interest should add up to the total at week %d", weeks)
+		}
+		assert.Equal(t, 0.0, loan.UnearnedInterest(), "Everything should be earned after the term")
+	}
+
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	clock.Advance(10 * week)
+	assert.InDelta(t, 20000, loan.EarnedInterest(), 1e-6, "Flat interest should be earned evenly")
+	assert.InDelta(t, 80000, loan.UnearnedInterest(), 1e-6)
+}
+
+func TestLoan_InterestSavingsBiweekly(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:      1000000,
+		InterestRate:   0.10,
+		TotalWeeks:     104,
+		MinorUnit:      1,
+		InterestMethod: DecliningBalance,
+	}))
+
+	savings := loan.InterestSavingsBiweekly()
+	assert.Greater(t, savings, 0.0, "Paying an extra month a year should save interest")
+
+	totalInterest := loan.GetOutstanding() - loan.GetPrincipal()
+	assert.Less(t, savings, totalInterest)
+
+	for i := 0; i < 52; i++ {
+		assert.NoError(t, loan.MakePayment(loan.GetWeeklyPayment()))
+	}
+	assert.Less(t, loan.InterestSavingsBiweekly(), savings, "Less remains to be saved once half the loan is repaid")
+
+	flat := NewLoan(WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 104}))
+	assert.Equal(t, 0.0, flat.InterestSavingsBiweekly(), "Flat interest is fixed, so nothing can be saved")
+}
@@ -0,0 +1,89 @@
+package billing
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// anomalousPaymentFactor is how many times the largest scheduled installment a
+// payment must exceed to be flagged as an unusual overpayment
+const anomalousPaymentFactor = 3
+
+// AnomalyKind identifies why a payment was flagged
+type AnomalyKind int
+
+const (
+	// DuplicatePayment is a payment of the same amount as the previous one,
+	// made within the detection window
+	DuplicatePayment AnomalyKind = iota
+	// UnusualOverpayment is a payment far above the scheduled installments
+	UnusualOverpayment
+)
+
+// String returns the string representation of the anomaly kind
+func (k AnomalyKind) String() string {
+	switch k {
+	case DuplicatePayment:
+		return "DuplicatePayment"
+	case UnusualOverpayment:
+		return "UnusualOverpayment"
+	default:
+		return "Unknown"
+	}
+}
+
+// PaymentAnomaly is a payment that looks duplicated or suspicious
+type PaymentAnomaly struct {
+	LoanID  string
+	Index   int // the position of the payment in the loan's payment history
+	Payment Payment
+	Kind    AnomalyKind
+}
+
+// DetectAnomalousPayments scans the payment history for payments repeating the
+// amount of the previous payment within window, and for payments more than
+// three times the largest scheduled installment. A payment may be flagged for
+// both reasons.
+func (l *Loan) DetectAnomalousPayments(window time.Duration) []PaymentAnomaly {
+	largestInstallment := 0.0
+	for _, installment := range l.billingSchedule() {
+		largestInstallment = math.Max(largestInstallment, installment)
+	}
+
+	var anomalies []PaymentAnomaly
+	for i, payment := range l.payments {
+		if i > 0 {
+			previous := l.payments[i-1]
+			if math.Abs(payment.Amount-previous.Amount) <= paymentTolerance && payment.Date.Sub(previous.Date) <= window {
+				anomalies = append(anomalies, PaymentAnomaly{LoanID: l.id, Index: i, Payment: payment, Kind: DuplicatePayment})
+			}
+		}
+		if payment.Amount > anomalousPaymentFactor*largestInstallment+paymentTolerance {
+			anomalies = append(anomalies, PaymentAnomaly{LoanID: l.id, Index: i, Payment: payment, Kind: UnusualOverpayment})
+		}
+	}
+
+	return anomalies
+}
+
+// DetectAnomalies runs DetectAnomalousPayments across every loan, returning the
+// anomalies sorted by loan ID and payment order
+func (e *Engine) DetectAnomalies(window time.Duration) []PaymentAnomaly {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	var anomalies []PaymentAnomaly
+	for _, loan := range e.loans {
+		anomalies = append(anomalies, loan.DetectAnomalousPayments(window)...)
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if anomalies[i].LoanID != anomalies[j].LoanID {
+			return anomalies[i].LoanID < anomalies[j].LoanID
+		}
+		return anomalies[i].Index < anomalies[j].Index
+	})
+
+	return anomalies
+}
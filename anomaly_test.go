@@ -0,0 +1,57 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_DetectAnomalousPayments(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithOverpaymentCredit(), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.MakePayment(22000))
+	clock.Advance(2 * time.Minute)
+	assert.NoError(t, loan.MakePayment(22000))
+
+	anomalies := loan.DetectAnomalousPayments(5 * time.Minute)
+
+	assert.Equal(t, []PaymentAnomaly{
+		{LoanID: loan.GetID(), Index: 1, Payment: loan.GetPayments()[1], Kind: DuplicatePayment},
+	}, anomalies)
+	assert.Empty(t, loan.DetectAnomalousPayments(time.Minute), "Payments further apart than the window are not duplicates")
+
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	assert.NoError(t, loan.MakePayment(100000))
+
+	anomalies = loan.DetectAnomalousPayments(time.Minute)
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, UnusualOverpayment, anomalies[0].Kind)
+	assert.Equal(t, 2, anomalies[0].Index)
+}
+
+func TestEngine_DetectAnomalies(t *testing.T) {
+	clock := newMockClock()
+	engine := NewEngine()
+
+	for _, id := range []string{"loan2", "loan1", "clean"} {
+		_, _ = engine.CreateLoan(WithLoanID(id), WithClock(clock), WithOverpaymentCredit())
+	}
+	for _, id := range []string{"loan1", "loan2"} {
+		assert.NoError(t, engine.MakePayment(id, 110000))
+		assert.NoError(t, engine.MakePayment(id, 110000))
+	}
+	assert.NoError(t, engine.MakePayment("clean", 110000))
+
+	anomalies := engine.DetectAnomalies(time.Hour)
+
+	assert.Len(t, anomalies, 2)
+	assert.Equal(t, "loan1", anomalies[0].LoanID)
+	assert.Equal(t, "loan2", anomalies[1].LoanID)
+	assert.Equal(t, DuplicatePayment, anomalies[1].Kind)
+}
@@ -0,0 +1,169 @@
+package billing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AuditEntryKind identifies what an audit trail entry records
+type AuditEntryKind int
+
+const (
+	// AuditStatusChange is a transition of the loan status
+	AuditStatusChange AuditEntryKind = iota
+	// AuditPayment is a payment applied to the loan
+	AuditPayment
+	// AuditReturnedPayment is a payment that bounced, with its fee
+	AuditReturnedPayment
+	// AuditAdjustment is a manual correction of the outstanding balance
+	AuditAdjustment
+	// AuditDiscount is a promotional reduction of the outstanding debt
+	AuditDiscount
+	// AuditDisbursement is a draw of further principal
+	AuditDisbursement
+	// AuditImpairment is a write-down of the loan's carrying value
+	AuditImpairment
+	// AuditSuspension is the start of a suspension
+	AuditSuspension
+	// AuditReliefPeriod is a relief period declared for the loan
+	AuditReliefPeriod
+	// AuditRescheduledInstallment is an overdue installment moved to the end of the term
+	AuditRescheduledInstallment
+	// AuditCreditApplication is overpayment credit applied to an installment
+	AuditCreditApplication
+	// AuditGuaranteeCall is an amount collected from the guarantor
+	AuditGuaranteeCall
+)
+
+// String returns the string representation of the audit entry kind
+func (k AuditEntryKind) String() string {
+	switch k {
+	case AuditStatusChange:
+		return "StatusChange"
+	case AuditPayment:
+		return "Payment"
+	case AuditReturnedPayment:
+		return "ReturnedPayment"
+	case AuditAdjustment:
+		return "Adjustment"
+	case AuditDiscount:
+		return "Discount"
+	case AuditDisbursement:
+		return "Disbursement"
+	case AuditImpairment:
+		return "Impairment"
+	case AuditSuspension:
+		return "Suspension"
+	case AuditReliefPeriod:
+		return "ReliefPeriod"
+	case AuditRescheduledInstallment:
+		return "RescheduledInstallment"
+	case AuditCreditApplication:
+		return "CreditApplication"
+	case AuditGuaranteeCall:
+		return "GuaranteeCall"
+	default:
+		return "Unknown"
+	}
+}
+
+// AuditEntry is a single dated item of a loan's history
+type AuditEntry struct {
+	Date        time.Time
+	Kind        AuditEntryKind
+	Description string
+	Amount      float64 // the amount involved, or zero when the entry has none
+	Balance     float64 // the outstanding balance after an entry that changed the debt; zero for other entries
+}
+
+// AuditDocument is a render-ready history of a loan: its terms and current
+// position, and every dated change made to it as a single timeline. Late fees
+// and penalties accrue without a date of their own, so they are reported as
+// totals rather than as entries.
+type AuditDocument struct {
+	LoanID      string
+	BorrowerID  string
+	Currency    string
+	Principal   float64
+	StartDate   time.Time
+	Status      LoanStatus
+	Outstanding float64
+	LateFees    float64
+	PenaltyFees float64
+	Entries     []AuditEntry // oldest first; a status change follows the other entries made at the same time
+}
+
+// AuditTrail gathers the status history, the payment ledger, credit
+// applications, returned payments, adjustments, discounts, guarantee calls,
+// disbursements, impairments, suspensions, relief periods and rescheduled
+// installments of the loan into a single document, ordered by date. Each entry
+// that changed the debt carries the balance it left, found by unwinding the
+// dated changes from the current outstanding debt, so fees and penalties
+// accrued since an entry are included in its balance.
+func (l *Loan) AuditTrail() AuditDocument {
+	var entries []AuditEntry
+
+	for _, change := range l.debtChanges() {
+		entry := AuditEntry{Date: change.date, Balance: change.balance}
+		switch change.kind {
+		case paymentChange:
+			entry.Kind, entry.Description, entry.Amount = AuditPayment, "payment", l.payments[change.index].Amount
+		case creditApplicationChange:
+			entry.Kind, entry.Description, entry.Amount = AuditCreditApplication, "overpayment credit applied", l.creditApplications[change.index].Amount
+		case returnedPaymentChange:
+			returned := l.returnedPayments[change.index]
+			entry.Kind, entry.Amount = AuditReturnedPayment, returned.Fee
+			entry.Description = fmt.Sprintf("payment of %.2f returned: %s", returned.Payment.Amount, returned.Reason)
+		case adjustmentChange:
+			adjustment := l.adjustments[change.index]
+			entry.Kind, entry.Description, entry.Amount = AuditAdjustment, adjustment.Reason, adjustment.Amount
+		case discountChange:
+			discount := l.discounts[change.index]
+			entry.Kind, entry.Description, entry.Amount = AuditDiscount, discount.Reason, discount.Amount
+		case guaranteeCallChange:
+			entry.Kind, entry.Description, entry.Amount = AuditGuaranteeCall, "guarantee called", l.guarantor.Calls[change.index].Amount
+		case disbursementChange:
+			entry.Kind, entry.Description, entry.Amount = AuditDisbursement, "disbursement", l.disbursements[change.index].Amount
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, impairment := range l.impairments {
+		entries = append(entries, AuditEntry{Date: impairment.Date, Kind: AuditImpairment, Description: impairment.Reason, Amount: impairment.Amount})
+	}
+	for _, s := range l.suspensions {
+		entries = append(entries, AuditEntry{Date: s.from, Kind: AuditSuspension, Description: s.reason})
+	}
+	for _, p := range l.reliefPeriods {
+		entries = append(entries, AuditEntry{Date: p.from, Kind: AuditReliefPeriod,
+			Description: fmt.Sprintf("relief period until %s", p.to.Format("2006-01-02"))})
+	}
+	for _, d := range l.deferrals {
+		entries = append(entries, AuditEntry{Date: d.date, Kind: AuditRescheduledInstallment,
+			Description: fmt.Sprintf("installment of week %d rescheduled", d.week)})
+	}
+
+	// Status changes come last so that they follow the entries that caused them
+	for _, change := range l.statusHistory {
+		entries = append(entries, AuditEntry{Date: change.Date, Kind: AuditStatusChange,
+			Description: fmt.Sprintf("status changed from %s to %s", change.From, change.To)})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+
+	return AuditDocument{
+		LoanID:      l.id,
+		BorrowerID:  l.borrowerID,
+		Currency:    l.currency,
+		Principal:   l.principal,
+		StartDate:   l.startDate,
+		Status:      l.status,
+		Outstanding: l.outstandingDebt,
+		LateFees:    l.lateFees,
+		PenaltyFees: l.penaltyFees,
+		Entries:     entries,
+	}
+}
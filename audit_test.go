@@ -0,0 +1,78 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_AuditTrail(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	clock := newMockClock()
+	start := clock.Now()
+	loan := NewLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+
+	assert.NoError(t, loan.MakePayment(22000))
+	clock.Advance(24 * time.Hour)
+	assert.NoError(t, loan.Adjust(5000, "goodwill"))
+	clock.Advance(3 * week)
+	loan.RefreshStatus()
+	assert.Equal(t, Delinquent, loan.GetStatus())
+
+	doc := loan.AuditTrail()
+	assert.Equal(t, "loan1", doc.LoanID)
+	assert.Equal(t, Delinquent, doc.Status)
+	assert.Equal(t, 1073000.0, doc.Outstanding)
+	assert.Equal(t, []AuditEntry{
+		{Date: start, Kind: AuditPayment, Description: "payment", Amount: 22000, Balance: 1078000},
+		{Date: start.Add(24 * time.Hour), Kind: AuditAdjustment, Description: "goodwill", Amount: 5000, Balance: 1073000},
+		{Date: start.Add(24*time.Hour + 3*week), Kind: AuditStatusChange, Description: "status changed from Active to Delinquent"},
+	}, doc.Entries)
+	assert.Equal(t, "StatusChange", doc.Entries[2].Kind.String())
+
+	assert.Equal(t, []StatusChange{{From: Active, To: Delinquent, Date: start.Add(24*time.Hour + 3*week)}}, loan.GetStatusHistory())
+}
+
+func TestLoan_AuditTrail_Balances(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	config := Config{Principal: 1000, InterestRate: 0, TotalWeeks: 4}
+
+	balances := func(doc AuditDocument) map[AuditEntryKind]float64 {
+		result := make(map[AuditEntryKind]float64)
+		for _, entry := range doc.Entries {
+			if entry.Kind != AuditStatusChange {
+				result[entry.Kind] = entry.Balance
+			}
+		}
+		return result
+	}
+
+	t.Run("Guarantee call", func(t *testing.T) {
+		loan := NewLoan(WithClock(newMockClock()), WithGuarantor("guarantor", 500), WithLoanConfig(config))
+		assert.NoError(t, loan.MakePayment(250))
+		assert.NoError(t, loan.CallGuarantee(100))
+
+		assert.Equal(t, map[AuditEntryKind]float64{AuditPayment: 750, AuditGuaranteeCall: 650}, balances(loan.AuditTrail()))
+	})
+
+	t.Run("Disbursement", func(t *testing.T) {
+		loan := NewLoan(WithClock(newMockClock()), WithLoanConfig(config))
+		assert.NoError(t, loan.MakePayment(250))
+		assert.NoError(t, loan.Disburse(400))
+
+		assert.Equal(t, map[AuditEntryKind]float64{AuditPayment: 750, AuditDisbursement: 1150}, balances(loan.AuditTrail()))
+	})
+
+	t.Run("Credit application", func(t *testing.T) {
+		clock := newMockClock()
+		loan := NewLoan(WithClock(clock), WithOverpaymentCredit(), WithLoanConfig(config))
+		assert.NoError(t, loan.MakePayment(500))
+		clock.Advance(week)
+		loan.RefreshStatus()
+
+		assert.Equal(t, map[AuditEntryKind]float64{AuditPayment: 750, AuditCreditApplication: 500}, balances(loan.AuditTrail()))
+	})
+}
@@ -0,0 +1,87 @@
+package billing
+
+import (
+	"sort"
+	"time"
+)
+
+// ScheduledPayment is an automatic payment registered for a loan
+type ScheduledPayment struct {
+	LoanID     string
+	Amount     float64
+	MaxRetries int
+
+	failures int
+}
+
+// WithAutoPay registers an automatic payment of the given amount for a loan,
+// retried up to retries times when it fails
+func WithAutoPay(id string, amount float64, retries int) EngineOption {
+	return func(e *Engine) {
+		e.autoPays[id] = &ScheduledPayment{LoanID: id, Amount: amount, MaxRetries: retries}
+	}
+}
+
+// SetAutoPay registers or replaces the automatic payment for a loan,
+// resetting any failed attempts
+func (e *Engine) SetAutoPay(id string, amount float64, retries int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.autoPays[id] = &ScheduledPayment{LoanID: id, Amount: amount, MaxRetries: retries}
+}
+
+// GetAutoPay returns a copy of the automatic payment registered for a loan
+func (e *Engine) GetAutoPay(id string) (ScheduledPayment, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	autoPay, exists := e.autoPays[id]
+	if !exists {
+		return ScheduledPayment{}, false
+	}
+	return *autoPay, true
+}
+
+// ProcessDue attempts the automatic payments of all loans that have an
+// installment due as of now. Each payment is applied as of now as well, rather
+// than by the loan's own clock, so the loans found due are the ones paid and
+// the payments are dated now. A failed attempt is retried on subsequent calls;
+// once an automatic payment has failed more than its retry limit, an
+// EventAutoPayFailed event is emitted and the automatic payment is removed.
+func (e *Engine) ProcessDue(now time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	ids := make([]string, 0, len(e.autoPays))
+	for id := range e.autoPays {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		autoPay := e.autoPays[id]
+
+		loan, exists := e.loans[id]
+		if !exists || loan.GetStatus() == Closed || loan.missedPaymentsAsOf(now) <= 0 {
+			continue
+		}
+
+		clock := loan.clock
+		loan.clock = &replayClock{now: now}
+		err := e.applyPayment(loan, autoPay.Amount)
+		loan.clock = clock
+
+		if err != nil {
+			autoPay.failures++
+			if autoPay.failures > autoPay.MaxRetries {
+				delete(e.autoPays, id)
+				e.emit(Event{Type: EventAutoPayFailed, LoanID: id, Amount: autoPay.Amount, Time: now, Err: err})
+			}
+			continue
+		}
+
+		autoPay.failures = 0
+		e.emit(Event{Type: EventPaymentMade, LoanID: id, Amount: autoPay.Amount, Time: now})
+	}
+}
@@ -0,0 +1,95 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_ProcessDue(t *testing.T) {
+	t.Run("Retry with corrected amount succeeds", func(t *testing.T) {
+		var events []Event
+		engine := NewEngine(
+			WithAutoPay("loan1", 11000, 2),
+			WithEventHandler(func(event Event) { events = append(events, event) }),
+		)
+		clock := newMockClock()
+		_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(Config{
+			Principal:    1000000,
+			InterestRate: 0.10,
+			TotalWeeks:   50,
+		}))
+
+		engine.ProcessDue(clock.Now())
+		outstanding, _ := engine.GetOutstanding("loan1")
+		assert.Equal(t, 1100000.0, outstanding, "Insufficient payment should not be applied")
+
+		autoPay, exists := engine.GetAutoPay("loan1")
+		assert.True(t, exists, "Auto-payment should be kept for a retry")
+		assert.Equal(t, 1, autoPay.failures)
+
+		engine.SetAutoPay("loan1", 22000, 2)
+		engine.ProcessDue(clock.Now())
+		outstanding, _ = engine.GetOutstanding("loan1")
+		assert.Equal(t, 1078000.0, outstanding)
+
+		engine.ProcessDue(clock.Now())
+		outstanding, _ = engine.GetOutstanding("loan1")
+		assert.Equal(t, 1078000.0, outstanding, "No payment should be attempted when nothing is due")
+
+		assert.Len(t, events, 2)
+		assert.Equal(t, EventLoanCreated, events[0].Type)
+		assert.Equal(t, EventPaymentMade, events[1].Type)
+		assert.Equal(t, 22000.0, events[1].Amount)
+	})
+
+	t.Run("Permanent failure after retries", func(t *testing.T) {
+		var failures []Event
+		engine := NewEngine(
+			WithAutoPay("loan1", 11000, 1),
+			WithEventHandler(func(event Event) {
+				if event.Type == EventAutoPayFailed {
+					failures = append(failures, event)
+				}
+			}),
+		)
+		clock := newMockClock()
+		_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(clock))
+
+		engine.ProcessDue(clock.Now())
+		assert.Empty(t, failures, "First failure should be retried")
+
+		clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+		engine.ProcessDue(clock.Now())
+		assert.Len(t, failures, 1)
+		assert.Equal(t, "loan1", failures[0].LoanID)
+		assert.Error(t, failures[0].Err)
+
+		_, exists := engine.GetAutoPay("loan1")
+		assert.False(t, exists, "Permanently failed auto-payment should be removed")
+	})
+
+	t.Run("Due date and payment use the same time", func(t *testing.T) {
+		const week = 7 * 24 * time.Hour
+
+		engine := NewEngine(WithAutoPay("loan1", 22000, 0))
+		clock := newMockClock()
+		loan, _ := engine.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(Config{
+			Principal:    1000000,
+			InterestRate: 0.10,
+			TotalWeeks:   50,
+		}))
+		assert.NoError(t, engine.MakePayment("loan1", 22000))
+
+		now := clock.Now().Add(week)
+		engine.ProcessDue(now)
+		payments := loan.GetPayments()
+		assert.Len(t, payments, 2, "An installment due as of now should be paid even if the loan's clock lags behind")
+		assert.Equal(t, now, payments[1].Date)
+		assert.Equal(t, clock.Now(), loan.clock.Now(), "The loan's clock should be restored")
+
+		engine.ProcessDue(clock.Now())
+		assert.Len(t, loan.GetPayments(), 2, "No payment should be attempted when nothing is due as of now")
+	})
+}
@@ -0,0 +1,49 @@
+package billing
+
+import "errors"
+
+// WithBalloon makes the loan a balloon loan whose final installment is the
+// given amount: the regular installments repay the rest of the total over all
+// but the final week. It must follow WithLoanConfig. An amount that is not
+// positive or not less than the total repayable, or a WithLoanConfig that comes
+// later, is reported by NewLoanValidated.
+func WithBalloon(amount float64) LoanOption {
+	return func(l *Loan) {
+		if amount <= 0 {
+			l.optionErr = errors.New("balloon amount must be positive")
+			return
+		}
+		if amount >= l.outstandingDebt {
+			l.optionErr = errors.New("balloon amount must be less than the total repayable")
+			return
+		}
+		if l.interestMethod != FlatInterest {
+			l.optionErr = errors.New("balloon payments are only supported for flat-interest loans")
+			return
+		}
+		if l.totalWeeks < 2 {
+			l.optionErr = errors.New("a balloon payment requires at least two weeks")
+			return
+		}
+
+		l.balloon = amount
+		l.followConfig("WithBalloon")
+		l.applyBalloon()
+		l.invalidateSchedule()
+	}
+}
+
+// GetBalloonAmount returns the balloon due with the final installment, or zero
+// if the loan has no balloon
+func (l *Loan) GetBalloonAmount() float64 {
+	return l.balloon
+}
+
+// applyBalloon sets the regular installment to repay the total less the balloon
+// over all but the final week, leaving the balloon, and any rounding of the
+// regular installments, to the final installment
+func (l *Loan) applyBalloon() {
+	total := l.outstandingDebt
+	l.weeklyPayment = l.roundToMinorUnit((total - l.balloon) / float64(l.totalWeeks-1))
+	l.roundingResidual = total - l.weeklyPayment*float64(l.totalWeeks)
+}
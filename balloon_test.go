@@ -0,0 +1,81 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_Balloon(t *testing.T) {
+	loan, err := NewLoanValidated(WithLoanConfig(Config{
+		Principal:     1000000,
+		InterestRate:  0.10,
+		TotalWeeks:    50,
+		BalloonAmount: 608000,
+	}))
+	assert.NoError(t, err)
+
+	schedule := loan.GetBillingSchedule()
+	total := 0.0
+	for week, installment := range schedule[:49] {
+		assert.InDelta(t, 10040.8163, installment, 1e-4, "Week %d should be a small regular installment", week)
+		total += installment
+	}
+	total += schedule[49]
+
+	assert.InDelta(t, 608000, schedule[49], 1e-6, "The final installment should be the balloon")
+	assert.InDelta(t, 1100000, total, 1e-6, "The schedule should add up to the total repayable")
+	assert.Equal(t, 608000.0, loan.GetBalloonAmount())
+}
+
+func TestLoan_Balloon_Rounded(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:     1000000,
+		InterestRate:  0.10,
+		TotalWeeks:    50,
+		MinorUnit:     100,
+		BalloonAmount: 608000,
+	}))
+
+	schedule := loan.GetBillingSchedule()
+	assert.Equal(t, 10000.0, schedule[0])
+	assert.InDelta(t, 1100000-49*10000, schedule[49], 1e-6, "The final installment should be the balloon plus the rounding of the regular ones")
+	assert.Equal(t, 1100000.0, loan.GetOutstanding())
+}
+
+func TestWithBalloon(t *testing.T) {
+	loan, err := NewLoanValidated(WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}), WithBalloon(608000))
+	assert.NoError(t, err)
+	assert.InDelta(t, 608000, loan.GetBillingSchedule()[49], 1e-6)
+	assert.InDelta(t, 10040.8163, loan.GetWeeklyPayment(), 1e-4)
+
+	_, _, err = loan.SimulateRateChange(0.12, 10)
+	assert.EqualError(t, err, "interest rate changes are not supported for balloon loans")
+}
+
+func TestLoan_Balloon_Invalid(t *testing.T) {
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+
+	_, err := NewLoanValidated(WithLoanConfig(config), WithBalloon(0))
+	assert.EqualError(t, err, "balloon amount must be positive")
+
+	_, err = NewLoanValidated(WithLoanConfig(config), WithBalloon(1100000))
+	assert.EqualError(t, err, "balloon amount must be less than the total repayable")
+
+	_, err = NewLoanValidated(WithBalloon(500000), WithLoanConfig(config))
+	assert.EqualError(t, err, "WithBalloon must follow WithLoanConfig", "A later configuration should not silently drop the balloon")
+
+	config.BalloonAmount = -1
+	assert.EqualError(t, config.Validate(), "balloon amount must not be negative")
+
+	config.BalloonAmount = 1100000
+	assert.EqualError(t, config.Validate(), "balloon amount must be less than the total repayable")
+
+	config.BalloonAmount = 500000
+	config.TotalWeeks = 1
+	assert.EqualError(t, config.Validate(), "a balloon payment requires at least two weeks")
+
+	config.TotalWeeks = 50
+	config.InterestMethod = DecliningBalance
+	assert.EqualError(t, config.Validate(), "balloon payments are only supported for flat-interest loans")
+}
@@ -0,0 +1,85 @@
+package billing
+
+import "sort"
+
+// BorrowerSummary aggregates the loans held by a single borrower
+type BorrowerSummary struct {
+	BorrowerID       string
+	LoanCount        int
+	TotalOutstanding map[string]float64 // keyed by ISO 4217 currency code
+	WorstStatus      LoanStatus         // the most severe status across the borrower's loans
+	WeeklyObligation map[string]float64 // the next installment of every open loan combined, by currency
+}
+
+// statusSeverity ranks statuses from least to most severe for BorrowerSummary
+var statusSeverity = map[LoanStatus]int{
+	Closed:     0,
+	Active:     1,
+	Suspended:  2,
+	PastDue:    3,
+	Delinquent: 4,
+}
+
+// WithBorrowerID sets the ID of the borrower who holds the loan
+func WithBorrowerID(id string) LoanOption {
+	return func(l *Loan) {
+		l.borrowerID = id
+	}
+}
+
+// GetBorrowerID returns the ID of the borrower who holds the loan
+func (l *Loan) GetBorrowerID() string {
+	return l.borrowerID
+}
+
+// FindByBorrower returns the loans held by the given borrower, sorted by ID
+func (e *Engine) FindByBorrower(borrowerID string) []*Loan {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	return e.findByBorrower(borrowerID)
+}
+
+// findByBorrower returns the borrower's loans sorted by ID. The caller must hold the lock.
+func (e *Engine) findByBorrower(borrowerID string) []*Loan {
+	var found []*Loan
+	for _, loan := range e.loans {
+		if loan.borrowerID == borrowerID {
+			found = append(found, loan)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].GetID() < found[j].GetID()
+	})
+
+	return found
+}
+
+// BorrowerSummary aggregates the outstanding balance, status and weekly
+// obligation across all loans held by the given borrower, totalling the amounts
+// in each currency. A borrower without loans gets an empty summary with a
+// Closed worst status.
+func (e *Engine) BorrowerSummary(borrowerID string) BorrowerSummary {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	summary := BorrowerSummary{
+		BorrowerID:       borrowerID,
+		TotalOutstanding: make(map[string]float64),
+		WorstStatus:      Closed,
+		WeeklyObligation: make(map[string]float64),
+	}
+	for _, loan := range e.findByBorrower(borrowerID) {
+		summary.LoanCount++
+		summary.TotalOutstanding[loan.currency] += loan.GetOutstanding()
+		if statusSeverity[loan.GetStatus()] > statusSeverity[summary.WorstStatus] {
+			summary.WorstStatus = loan.GetStatus()
+		}
+		if loan.GetStatus() != Closed {
+			summary.WeeklyObligation[loan.currency] += loan.installmentForWeek(loan.settledInstallments())
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,55 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_BorrowerSummary(t *testing.T) {
+	clock := newMockClock()
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("loan2"), WithBorrowerID("borrower1"), WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	delinquent, _ := engine.CreateLoan(WithLoanID("loan1"), WithBorrowerID("borrower1"), WithClock(clock), WithLoanConfig(Config{
+		Principal:    2000000,
+		InterestRate: 0.20,
+		TotalWeeks:   40,
+	}))
+	_, _ = engine.CreateLoan(WithLoanID("loan3"), WithBorrowerID("borrower2"), WithClock(clock))
+	_, _ = engine.CreateLoan(WithLoanID("loan4"), WithBorrowerID("borrower1"), WithClock(clock), WithCurrency("USD"),
+		WithLoanConfig(Config{Principal: 1000, InterestRate: 0.10, TotalWeeks: 10}))
+	delinquent.status = Delinquent
+
+	var ids []string
+	for _, loan := range engine.FindByBorrower("borrower1") {
+		ids = append(ids, loan.GetID())
+	}
+	assert.Equal(t, []string{"loan1", "loan2", "loan4"}, ids)
+
+	summary := engine.BorrowerSummary("borrower1")
+
+	assert.Equal(t, BorrowerSummary{
+		BorrowerID:       "borrower1",
+		LoanCount:        3,
+		TotalOutstanding: map[string]float64{"IDR": 1100000 + 2400000, "USD": 1100},
+		WorstStatus:      Delinquent,
+		WeeklyObligation: map[string]float64{"IDR": 22000 + 60000, "USD": 110},
+	}, summary, "Amounts in different currencies should be totalled apart")
+
+	assert.Equal(t, BorrowerSummary{
+		BorrowerID:       "unknown",
+		TotalOutstanding: map[string]float64{},
+		WorstStatus:      Closed,
+		WeeklyObligation: map[string]float64{},
+	}, engine.BorrowerSummary("unknown"))
+}
+
+func TestLoan_ToStructuredRecord_Counterparty(t *testing.T) {
+	loan := NewLoan(WithBorrowerID("borrower1"))
+
+	assert.Equal(t, "borrower1", loan.ToStructuredRecord().Counterparty.Identification)
+}
@@ -0,0 +1,88 @@
+package billing
+
+import "errors"
+
+// Charge is an external charge, such as an insurance premium, collected
+// alongside the installments but kept apart from the loan balance
+type Charge struct {
+	Name      string
+	Amount    float64
+	PerPeriod bool // collected with every installment rather than once
+	Collected bool // whether a one-off charge has been collected
+}
+
+// AddCharge registers a charge to be collected on top of the installments.
+// A per-period charge is added to every installment a payment settles; a
+// one-off charge is added to the next payment only.
+func (l *Loan) AddCharge(name string, amount float64, perPeriod bool) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if name == "" {
+		return errors.New("charge name must not be empty")
+	}
+	if amount <= 0 {
+		return errors.New("charge amount must be positive")
+	}
+	if l.outstandingDebt <= 0 {
+		return errors.New("loan is already fully paid")
+	}
+
+	l.charges = append(l.charges, Charge{Name: name, Amount: amount, PerPeriod: perPeriod})
+	return nil
+}
+
+// GetCharges returns a copy of the charges slice
+func (l *Loan) GetCharges() []Charge {
+	chargesCopy := make([]Charge, len(l.charges))
+	copy(chargesCopy, l.charges)
+	return chargesCopy
+}
+
+// ChargesCollected returns the total collected for charges, which is not part
+// of the payments applied to the loan balance
+func (l *Loan) ChargesCollected() float64 {
+	return l.chargesCollected
+}
+
+// chargesDue returns the charges owed with a payment settling n installments,
+// at least one
+func (l *Loan) chargesDue(n int) float64 {
+	if n < 1 {
+		n = 1
+	}
+
+	total := 0.0
+	for _, charge := range l.charges {
+		if charge.PerPeriod {
+			total += charge.Amount * float64(n)
+		} else if !charge.Collected {
+			total += charge.Amount
+		}
+	}
+	return total
+}
+
+// collectCharges records the charges collected with a payment and marks the
+// one-off charges as collected. It returns the indexes of the one-off charges
+// it marked, so that they can be uncollected if the payment is reversed.
+func (l *Loan) collectCharges(amount float64) []int {
+	l.chargesCollected += amount
+	var oneOffs []int
+	for i := range l.charges {
+		if !l.charges[i].PerPeriod && !l.charges[i].Collected {
+			l.charges[i].Collected = true
+			oneOffs = append(oneOffs, i)
+		}
+	}
+	return oneOffs
+}
+
+// uncollectCharges undoes the collection of the charges paid with a payment
+func (l *Loan) uncollectCharges(payment Payment) {
+	l.chargesCollected -= payment.Charges
+	for _, i := range payment.oneOffs {
+		l.charges[i].Collected = false
+	}
+}
@@ -0,0 +1,37 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_AddCharge(t *testing.T) {
+	loan := NewLoan(WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    100000,
+		InterestRate: 0.10,
+		TotalWeeks:   2,
+	}))
+
+	assert.EqualError(t, loan.AddCharge("", 1000, true), "charge name must not be empty")
+	assert.EqualError(t, loan.AddCharge("insurance", 0, true), "charge amount must be positive")
+
+	assert.NoError(t, loan.AddCharge("insurance", 1000, true))
+	assert.NoError(t, loan.AddCharge("admin", 500, false))
+
+	assert.EqualError(t, loan.MakePayment(55000), "payment amount must be at least 56500.00 for 1 missed payments",
+		"The installment alone should not be enough")
+	assert.NoError(t, loan.MakePayment(56500))
+
+	assert.Equal(t, 55000.0, loan.GetOutstanding(), "Charges should not reduce the loan balance")
+	assert.Equal(t, 1500.0, loan.ChargesCollected())
+	assert.Equal(t, 55000.0, loan.GetPayments()[0].Amount)
+	assert.True(t, loan.GetCharges()[1].Collected)
+
+	assert.NoError(t, loan.MakePayment(56000), "A one-off charge should only be collected once")
+
+	assert.Equal(t, Closed, loan.GetStatus(), "Charges should not hold up the payoff")
+	assert.Equal(t, 0.0, loan.GetOutstanding())
+	assert.Equal(t, 2500.0, loan.ChargesCollected())
+	assert.EqualError(t, loan.AddCharge("insurance", 1000, true), "loan is already fully paid")
+}
@@ -0,0 +1,64 @@
+package billing
+
+import (
+	"errors"
+	"time"
+)
+
+// WithOverpaymentCredit holds the excess of a payment over the amount due as a
+// credit balance, which settles later installments as they fall due, instead
+// of applying it to the outstanding debt straight away
+func WithOverpaymentCredit() LoanOption {
+	return func(l *Loan) {
+		l.overpaymentCredit = true
+	}
+}
+
+// GetCreditBalance returns the overpayment credit held for future installments
+func (l *Loan) GetCreditBalance() float64 {
+	return l.creditBalance
+}
+
+// ApplyCredit settles every installment that has fallen due and that the
+// credit balance fully covers. Credit is also applied automatically whenever
+// the loan's status is recomputed.
+func (l *Loan) ApplyCredit() error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if l.creditBalance <= 0 {
+		return errors.New("loan has no credit balance")
+	}
+	if l.applyCredit() == 0 {
+		return errors.New("credit does not cover an installment that is due")
+	}
+
+	l.updateStatus()
+	return nil
+}
+
+// applyCredit settles due installments from the credit balance, dating each at
+// the time its installment fell due, and returns how many were settled
+func (l *Loan) applyCredit() int {
+	applied := 0
+	for l.creditBalance > 0 && l.outstandingDebt > 0 && l.missedPayments() > 0 {
+		week := l.settledInstallments()
+		installment := l.installmentForWeek(week)
+		if installment > l.creditBalance+paymentTolerance {
+			break
+		}
+
+		date := l.startDate.Add(time.Duration(week) * DaysPerWeek * HoursPerDay * time.Hour)
+		if now := l.clock.Now(); date.After(now) {
+			date = now
+		}
+
+		l.creditApplications = append(l.creditApplications, Payment{Amount: installment, Date: date})
+		l.creditBalance -= installment
+		l.outstandingDebt -= installment
+		l.clearDust()
+		applied++
+	}
+	return applied
+}
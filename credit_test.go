@@ -0,0 +1,66 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_OverpaymentCredit(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithOverpaymentCredit(), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.MakePayment(44000))
+	assert.Equal(t, 22000.0, loan.GetCreditBalance())
+	assert.Equal(t, 1078000.0, loan.GetOutstanding(), "Excess should be held as credit")
+
+	// Skip week 1's payment entirely
+	clock.Advance(2*week + HoursPerDay*time.Hour)
+	loan.RefreshStatus()
+
+	assert.Equal(t, 0.0, loan.GetCreditBalance(), "Credit should cover the skipped installment")
+	assert.Equal(t, 1056000.0, loan.GetOutstanding())
+	assert.False(t, loan.IsDelinquent())
+	assert.NotEqual(t, Delinquent, loan.GetStatus())
+	assert.Equal(t, 1, loan.missedPayments(), "Only week 2 should be due")
+}
+
+func TestLoan_ApplyCredit(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithOverpaymentCredit(), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.EqualError(t, loan.ApplyCredit(), "loan has no credit balance")
+
+	assert.NoError(t, loan.MakePayment(30000))
+	assert.EqualError(t, loan.ApplyCredit(), "credit does not cover an installment that is due")
+
+	assert.NoError(t, loan.MakePayment(40000))
+	assert.Equal(t, 26000.0, loan.GetCreditBalance())
+
+	clock.Advance(2 * week)
+	assert.NoError(t, loan.ApplyCredit())
+	assert.Equal(t, 4000.0, loan.GetCreditBalance())
+	assert.Equal(t, 1034000.0, loan.GetOutstanding())
+}
+
+func TestLoan_MakePayment_OverpaymentWithoutCredit(t *testing.T) {
+	loan := NewLoan(WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.MakePayment(22000))
+	assert.Error(t, loan.MakePayment(44000), "Overpayments should be rejected unless credit is enabled")
+}
@@ -0,0 +1,40 @@
+package billing
+
+import "time"
+
+// DayCountConvention determines how days are counted when accruing interest
+type DayCountConvention int
+
+// Day count conventions
+const (
+	// Actual365 counts the actual number of days over a 365-day year
+	Actual365 DayCountConvention = iota
+
+	// Thirty360 counts every month as 30 days over a 360-day year
+	Thirty360
+)
+
+// dayCount returns the number of whole days between from and to under the convention
+func (c DayCountConvention) dayCount(from, to time.Time) int {
+	if c == Thirty360 {
+		y1, m1, d1 := from.Date()
+		y2, m2, d2 := to.Date()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		return 360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1)
+	}
+
+	return int(to.Sub(from).Hours() / HoursPerDay)
+}
+
+// daysPerYear returns the length of the year in days under the convention
+func (c DayCountConvention) daysPerYear() float64 {
+	if c == Thirty360 {
+		return 360
+	}
+	return DaysPerYear
+}
@@ -0,0 +1,51 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDayCountConvention_dayCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		convention DayCountConvention
+		from       time.Time
+		to         time.Time
+		expected   int
+	}{
+		{"Actual/365 over February", Actual365, date(2024, time.February, 1), date(2024, time.March, 1), 29},
+		{"30/360 over February", Thirty360, date(2024, time.February, 1), date(2024, time.March, 1), 30},
+		{"30/360 from month end", Thirty360, date(2024, time.January, 31), date(2024, time.March, 31), 60},
+		{"30/360 across years", Thirty360, date(2023, time.December, 15), date(2024, time.January, 15), 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.convention.dayCount(tt.from, tt.to))
+		})
+	}
+}
+
+func TestLoan_AccruedInterestAsOf_DayCount(t *testing.T) {
+	config := Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}
+	start := date(2024, time.January, 1)
+	asOf := date(2024, time.March, 1)
+
+	actual := NewLoan(WithClock(&mockClock{now: start}), WithLoanConfig(config))
+	config.DayCount = Thirty360
+	thirty := NewLoan(WithClock(&mockClock{now: start}), WithLoanConfig(config))
+
+	assert.InDelta(t, 1000000*0.10*60/365, actual.AccruedInterestAsOf(asOf), 0.01)
+	assert.InDelta(t, 1000000*0.10*60/360, thirty.AccruedInterestAsOf(asOf), 0.01)
+	assert.Greater(t, thirty.AccruedInterestAsOf(asOf), actual.AccruedInterestAsOf(asOf), "A 360-day year should accrue more for the same day count")
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,150 @@
+package billing
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Disbursement records a draw of further principal after the loan was created
+type Disbursement struct {
+	Amount    float64
+	Date      time.Time
+	Repayable float64 // the debt the draw added: the amount drawn with its interest
+}
+
+// WithUndrawnPrincipal marks amount of the configured principal as committed
+// but not yet drawn, as on a line of credit. The principal and the schedule
+// cover only the disbursed part; each draw with Disburse is added to them, and
+// CancelUndrawn cancels what is no longer needed. This option must follow
+// WithLoanConfig; an amount that is negative or not less than the principal, a
+// loan that cannot draw later, or a WithLoanConfig that comes later, is
+// reported by NewLoanValidated.
+func WithUndrawnPrincipal(amount float64) LoanOption {
+	return func(l *Loan) {
+		if amount < 0 || amount >= l.principal {
+			l.optionErr = errors.New("undrawn principal must be at least 0 and less than the principal")
+			return
+		}
+		if l.interestMethod != FlatInterest || l.balloon > 0 {
+			l.optionErr = errors.New("undrawn principal is only supported for flat-interest loans without a balloon")
+			return
+		}
+
+		l.undrawn = amount
+		l.followConfig("WithUndrawnPrincipal")
+		l.principal -= amount
+		total := l.principal + l.principal*l.interestRate
+		l.weeklyPayment = l.roundToMinorUnit(total / float64(l.totalWeeks))
+		l.roundingResidual = l.residualFor(total, l.weeklyPayment, l.totalWeeks)
+		l.outstandingDebt = total
+		l.invalidateSchedule()
+	}
+}
+
+// DisbursedPrincipal returns the principal drawn so far
+func (l *Loan) DisbursedPrincipal() float64 {
+	return l.principal
+}
+
+// Disburse draws a further tranche of principal. The tranche comes out of the
+// undrawn commitment first and any part beyond it raises the commitment. It is
+// charged flat interest at the loan's rate and, together with the installments
+// still unpaid, is reamortized over the remaining term from the first unpaid
+// week. Only flat-interest loans that are modifiable can draw.
+func (l *Loan) Disburse(amount float64) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if amount <= 0 {
+		return errors.New("disbursement amount must be positive")
+	}
+
+	outstanding := l.outstandingDebt
+	if err := l.reamortizePrincipalChange(amount); err != nil {
+		return err
+	}
+	l.undrawn = math.Max(l.undrawn-amount, 0)
+
+	l.disbursements = append(l.disbursements, Disbursement{Amount: amount, Date: l.clock.Now(), Repayable: l.outstandingDebt - outstanding})
+	l.updateStatus()
+
+	return nil
+}
+
+// CancelUndrawn cancels amount of the undrawn commitment so it can no longer
+// be drawn. The schedule only covers the disbursed principal, so it does not
+// change.
+func (l *Loan) CancelUndrawn(amount float64) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if amount <= 0 {
+		return errors.New("cancelled amount must be positive")
+	}
+	if amount > l.undrawn+paymentTolerance {
+		return errors.New("principal must not drop below the disbursed principal")
+	}
+
+	l.undrawn -= amount
+
+	return nil
+}
+
+// reamortizePrincipalChange changes the principal by delta, charging or
+// dropping flat interest on it, and spreads the change over the installments
+// from the first unpaid week
+func (l *Loan) reamortizePrincipalChange(delta float64) error {
+	if !l.IsModifiable() {
+		return ErrNotModifiable
+	}
+	if l.interestMethod != FlatInterest {
+		return errors.New("principal changes are only supported for flat-interest loans")
+	}
+	if l.balloon > 0 {
+		return errors.New("principal changes are not supported for balloon loans")
+	}
+
+	effectiveWeek := l.settledInstallments()
+	if effectiveWeek >= l.totalWeeks {
+		return errors.New("loan has no remaining term to reamortize over")
+	}
+	if len(l.deferrals) > 0 && effectiveWeek <= l.deferrals[len(l.deferrals)-1].week {
+		return errors.New("principal change must follow any rescheduled installment")
+	}
+
+	repayable := delta * (1 + l.interestRate)
+	change := rateChange{}
+	for week := effectiveWeek; week < l.totalWeeks; week++ {
+		change.oldRemaining += l.installmentForWeek(week)
+	}
+	change.remainingTotal = change.oldRemaining + repayable
+	if change.remainingTotal < 0 {
+		return errors.New("principal change exceeds the installments still unpaid")
+	}
+	change.installment = l.roundToMinorUnit(change.remainingTotal / float64(l.totalWeeks-effectiveWeek))
+
+	l.reamortize(effectiveWeek, change)
+	l.principal += delta
+	l.outstandingDebt += repayable
+
+	return nil
+}
+
+// GetDisbursements returns a copy of the disbursements slice. The principal the
+// loan was created with is not included.
+func (l *Loan) GetDisbursements() []Disbursement {
+	disbursementsCopy := make([]Disbursement, len(l.disbursements))
+	copy(disbursementsCopy, l.disbursements)
+	return disbursementsCopy
+}
+
+// CurrentSchedule returns the unpaid installments as currently scheduled, over
+// the remaining term. The schedule is recomputed whenever a disbursement raises
+// the principal.
+func (l *Loan) CurrentSchedule() []ScheduleEntry {
+	entries, _ := l.NextNInstallments(l.totalWeeks)
+	return entries
+}
@@ -0,0 +1,85 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_CurrentSchedule_Disbursements(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    400000,
+		InterestRate: 0.10,
+		TotalWeeks:   10,
+	}))
+
+	assert.EqualError(t, loan.Disburse(0), "disbursement amount must be positive")
+
+	schedule := loan.CurrentSchedule()
+	assert.Len(t, schedule, 10)
+	assert.Equal(t, 44000.0, schedule[0].Amount)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, loan.MakePayment(44000))
+		clock.Advance(week)
+	}
+
+	// The second tranche is charged interest and spread over the five weeks left
+	assert.NoError(t, loan.Disburse(500000))
+
+	schedule = loan.CurrentSchedule()
+	assert.Len(t, schedule, 5)
+	assert.Equal(t, 5, schedule[0].Week)
+	assert.Equal(t, 154000.0, schedule[0].Amount, "The installment should increase after the second draw")
+	assert.Equal(t, 900000.0, loan.GetPrincipal())
+	assert.Equal(t, 770000.0, loan.GetOutstanding())
+	assert.Len(t, loan.GetDisbursements(), 1)
+
+	total := 0.0
+	for _, entry := range schedule {
+		total += entry.Amount
+	}
+	assert.InDelta(t, loan.GetOutstanding(), total, 1e-6, "The schedule should repay the outstanding balance")
+}
+
+func TestLoan_CancelUndrawn(t *testing.T) {
+	_, err := NewLoanValidated(WithLoanConfig(Config{Principal: 100000, InterestRate: 0.10, TotalWeeks: 10}),
+		WithUndrawnPrincipal(100000))
+	assert.EqualError(t, err, "undrawn principal must be at least 0 and less than the principal")
+
+	loan := NewLoan(WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   10,
+	}), WithUndrawnPrincipal(600000))
+
+	assert.Equal(t, 400000.0, loan.DisbursedPrincipal())
+	assert.Equal(t, 44000.0, loan.GetWeeklyPayment(), "The schedule should amortize only the disbursed principal")
+	assert.Equal(t, 440000.0, loan.GetOutstanding())
+
+	assert.NoError(t, loan.Disburse(100000))
+	assert.Equal(t, 500000.0, loan.DisbursedPrincipal())
+	assert.Equal(t, 55000.0, loan.CurrentSchedule()[0].Amount, "Drawing the commitment should rebuild the schedule")
+	assert.Equal(t, 550000.0, loan.GetOutstanding())
+	assert.Equal(t, 110000.0, loan.GetDisbursements()[0].Repayable)
+
+	assert.EqualError(t, loan.CancelUndrawn(600000), "principal must not drop below the disbursed principal")
+	assert.NoError(t, loan.CancelUndrawn(500000))
+
+	assert.Equal(t, 500000.0, loan.GetPrincipal())
+	assert.Equal(t, 500000.0, loan.DisbursedPrincipal())
+	assert.Equal(t, 55000.0, loan.CurrentSchedule()[0].Amount, "Cancelling the undrawn commitment should not change the schedule")
+	assert.Equal(t, 550000.0, loan.GetOutstanding())
+
+	_, err = NewLoanValidated(WithLoanConfig(Config{Principal: 100000, InterestRate: 0.10, TotalWeeks: 10, BalloonAmount: 50000}),
+		WithUndrawnPrincipal(50000))
+	assert.EqualError(t, err, "undrawn principal is only supported for flat-interest loans without a balloon")
+
+	_, err = NewLoanValidated(WithUndrawnPrincipal(50000), WithLoanConfig(Config{Principal: 100000, InterestRate: 0.10, TotalWeeks: 10}))
+	assert.EqualError(t, err, "WithUndrawnPrincipal must follow WithLoanConfig")
+
+	assert.EqualError(t, loan.CancelUndrawn(1), "principal must not drop below the disbursed principal")
+}
@@ -0,0 +1,64 @@
+package billing
+
+import (
+	"errors"
+	"time"
+)
+
+// Discount records a promotional reduction of the outstanding debt. Unlike a
+// payment, it does not settle an installment.
+type Discount struct {
+	Amount float64
+	Reason string
+	Date   time.Time
+}
+
+// ApplyDiscount reduces the outstanding debt by amount and records it as a
+// discount. The amount must be positive and must not exceed the outstanding debt.
+func (l *Loan) ApplyDiscount(amount float64, reason string) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if amount <= 0 {
+		return errors.New("discount amount must be positive")
+	}
+	if amount > l.outstandingDebt+paymentTolerance {
+		return errors.New("discount must not exceed the outstanding debt")
+	}
+
+	l.discounts = append(l.discounts, Discount{Amount: amount, Reason: reason, Date: l.clock.Now()})
+	l.outstandingDebt -= amount
+	l.clearDust()
+	l.updateStatus()
+
+	return nil
+}
+
+// GetDiscounts returns a copy of the discounts slice
+func (l *Loan) GetDiscounts() []Discount {
+	discountsCopy := make([]Discount, len(l.discounts))
+	copy(discountsCopy, l.discounts)
+	return discountsCopy
+}
+
+// ApplyDiscount applies a promotional discount to a specific loan
+func (e *Engine) ApplyDiscount(id string, amount float64, reason string) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALDiscount, Amount: amount, Reason: reason}, func(l *Loan) error {
+		return l.ApplyDiscount(amount, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventDiscountApplied, LoanID: id, Amount: amount, Reason: reason, Time: loan.clock.Now()})
+	return nil
+}
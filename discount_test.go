@@ -0,0 +1,61 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_ApplyDiscount(t *testing.T) {
+	clock := newMockClock()
+	engine := NewEngine()
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	assert.NoError(t, engine.MakePayment("loan1", 22000))
+
+	before, _ := loan.PaymentToCloseByWeek(49)
+	assert.NoError(t, engine.ApplyDiscount("loan1", 30000, "ramadan promo"))
+
+	assert.Equal(t, 1048000.0, loan.GetOutstanding())
+	assert.Equal(t, []Discount{{Amount: 30000, Reason: "ramadan promo", Date: clock.Now()}}, loan.GetDiscounts())
+	assert.Len(t, loan.GetPayments(), 1, "A discount should not count as a payment")
+
+	after, err := loan.PaymentToCloseByWeek(49)
+	assert.NoError(t, err)
+	assert.InDelta(t, before-30000, after, 1e-6, "Payoff target should reflect the discount")
+
+	assert.NoError(t, loan.PayOff())
+	assert.Equal(t, 1048000.0, loan.GetPayments()[1].Amount, "Loan should be paid off with less")
+	assert.Equal(t, Closed, loan.GetStatus())
+
+	assert.Error(t, engine.ApplyDiscount("non-existent", 1000, "promo"))
+}
+
+func TestLoan_ApplyDiscount_Invalid(t *testing.T) {
+	loan := NewLoan()
+
+	assert.EqualError(t, loan.ApplyDiscount(0, "promo"), "discount amount must be positive")
+	assert.EqualError(t, loan.ApplyDiscount(loan.GetOutstanding()+1, "promo"), "discount must not exceed the outstanding debt")
+}
+
+func TestEngine_ReplayEvents_Discount(t *testing.T) {
+	var log []Event
+	source := NewEngine(WithEventHandler(func(event Event) {
+		log = append(log, event)
+	}))
+	clock := newMockClock()
+	_, _ = source.CreateLoan(WithLoanID("loan1"), WithClock(clock))
+	clock.Advance(time.Hour)
+	assert.NoError(t, source.ApplyDiscount("loan1", 10000, "promo"))
+
+	replayed := NewEngine()
+	assert.NoError(t, replayed.ReplayEvents(log))
+
+	loan, _ := replayed.GetLoan("loan1")
+	assert.Equal(t, 5490000.0, loan.GetOutstanding())
+	assert.Len(t, loan.GetDiscounts(), 1)
+}
@@ -0,0 +1,87 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DistributionStrategy determines the order in which a lump sum is allocated across loans
+type DistributionStrategy int
+
+// Distribution strategies
+const (
+	// MostDelinquentFirst pays the loans with the most missed installments first
+	MostDelinquentFirst DistributionStrategy = iota
+
+	// HighestRateFirst pays the loans with the highest interest rate first
+	HighestRateFirst
+)
+
+// PaymentResult is the outcome of allocating part of a lump sum to a loan
+type PaymentResult struct {
+	LoanID string
+	Amount float64
+	Err    error
+}
+
+// DistributePayment allocates a lump sum across the given loans in the order
+// defined by strategy. Each loan in turn is paid the amount the borrower
+// currently owes on it, net of any subsidy and including charges due, if the
+// remaining sum covers it; otherwise it receives nothing. It returns one
+// result per loan, in allocation order, and the amount left over. All the loans
+// must be in the same currency as the lump sum is.
+func (e *Engine) DistributePayment(loanIDs []string, amount float64, strategy DistributionStrategy) ([]PaymentResult, float64, error) {
+	if amount <= 0 {
+		return nil, 0, errors.New("payment amount must be positive")
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	loans := make([]*Loan, 0, len(loanIDs))
+	for _, id := range loanIDs {
+		loan, exists := e.loans[id]
+		if !exists {
+			return nil, 0, fmt.Errorf("loan not found: %s", id)
+		}
+		if len(loans) > 0 && loan.currency != loans[0].currency {
+			return nil, 0, fmt.Errorf("loans must share a currency: %s is in %s, not %s", id, loan.currency, loans[0].currency)
+		}
+		loans = append(loans, loan)
+	}
+
+	switch strategy {
+	case MostDelinquentFirst:
+		sort.SliceStable(loans, func(i, j int) bool {
+			return loans[i].missedPayments() > loans[j].missedPayments()
+		})
+	case HighestRateFirst:
+		sort.SliceStable(loans, func(i, j int) bool {
+			return loans[i].GetInterestRate() > loans[j].GetInterestRate()
+		})
+	default:
+		return nil, 0, errors.New("unknown distribution strategy")
+	}
+
+	remaining := amount
+	results := make([]PaymentResult, 0, len(loans))
+	for _, loan := range loans {
+		result := PaymentResult{LoanID: loan.GetID()}
+
+		due := loan.borrowerAmountDue()
+		if loan.GetStatus() != Closed && due <= remaining {
+			if err := e.applyPayment(loan, due); err != nil {
+				result.Err = err
+			} else {
+				result.Amount = due
+				remaining -= due
+				e.emit(Event{Type: EventPaymentMade, LoanID: loan.GetID(), Amount: due, Time: loan.clock.Now()})
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, remaining, nil
+}
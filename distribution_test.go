@@ -0,0 +1,129 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_DistributePayment(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	setupEngine := func() *Engine {
+		clock := newMockClock()
+		engine := NewEngine()
+		_, _ = engine.CreateLoan(WithLoanID("cheap"), WithClock(clock), WithLoanConfig(Config{
+			Principal:    1000000,
+			InterestRate: 0.10,
+			TotalWeeks:   50,
+		}))
+		clock.Advance(-2 * week)
+		_, _ = engine.CreateLoan(WithLoanID("behind"), WithClock(clock), WithLoanConfig(Config{
+			Principal:    1000000,
+			InterestRate: 0.05,
+			TotalWeeks:   50,
+		}))
+		clock.Advance(2 * week)
+		return engine
+	}
+
+	tests := []struct {
+		name             string
+		strategy         DistributionStrategy
+		amount           float64
+		expectedResults  []PaymentResult
+		expectedLeftover float64
+	}{
+		{
+			name:     "Most delinquent first",
+			strategy: MostDelinquentFirst,
+			amount:   70000,
+			expectedResults: []PaymentResult{
+				{LoanID: "behind", Amount: 63000},
+				{LoanID: "cheap", Amount: 0},
+			},
+			expectedLeftover: 7000,
+		},
+		{
+			name:     "Highest rate first",
+			strategy: HighestRateFirst,
+			amount:   70000,
+			expectedResults: []PaymentResult{
+				{LoanID: "cheap", Amount: 22000},
+				{LoanID: "behind", Amount: 0},
+			},
+			expectedLeftover: 48000,
+		},
+		{
+			name:     "Enough for both",
+			strategy: HighestRateFirst,
+			amount:   100000,
+			expectedResults: []PaymentResult{
+				{LoanID: "cheap", Amount: 22000},
+				{LoanID: "behind", Amount: 63000},
+			},
+			expectedLeftover: 15000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := setupEngine()
+
+			results, leftover, err := engine.DistributePayment([]string{"cheap", "behind"}, tt.amount, tt.strategy)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedResults, results)
+			assert.InDelta(t, tt.expectedLeftover, leftover, 0.01)
+		})
+	}
+
+	t.Run("Unknown loan", func(t *testing.T) {
+		engine := setupEngine()
+		_, _, err := engine.DistributePayment([]string{"cheap", "non-existent"}, 100000, HighestRateFirst)
+		assert.EqualError(t, err, "loan not found: non-existent")
+
+		outstanding, _ := engine.GetOutstanding("cheap")
+		assert.Equal(t, 1100000.0, outstanding, "No loan should be paid when an ID is unknown")
+	})
+
+	t.Run("Non-positive amount", func(t *testing.T) {
+		_, _, err := setupEngine().DistributePayment([]string{"cheap"}, 0, HighestRateFirst)
+		assert.EqualError(t, err, "payment amount must be positive")
+	})
+
+	t.Run("Net of subsidy", func(t *testing.T) {
+		engine := NewEngine()
+		_, _ = engine.CreateLoan(WithLoanID("subsidized"), WithClock(newMockClock()), WithSubsidy(2000), WithLoanConfig(Config{
+			Principal:    1000000,
+			InterestRate: 0.10,
+			TotalWeeks:   50,
+		}))
+
+		results, leftover, err := engine.DistributePayment([]string{"subsidized"}, 25000, HighestRateFirst)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []PaymentResult{{LoanID: "subsidized", Amount: 20000}}, results, "The borrower should pay the installment net of the subsidy")
+		assert.InDelta(t, 5000, leftover, 0.01)
+		outstanding, _ := engine.GetOutstanding("subsidized")
+		assert.Equal(t, 1078000.0, outstanding)
+	})
+
+	t.Run("Vetoed by the payment pre-hook", func(t *testing.T) {
+		engine := setupEngine()
+		WithPaymentPreHook(func(*Loan, float64) error {
+			return errors.New("payments are blocked")
+		})(engine)
+
+		results, leftover, err := engine.DistributePayment([]string{"cheap", "behind"}, 100000, HighestRateFirst)
+
+		assert.NoError(t, err)
+		assert.EqualError(t, results[0].Err, "payments are blocked")
+		assert.EqualError(t, results[1].Err, "payments are blocked")
+		assert.Equal(t, 100000.0, leftover)
+		outstanding, _ := engine.GetOutstanding("cheap")
+		assert.Equal(t, 1100000.0, outstanding, "A vetoed payment should not be applied")
+	})
+}
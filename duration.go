@@ -0,0 +1,59 @@
+package billing
+
+import "math"
+
+// RemainingDuration returns the Macaulay duration, in years, of the loan's
+// remaining installments: the average time until each is due, weighted by its
+// present value discounted at the loan's interest rate compounded weekly.
+// Overdue installments count as due now. It returns zero once nothing remains.
+func (l *Loan) RemainingDuration() float64 {
+	if l.outstandingDebt <= 0 {
+		return 0
+	}
+
+	now := l.clock.Now()
+	weeklyRate := l.interestRate / WeeksPerYear
+
+	var weightedTime, presentValue float64
+	for week := l.settledInstallments(); week < l.totalWeeks; week++ {
+		dueDate := l.dueDate(week)
+		weeks := dueDate.Sub(now).Hours() / (DaysPerWeek * HoursPerDay)
+		if weeks < 0 {
+			weeks = 0
+		}
+
+		pv := l.installmentForWeek(week) / math.Pow(1+weeklyRate, weeks)
+		weightedTime += weeks / WeeksPerYear * pv
+		presentValue += pv
+	}
+
+	if presentValue == 0 {
+		return 0
+	}
+	return weightedTime / presentValue
+}
+
+// PortfolioDuration returns the outstanding-weighted average RemainingDuration
+// of the engine's loans in each currency, keyed by ISO 4217 code. Loans with
+// nothing outstanding are left out.
+func (e *Engine) PortfolioDuration() map[string]float64 {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	weighted := make(map[string]float64)
+	outstanding := make(map[string]float64)
+	for _, loan := range e.loans {
+		balance := loan.GetOutstanding()
+		if balance <= 0 {
+			continue
+		}
+		weighted[loan.currency] += balance * loan.RemainingDuration()
+		outstanding[loan.currency] += balance
+	}
+
+	durations := make(map[string]float64, len(outstanding))
+	for currency, total := range outstanding {
+		durations[currency] = weighted[currency] / total
+	}
+	return durations
+}
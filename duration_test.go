@@ -0,0 +1,59 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_RemainingDuration(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	clock := newMockClock()
+	longTerm := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 100}))
+	nearMaturity := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	for i := 0; i < 45; i++ {
+		assert.NoError(t, nearMaturity.MakePayment(nearMaturity.GetWeeklyPayment()))
+		assert.NoError(t, longTerm.MakePayment(longTerm.GetWeeklyPayment()))
+		clock.Advance(week)
+	}
+
+	assert.Greater(t, longTerm.RemainingDuration(), nearMaturity.RemainingDuration(),
+		"A longer-term loan should have a higher duration than a near-maturity one")
+
+	// Five installments due at the end of weeks 46 to 50 are one to five weeks
+	// away; discounting pulls the duration just below the three-week midpoint
+	duration := nearMaturity.RemainingDuration()
+	assert.Less(t, duration, 3.0/WeeksPerYear)
+	assert.InDelta(t, 3.0/WeeksPerYear, duration, 0.001)
+
+	// Without discounting the duration is the plain average of the due times,
+	// the ends of weeks 1 to 52
+	zeroRate := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0, TotalWeeks: 52}))
+	assert.InDelta(t, 26.5/WeeksPerYear, zeroRate.RemainingDuration(), 1e-9)
+}
+
+func TestLoan_RemainingDuration_Closed(t *testing.T) {
+	loan := NewLoan()
+	_ = loan.PayOff()
+
+	assert.Equal(t, 0.0, loan.RemainingDuration())
+}
+
+func TestEngine_PortfolioDuration(t *testing.T) {
+	engine := NewEngine()
+	long, _ := engine.CreateLoan(WithLoanID("long"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 100}))
+	short, _ := engine.CreateLoan(WithLoanID("short"), WithLoanConfig(Config{Principal: 2000000, InterestRate: 0.10, TotalWeeks: 10}))
+	usd, _ := engine.CreateLoan(WithLoanID("usd"), WithCurrency("USD"))
+	closed, _ := engine.CreateLoan(WithLoanID("closed"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 200}))
+	_ = closed.PayOff()
+
+	durations := engine.PortfolioDuration()
+
+	expected := (long.GetOutstanding()*long.RemainingDuration() + short.GetOutstanding()*short.RemainingDuration()) /
+		(long.GetOutstanding() + short.GetOutstanding())
+	assert.InDelta(t, expected, durations["IDR"], 1e-9, "Closed loans should not count")
+	assert.InDelta(t, usd.RemainingDuration(), durations["USD"], 1e-9)
+	assert.Len(t, durations, 2)
+}
@@ -2,25 +2,176 @@ package billing
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Engine manages loans
+// loanLockStripes is the number of locks the engine's loans are striped across
+const loanLockStripes = 64
+
+// Engine manages loans. Its mutex guards the set of loans: operations on a
+// single loan hold it for reading together with the lock of the loan's stripe,
+// so that operations on loans in different stripes proceed concurrently, while
+// operations that add loans or change several at once hold it for writing.
 type Engine struct {
-	loans map[string]*Loan
-	mutex sync.RWMutex
+	loans        map[string]*Loan
+	mutex        sync.RWMutex
+	loanLocks    [loanLockStripes]sync.RWMutex
+	emitMutex    sync.Mutex
+	eventHandler func(Event)
+	autoPays     map[string]*ScheduledPayment
+	subscribers  map[int]chan Event
+	nextSubID    int
+	refs         map[string]string
+
+	// refreshOnLoad recomputes the status of loans loaded by ReplayEvents
+	// against the current time
+	refreshOnLoad bool
+
+	// paymentPreHook may veto a payment before it is applied
+	paymentPreHook func(loan *Loan, amount float64) error
+
+	// wal, if set, records every mutation before it is applied
+	wal WAL
+
+	// readCache and cachedOutstanding serve GetOutstanding without locking;
+	// readCache is only set by WithReadCache when the engine is created
+	readCache         bool
+	cachedOutstanding sync.Map
+}
+
+// EngineOption defines a function type for engine options
+type EngineOption func(*Engine)
+
+// WithEventHandler sets a handler that receives the engine's lifecycle events.
+// The handler is invoked synchronously, one event at a time, while the lock of
+// the loan concerned is held, so it must be lightweight and must not call back
+// into the engine.
+func WithEventHandler(handler func(Event)) EngineOption {
+	return func(e *Engine) {
+		e.eventHandler = handler
+	}
+}
+
+// WithStatusRefreshOnLoad makes ReplayEvents recompute the status of every loan
+// it loads against the current time once the replay is done. Without it a
+// replayed loan keeps the status it had at its last event, so a loan that was
+// Active when saved but has since fallen two weeks behind still loads as Active.
+func WithStatusRefreshOnLoad() EngineOption {
+	return func(e *Engine) {
+		e.refreshOnLoad = true
+	}
+}
+
+// WithPaymentPreHook sets a hook that is called with the loan and amount before
+// every payment the engine applies, including automatic and transactional ones.
+// A non-nil error vetoes the payment, which is then reported to the caller and
+// leaves the loan unchanged. Like the event handler, the hook runs while the
+// loan's lock is held, so it must be lightweight and must not call back into
+// the engine. Payments on different loans may call it concurrently.
+func WithPaymentPreHook(hook func(loan *Loan, amount float64) error) EngineOption {
+	return func(e *Engine) {
+		e.paymentPreHook = hook
+	}
+}
+
+// NewEngine creates a new loan engine with the given options
+func NewEngine(options ...EngineOption) *Engine {
+	engine := &Engine{
+		loans:       make(map[string]*Loan),
+		autoPays:    make(map[string]*ScheduledPayment),
+		subscribers: make(map[int]chan Event),
+		refs:        make(map[string]string),
+	}
+
+	for _, option := range options {
+		option(engine)
+	}
+
+	return engine
+}
+
+// lockLoan locks the loan with the given ID for writing, holding the engine's
+// read lock so that the set of loans cannot change, and returns the function
+// that releases both. The loan need not exist.
+func (e *Engine) lockLoan(id string) (unlock func()) {
+	e.mutex.RLock()
+	lock := e.loanLock(id)
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		e.mutex.RUnlock()
+	}
+}
+
+// rlockLoan locks the loan with the given ID for reading, holding the engine's
+// read lock, and returns the function that releases both
+func (e *Engine) rlockLoan(id string) (unlock func()) {
+	e.mutex.RLock()
+	lock := e.loanLock(id)
+	lock.RLock()
+	return func() {
+		lock.RUnlock()
+		e.mutex.RUnlock()
+	}
 }
 
-// NewEngine creates a new loan engine
-func NewEngine() *Engine {
-	return &Engine{
-		loans: make(map[string]*Loan),
+// rlockAll locks every loan for reading, for operations that read across the
+// portfolio, and returns the function that releases the locks
+func (e *Engine) rlockAll() (unlock func()) {
+	e.mutex.RLock()
+	for i := range e.loanLocks {
+		e.loanLocks[i].RLock()
+	}
+	return func() {
+		for i := range e.loanLocks {
+			e.loanLocks[i].RUnlock()
+		}
+		e.mutex.RUnlock()
 	}
 }
 
-// CreateLoan creates a new loan and stores it in the engine
+// loanLock returns the lock of the stripe the loan with the given ID falls in,
+// chosen by the FNV-1a hash of the ID
+func (e *Engine) loanLock(id string) *sync.RWMutex {
+	hash := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		hash ^= uint32(id[i])
+		hash *= 16777619
+	}
+	return &e.loanLocks[hash%loanLockStripes]
+}
+
+// emit sends an event to the engine's event handler and subscribers. Events
+// are delivered one at a time even when emitted from concurrent operations.
+func (e *Engine) emit(event Event) {
+	e.emitMutex.Lock()
+	defer e.emitMutex.Unlock()
+
+	if e.eventHandler != nil {
+		e.eventHandler(event)
+	}
+
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Drop the event rather than block the engine on a slow subscriber
+		}
+	}
+}
+
+// CreateLoan creates a new loan and stores it in the engine. The loan is
+// validated like NewLoanValidated.
 func (e *Engine) CreateLoan(options ...LoanOption) (*Loan, error) {
-	loan := NewLoan(options...)
+	loan, err := NewLoanValidated(options...)
+	if err != nil {
+		return nil, err
+	}
 
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
@@ -28,11 +179,81 @@ func (e *Engine) CreateLoan(options ...LoanOption) (*Loan, error) {
 	if _, exists := e.loans[loan.GetID()]; exists {
 		return nil, errors.New("loan with this ID already exists")
 	}
+	if err := e.logCreation(loan); err != nil {
+		return nil, err
+	}
 
-	e.loans[loan.GetID()] = loan
+	e.store(loan)
+	e.emit(creationEvent(loan))
 	return loan, nil
 }
 
+// GetOrCreateLoan creates a new loan and stores it in the engine, or returns the
+// existing loan if one with the same ID is already stored. The returned bool
+// reports whether the loan was newly created. When the loan already exists, the
+// supplied options (including any config) are ignored; otherwise the new loan
+// is validated like NewLoanValidated.
+func (e *Engine) GetOrCreateLoan(options ...LoanOption) (*Loan, bool, error) {
+	loan := NewLoan(options...)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if existing, exists := e.loans[loan.GetID()]; exists {
+		return existing, false, nil
+	}
+	if err := loan.validate(); err != nil {
+		return nil, false, err
+	}
+	if err := e.logCreation(loan); err != nil {
+		return nil, false, err
+	}
+
+	e.store(loan)
+	e.emit(creationEvent(loan))
+	return loan, true, nil
+}
+
+// store adds a loan to the engine. The caller must hold the write lock.
+func (e *Engine) store(loan *Loan) {
+	e.loans[loan.GetID()] = loan
+	if e.readCache {
+		e.cachedOutstanding.Store(loan.GetID(), loan.enableOutstandingCache())
+	}
+}
+
+// DeleteLoan removes a loan from the engine, together with its automatic
+// payment and external reference
+func (e *Engine) DeleteLoan(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+	if err := e.appendWAL(WALEntry{Op: WALDeleteLoan, LoanID: id, Time: loan.clock.Now()}); err != nil {
+		return err
+	}
+
+	e.remove(id)
+	e.emit(Event{Type: EventLoanDeleted, LoanID: id, Time: loan.clock.Now()})
+	return nil
+}
+
+// remove deletes a loan and everything the engine keeps for it. The caller
+// must hold the write lock.
+func (e *Engine) remove(id string) {
+	delete(e.loans, id)
+	delete(e.autoPays, id)
+	e.cachedOutstanding.Delete(id)
+	for ref, refID := range e.refs {
+		if refID == id {
+			delete(e.refs, ref)
+		}
+	}
+}
+
 // GetLoan retrieves a loan by its ID
 func (e *Engine) GetLoan(id string) (*Loan, error) {
 	e.mutex.RLock()
@@ -45,10 +266,26 @@ func (e *Engine) GetLoan(id string) (*Loan, error) {
 	return loan, nil
 }
 
+// RefreshAllStatuses recomputes the status of every loan against the current time
+func (e *Engine) RefreshAllStatuses() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, loan := range e.loans {
+		loan.RefreshStatus()
+	}
+}
+
 // GetOutstanding gets the outstanding amount for a specific loan
 func (e *Engine) GetOutstanding(id string) (float64, error) {
-	e.mutex.RLock()
-	defer e.mutex.RUnlock()
+	if e.readCache {
+		if bits, ok := e.cachedOutstanding.Load(id); ok {
+			return math.Float64frombits(atomic.LoadUint64(bits.(*uint64))), nil
+		}
+	}
+
+	unlock := e.rlockLoan(id)
+	defer unlock()
 
 	loan, exists := e.loans[id]
 	if !exists {
@@ -60,8 +297,8 @@ func (e *Engine) GetOutstanding(id string) (float64, error) {
 
 // IsDelinquent checks if a specific loan is delinquent
 func (e *Engine) IsDelinquent(id string) (bool, error) {
-	e.mutex.RLock()
-	defer e.mutex.RUnlock()
+	unlock := e.rlockLoan(id)
+	defer unlock()
 
 	loan, exists := e.loans[id]
 	if !exists {
@@ -73,21 +310,45 @@ func (e *Engine) IsDelinquent(id string) (bool, error) {
 
 // MakePayment makes a payment for a specific loan
 func (e *Engine) MakePayment(id string, amount float64) error {
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
+	unlock := e.lockLoan(id)
+	defer unlock()
 
 	loan, exists := e.loans[id]
 	if !exists {
 		return errors.New("loan not found")
 	}
 
-	return loan.MakePayment(amount)
+	if err := e.applyPayment(loan, amount); err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventPaymentMade, LoanID: id, Amount: amount, Time: loan.clock.Now()})
+	return nil
+}
+
+// applyPayment makes a payment on a loan once the payment pre-hook, if any,
+// accepts it
+func (e *Engine) applyPayment(loan *Loan, amount float64) error {
+	if err := e.prePayment(loan, amount); err != nil {
+		return err
+	}
+	return e.mutate(loan, WALEntry{Op: WALPayment, Amount: amount}, func(l *Loan) error {
+		return l.MakePayment(amount)
+	})
+}
+
+// prePayment runs the payment pre-hook, if any
+func (e *Engine) prePayment(loan *Loan, amount float64) error {
+	if e.paymentPreHook == nil {
+		return nil
+	}
+	return e.paymentPreHook(loan, amount)
 }
 
 // GetBillingSchedule returns the billing schedule for a specific loan
 func (e *Engine) GetBillingSchedule(id string) ([]float64, error) {
-	e.mutex.RLock()
-	defer e.mutex.RUnlock()
+	unlock := e.rlockLoan(id)
+	defer unlock()
 
 	loan, exists := e.loans[id]
 	if !exists {
@@ -99,8 +360,8 @@ func (e *Engine) GetBillingSchedule(id string) ([]float64, error) {
 
 // GetLoanStatus returns the status of a specific loan
 func (e *Engine) GetLoanStatus(id string) (LoanStatus, error) {
-	e.mutex.RLock()
-	defer e.mutex.RUnlock()
+	unlock := e.rlockLoan(id)
+	defer unlock()
 
 	loan, exists := e.loans[id]
 	if !exists {
@@ -109,3 +370,329 @@ func (e *Engine) GetLoanStatus(id string) (LoanStatus, error) {
 
 	return loan.GetStatus(), nil
 }
+
+// ChangeInterestRate changes the interest rate of a specific loan from the given week onward
+func (e *Engine) ChangeInterestRate(id string, rate float64, week int) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALChangeInterestRate, Rate: rate, Week: week}, func(l *Loan) error {
+		return l.ChangeInterestRate(rate, week)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventInterestRateChanged, LoanID: id, Rate: rate, Week: week, Time: loan.clock.Now()})
+	return nil
+}
+
+// Suspend suspends a specific loan
+func (e *Engine) Suspend(id string, reason string) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALSuspend, Reason: reason}, func(l *Loan) error {
+		return l.Suspend(reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventSuspended, LoanID: id, Reason: reason, Time: loan.clock.Now()})
+	return nil
+}
+
+// Resume resumes a specific suspended loan
+func (e *Engine) Resume(id string) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	if err := e.mutate(loan, WALEntry{Op: WALResume}, (*Loan).Resume); err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventResumed, LoanID: id, Time: loan.clock.Now()})
+	return nil
+}
+
+// SetStartDate corrects the start date of a specific loan
+func (e *Engine) SetStartDate(id string, t time.Time) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALSetStartDate, From: t}, func(l *Loan) error {
+		return l.SetStartDate(t)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventStartDateChanged, LoanID: id, From: t, Time: loan.clock.Now()})
+	return nil
+}
+
+// PaymentsWithBalance returns the payments of a specific loan with their running balance
+func (e *Engine) PaymentsWithBalance(id string) ([]PaymentWithBalance, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return nil, errors.New("loan not found")
+	}
+
+	return loan.PaymentsWithBalance(), nil
+}
+
+// FindStale returns the loans, sorted by ID, whose last payment (or start date if
+// no payment was made) is older than noActivityFor. Closed loans are excluded.
+func (e *Engine) FindStale(noActivityFor time.Duration) []*Loan {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	var stale []*Loan
+	for _, loan := range e.loans {
+		if loan.GetStatus() == Closed {
+			continue
+		}
+		if loan.clock.Now().Sub(loan.lastActivity()) > noActivityFor {
+			stale = append(stale, loan)
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].GetID() < stale[j].GetID()
+	})
+
+	return stale
+}
+
+// Filter returns the loans for which pred returns true, sorted by ID. Each loan
+// is copied while the engine is locked and pred is called with the copy once
+// the locks are released, so it sees every loan in a consistent state and may
+// call back into the engine. Changes pred makes to a copy are discarded.
+func (e *Engine) Filter(pred func(*Loan) bool) []*Loan {
+	unlock := e.rlockAll()
+	loans := make([]*Loan, 0, len(e.loans))
+	for _, loan := range e.loans {
+		loans = append(loans, loan)
+	}
+	copies := make(map[*Loan]*Loan, len(loans))
+	for _, loan := range loans {
+		copies[loan] = loan.draft()
+	}
+	unlock()
+
+	sort.Slice(loans, func(i, j int) bool {
+		return loans[i].GetID() < loans[j].GetID()
+	})
+
+	var matched []*Loan
+	for _, loan := range loans {
+		if pred(copies[loan]) {
+			matched = append(matched, loan)
+		}
+	}
+	return matched
+}
+
+// WeeksPaidAhead returns how many installments a specific loan has paid in advance
+func (e *Engine) WeeksPaidAhead(id string) (int, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return 0, errors.New("loan not found")
+	}
+
+	return loan.WeeksPaidAhead(), nil
+}
+
+// IsPaidAhead checks if a specific loan has installments paid in advance
+func (e *Engine) IsPaidAhead(id string) (bool, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return false, errors.New("loan not found")
+	}
+
+	return loan.IsPaidAhead(), nil
+}
+
+// Adjust applies a manual adjustment to the outstanding balance of a specific loan
+func (e *Engine) Adjust(id string, amount float64, reason string) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALAdjust, Amount: amount, Reason: reason}, func(l *Loan) error {
+		return l.Adjust(amount, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventAdjusted, LoanID: id, Amount: amount, Reason: reason, Time: loan.clock.Now()})
+	return nil
+}
+
+// DeclareReliefPeriod declares a relief period for the given loans, during which
+// no delinquency accrues. No loan is changed if any of the IDs is not found or
+// the period cannot be added to any of the loans.
+func (e *Engine) DeclareReliefPeriod(from, to time.Time, loanIDs []string) error {
+	if !to.After(from) {
+		return errors.New("relief period must end after it starts")
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, id := range loanIDs {
+		if _, exists := e.loans[id]; !exists {
+			return fmt.Errorf("loan not found: %s", id)
+		}
+	}
+
+	// The period is added to drafts of every loan and logged as one entry
+	// before any loan is changed
+	drafts := make(map[string]*Loan, len(loanIDs))
+	entries := make([]WALEntry, 0, len(loanIDs))
+	for _, id := range loanIDs {
+		loan := e.loans[id]
+		draft, exists := drafts[id]
+		if !exists {
+			draft = loan.draft()
+			drafts[id] = draft
+		}
+		if err := draft.AddReliefPeriod(from, to); err != nil {
+			return err
+		}
+		entries = append(entries, WALEntry{Op: WALReliefPeriod, LoanID: id, Time: loan.clock.Now(), From: from, To: to})
+	}
+
+	if err := e.appendWALBatch(entries); err != nil {
+		return err
+	}
+	for id, draft := range drafts {
+		e.loans[id].install(draft)
+	}
+	for _, entry := range entries {
+		e.emit(Event{Type: EventReliefPeriodDeclared, LoanID: entry.LoanID, From: from, To: to, Time: entry.Time})
+	}
+
+	return nil
+}
+
+// RemainingTerm returns the remaining term of a specific loan as a duration
+func (e *Engine) RemainingTerm(id string) (time.Duration, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return 0, errors.New("loan not found")
+	}
+
+	return loan.RemainingTerm(), nil
+}
+
+// WeeksElapsed returns the number of whole weeks since a specific loan started
+func (e *Engine) WeeksElapsed(id string) (int, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return 0, errors.New("loan not found")
+	}
+
+	return loan.WeeksElapsed(), nil
+}
+
+// MaturityDate returns the date the final installment of a specific loan falls due
+func (e *Engine) MaturityDate(id string) (time.Time, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return time.Time{}, errors.New("loan not found")
+	}
+
+	return loan.MaturityDate(), nil
+}
+
+// GetProgress returns the repayment progress of a specific loan as a percentage
+func (e *Engine) GetProgress(id string) (float64, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return 0, errors.New("loan not found")
+	}
+
+	return loan.Progress(), nil
+}
+
+// Describe returns a readable statement of a specific loan
+func (e *Engine) Describe(id string) (string, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return "", errors.New("loan not found")
+	}
+
+	return loan.String(), nil
+}
+
+// RescheduleInstallment defers an overdue installment of a specific loan to the end of its term
+func (e *Engine) RescheduleInstallment(id string, week int) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALRescheduleInstallment, Week: week}, func(l *Loan) error {
+		return l.RescheduleInstallment(week)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventInstallmentRescheduled, LoanID: id, Week: week, Time: loan.clock.Now()})
+	return nil
+}
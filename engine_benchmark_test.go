@@ -2,6 +2,7 @@ package billing
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
 )
 
@@ -30,3 +31,55 @@ func BenchmarkEngine_MakePayment(b *testing.B) {
 		_ = engine.MakePayment("loan1", loan.GetWeeklyPayment())
 	}
 }
+
+// BenchmarkEngine_ConcurrentPayments makes payments in parallel, each on a loan
+// distinct from the loans the other goroutines are paying. The engine lock case
+// applies the same payments under the engine's write lock, as every payment did
+// before loans were locked individually.
+func BenchmarkEngine_ConcurrentPayments(b *testing.B) {
+	const loans = 4096
+
+	newEngine := func() *Engine {
+		engine := NewEngine()
+		for i := 0; i < loans; i++ {
+			_, _ = engine.CreateLoan(WithLoanID(fmt.Sprintf("loan%d", i)), WithLoanConfig(Config{
+				Principal:    1000000,
+				InterestRate: 0.10,
+				TotalWeeks:   500,
+			}))
+		}
+		return engine
+	}
+
+	pay := func(b *testing.B, engine *Engine, makePayment func(id string, amount float64)) {
+		installment := NewLoan(WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 500})).GetWeeklyPayment()
+		ids := make([]string, loans)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("loan%d", i)
+		}
+
+		var next int64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				makePayment(ids[atomic.AddInt64(&next, 1)%loans], installment)
+			}
+		})
+	}
+
+	b.Run("per-loan locks", func(b *testing.B) {
+		engine := newEngine()
+		pay(b, engine, func(id string, amount float64) {
+			_ = engine.MakePayment(id, amount)
+		})
+	})
+
+	b.Run("engine lock", func(b *testing.B) {
+		engine := newEngine()
+		pay(b, engine, func(id string, amount float64) {
+			engine.mutex.Lock()
+			_ = engine.applyPayment(engine.loans[id], amount)
+			engine.mutex.Unlock()
+		})
+	})
+}
@@ -1,6 +1,9 @@
 package billing
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,12 +16,32 @@ func TestEngine(t *testing.T) {
 		testFunc func(*testing.T, *Engine)
 	}{
 		{"CreateLoan", testCreateLoan},
+		{"GetOrCreateLoan", testGetOrCreateLoan},
 		{"GetLoan", testGetLoan},
 		{"GetOutstanding", testGetOutstanding},
 		{"IsDelinquent", testIsDelinquent},
 		{"MakePayment", testMakePayment},
 		{"GetBillingSchedule", testGetBillingSchedule},
 		{"GetLoanStatus", testGetLoanStatus},
+		{"ChangeInterestRate", testChangeInterestRate},
+		{"SuspendResume", testSuspendResume},
+		{"SetStartDate", testSetStartDate},
+		{"PaymentsWithBalance", testPaymentsWithBalance},
+		{"FindStale", testFindStale},
+		{"PaidAhead", testPaidAhead},
+		{"Adjust", testAdjust},
+		{"DeclareReliefPeriod", testDeclareReliefPeriod},
+		{"RemainingTerm", testRemainingTerm},
+		{"GetProgress", testGetProgress},
+		{"Describe", testDescribe},
+		{"RescheduleInstallment", testRescheduleInstallment},
+		{"Filter", testFilter},
+		{"FilterDuringPayments", testFilterDuringPayments},
+		{"PaymentPreHook", testPaymentPreHook},
+		{"MaturityDate", testMaturityDate},
+		{"WeeksElapsed", testWeeksElapsed},
+		{"ConcurrentOperations", testConcurrentOperations},
+		{"DeleteLoan", testDeleteLoan},
 	}
 
 	for _, tt := range tests {
@@ -53,6 +76,27 @@ func testCreateLoan(t *testing.T, engine *Engine) {
 	}
 }
 
+func testGetOrCreateLoan(t *testing.T, engine *Engine) {
+	first, created, err := engine.GetOrCreateLoan(WithLoanID("loan1"), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "loan1", first.GetID())
+
+	second, created, err := engine.GetOrCreateLoan(WithLoanID("loan1"), WithLoanConfig(Config{
+		Principal:    2000000,
+		InterestRate: 0.20,
+		TotalWeeks:   25,
+	}))
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1000000.0, second.GetPrincipal(), "Config on duplicate should be ignored")
+}
+
 func testGetLoan(t *testing.T, engine *Engine) {
 	_, _ = engine.CreateLoan(WithLoanID("loan1"))
 
@@ -241,3 +285,410 @@ func testGetLoanStatus(t *testing.T, engine *Engine) {
 		})
 	}
 }
+
+func testChangeInterestRate(t *testing.T, engine *Engine) {
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	tests := []struct {
+		name        string
+		loanID      string
+		rate        float64
+		week        int
+		expectError bool
+	}{
+		{"Change rate for existing loan", "loan1", 0.20, 10, false},
+		{"Change rate with invalid week", "loan1", 0.20, 50, true},
+		{"Change rate for non-existent loan", "non-existent", 0.20, 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.ChangeInterestRate(tt.loanID, tt.rate, tt.week)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func testSuspendResume(t *testing.T, engine *Engine) {
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+
+	assert.NoError(t, engine.Suspend("loan1", "borrower dispute"))
+	status, _ := engine.GetLoanStatus("loan1")
+	assert.Equal(t, Suspended, status)
+
+	assert.NoError(t, engine.Resume("loan1"))
+	status, _ = engine.GetLoanStatus("loan1")
+	assert.Equal(t, Active, status)
+
+	assert.Error(t, engine.Suspend("non-existent", "borrower dispute"))
+	assert.Error(t, engine.Resume("non-existent"))
+}
+
+func testSetStartDate(t *testing.T, engine *Engine) {
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+
+	tests := []struct {
+		name        string
+		loanID      string
+		startDate   time.Time
+		expectError bool
+	}{
+		{"Set start date for existing loan", "loan1", time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour), false},
+		{"Set future start date", "loan1", time.Now().Add(DaysPerWeek * HoursPerDay * time.Hour), true},
+		{"Set start date for non-existent loan", "non-existent", time.Now(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.SetStartDate(tt.loanID, tt.startDate)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				status, _ := engine.GetLoanStatus(tt.loanID)
+				assert.Equal(t, Delinquent, status)
+			}
+		})
+	}
+}
+
+func testPaymentsWithBalance(t *testing.T, engine *Engine) {
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+	_ = engine.MakePayment("loan1", loan.GetWeeklyPayment())
+
+	tests := []struct {
+		name        string
+		loanID      string
+		expectError bool
+		expectedLen int
+	}{
+		{"Get payments for existing loan", "loan1", false, 1},
+		{"Get payments for non-existent loan", "non-existent", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := engine.PaymentsWithBalance(tt.loanID)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, entries, tt.expectedLen)
+				assert.Equal(t, loan.GetOutstanding(), entries[0].BalanceAfter)
+			}
+		})
+	}
+}
+
+func testFindStale(t *testing.T, engine *Engine) {
+	clock := newMockClock()
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	idle, _ := engine.CreateLoan(WithLoanID("idle"), WithClock(clock))
+	paidAhead, _ := engine.CreateLoan(WithLoanID("paid-ahead"), WithClock(clock))
+	_ = paidAhead.MakePayment(paidAhead.GetWeeklyPayment())
+	_ = paidAhead.MakePayment(paidAhead.GetWeeklyPayment())
+	closed, _ := engine.CreateLoan(WithLoanID("closed"), WithClock(clock))
+	closed.status = Closed
+
+	clock.Advance(5 * week)
+	active, _ := engine.CreateLoan(WithLoanID("active"), WithClock(clock))
+	_ = active.MakePayment(active.GetWeeklyPayment())
+
+	stale := engine.FindStale(4 * week)
+
+	assert.Equal(t, []*Loan{idle, paidAhead}, stale)
+	assert.Empty(t, engine.FindStale(6*week))
+}
+
+func testPaidAhead(t *testing.T, engine *Engine) {
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+	_ = engine.MakePayment("loan1", loan.GetWeeklyPayment())
+	_ = engine.MakePayment("loan1", loan.GetWeeklyPayment())
+
+	weeks, err := engine.WeeksPaidAhead("loan1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, weeks)
+
+	ahead, err := engine.IsPaidAhead("loan1")
+	assert.NoError(t, err)
+	assert.True(t, ahead)
+
+	_, err = engine.WeeksPaidAhead("non-existent")
+	assert.Error(t, err)
+	_, err = engine.IsPaidAhead("non-existent")
+	assert.Error(t, err)
+}
+
+func testAdjust(t *testing.T, engine *Engine) {
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+
+	tests := []struct {
+		name        string
+		loanID      string
+		amount      float64
+		expectError bool
+	}{
+		{"Adjust existing loan", "loan1", 50000, false},
+		{"Adjust with zero amount", "loan1", 0, true},
+		{"Adjust non-existent loan", "non-existent", 50000, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.Adjust(tt.loanID, tt.amount, "goodwill")
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	outstanding, _ := engine.GetOutstanding("loan1")
+	assert.Equal(t, 5450000.0, outstanding)
+}
+
+func testDeclareReliefPeriod(t *testing.T, engine *Engine) {
+	clock := newMockClock()
+	start := clock.Now()
+	_, _ = engine.CreateLoan(WithLoanID("affected"), WithClock(clock))
+	_, _ = engine.CreateLoan(WithLoanID("unaffected"), WithClock(clock))
+	clock.Advance(3 * DaysPerWeek * HoursPerDay * time.Hour)
+
+	err := engine.DeclareReliefPeriod(start, clock.Now(), []string{"affected", "non-existent"})
+	assert.Error(t, err)
+	isDelinquent, _ := engine.IsDelinquent("affected")
+	assert.True(t, isDelinquent, "No loan should change when an ID is unknown")
+
+	assert.NoError(t, engine.DeclareReliefPeriod(start, clock.Now(), []string{"affected"}))
+
+	isDelinquent, _ = engine.IsDelinquent("affected")
+	assert.False(t, isDelinquent)
+	isDelinquent, _ = engine.IsDelinquent("unaffected")
+	assert.True(t, isDelinquent)
+}
+
+func testRemainingTerm(t *testing.T, engine *Engine) {
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+	_ = engine.MakePayment("loan1", loan.GetWeeklyPayment())
+
+	term, err := engine.RemainingTerm("loan1")
+	assert.NoError(t, err)
+	assert.Equal(t, 49*DaysPerWeek*HoursPerDay*time.Hour, term)
+
+	_, err = engine.RemainingTerm("non-existent")
+	assert.Error(t, err)
+}
+
+func testGetProgress(t *testing.T, engine *Engine) {
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+	_ = engine.MakePayment("loan1", loan.GetWeeklyPayment())
+
+	progress, err := engine.GetProgress("loan1")
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.0, progress, 1e-9)
+
+	_, err = engine.GetProgress("non-existent")
+	assert.Error(t, err)
+}
+
+func testDescribe(t *testing.T, engine *Engine) {
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+
+	output, err := engine.Describe("loan1")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Loan loan1")
+	assert.Contains(t, output, "Status:         Active")
+
+	_, err = engine.Describe("non-existent")
+	assert.Error(t, err)
+}
+
+func testRescheduleInstallment(t *testing.T, engine *Engine) {
+	clock := newMockClock()
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(clock))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+
+	assert.NoError(t, engine.RescheduleInstallment("loan1", 0))
+	loan, _ := engine.GetLoan("loan1")
+	assert.Equal(t, 51, loan.GetTotalWeeks())
+
+	assert.Error(t, engine.RescheduleInstallment("non-existent", 0))
+}
+
+func testFilter(t *testing.T, engine *Engine) {
+	for _, id := range []string{"loan3", "loan1", "loan2", "loan4"} {
+		_, _ = engine.CreateLoan(WithLoanID(id))
+	}
+	loan1, _ := engine.GetLoan("loan1")
+	loan1.status = Delinquent
+	loan2, _ := engine.GetLoan("loan2")
+	_ = loan2.Adjust(5000000, "settlement")
+	loan2.status = Delinquent
+	loan3, _ := engine.GetLoan("loan3")
+	loan3.status = Delinquent
+
+	loans := engine.Filter(func(loan *Loan) bool {
+		return loan.GetStatus() == Delinquent && loan.GetOutstanding() > 1000000
+	})
+
+	var ids []string
+	for _, loan := range loans {
+		ids = append(ids, loan.GetID())
+	}
+	assert.Equal(t, []string{"loan1", "loan3"}, ids)
+
+	assert.Empty(t, engine.Filter(func(*Loan) bool { return false }))
+
+	settled := engine.Filter(func(loan *Loan) bool {
+		delinquent, err := engine.IsDelinquent(loan.GetID())
+		return err == nil && !delinquent && loan.GetOutstanding() <= 1000000
+	})
+	assert.Len(t, settled, 1, "The predicate should be able to call back into the engine")
+}
+
+// testFilterDuringPayments runs Filter while payments are made, so that the race
+// detector catches a predicate reading loans that are not locked
+func testFilterDuringPayments(t *testing.T, _ *Engine) {
+	engine := NewEngine()
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+	installment := loan.GetWeeklyPayment()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, engine.MakePayment("loan1", installment))
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		engine.Filter(func(loan *Loan) bool {
+			return len(loan.payments) > 0 && loan.outstandingDebt > 0
+		})
+	}
+	<-done
+
+	assert.Len(t, engine.Filter(func(loan *Loan) bool { return len(loan.payments) == 10 }), 1)
+}
+
+func testPaymentPreHook(t *testing.T, engine *Engine) {
+	var hooked []float64
+	WithPaymentPreHook(func(loan *Loan, amount float64) error {
+		hooked = append(hooked, amount)
+		if loan.GetID() == "blocked" {
+			return errors.New("payments are blocked on holidays")
+		}
+		return nil
+	})(engine)
+
+	_, _ = engine.CreateLoan(WithLoanID("blocked"))
+	_, _ = engine.CreateLoan(WithLoanID("allowed"))
+
+	err := engine.MakePayment("blocked", 110000)
+
+	assert.EqualError(t, err, "payments are blocked on holidays")
+	outstanding, _ := engine.GetOutstanding("blocked")
+	assert.Equal(t, 5500000.0, outstanding, "A vetoed payment should leave the balance untouched")
+	loan, _ := engine.GetLoan("blocked")
+	assert.Empty(t, loan.GetPayments())
+
+	assert.NoError(t, engine.MakePayment("allowed", 110000))
+	outstanding, _ = engine.GetOutstanding("allowed")
+	assert.Equal(t, 5390000.0, outstanding)
+	assert.Equal(t, []float64{110000, 110000}, hooked)
+}
+
+func testMaturityDate(t *testing.T, engine *Engine) {
+	clock := newMockClock()
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(clock))
+
+	maturity, err := engine.MaturityDate("loan1")
+	assert.NoError(t, err)
+	assert.Equal(t, clock.Now().AddDate(0, 0, 350), maturity)
+
+	_, err = engine.MaturityDate("non-existent")
+	assert.Error(t, err)
+}
+
+func testWeeksElapsed(t *testing.T, engine *Engine) {
+	clock := newMockClock()
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(clock))
+	clock.Advance(2 * DaysPerWeek * HoursPerDay * time.Hour)
+
+	weeks, err := engine.WeeksElapsed("loan1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, weeks)
+
+	_, err = engine.WeeksElapsed("non-existent")
+	assert.Error(t, err)
+}
+
+func testConcurrentOperations(t *testing.T, _ *Engine) {
+	const loans = 16
+	const payments = 10
+
+	var events int
+	engine := NewEngine(WithEventHandler(func(Event) { events++ }))
+
+	var installment, total float64
+	for i := 0; i < loans; i++ {
+		loan, _ := engine.CreateLoan(WithLoanID(fmt.Sprintf("concurrent%d", i)))
+		installment, total = loan.GetWeeklyPayment(), loan.GetOutstanding()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < loans; i++ {
+		wg.Add(2)
+		go func(id string) {
+			defer wg.Done()
+			for j := 0; j < payments; j++ {
+				assert.NoError(t, engine.MakePayment(id, installment))
+				_, _ = engine.GetLoanStatus(id)
+			}
+		}(fmt.Sprintf("concurrent%d", i))
+		go func(i int) {
+			defer wg.Done()
+			_ = engine.Stats()
+			_, _ = engine.CreateLoan(WithLoanID(fmt.Sprintf("concurrent-new%d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < loans; i++ {
+		outstanding, err := engine.GetOutstanding(fmt.Sprintf("concurrent%d", i))
+		assert.NoError(t, err)
+		assert.InDelta(t, total-payments*installment, outstanding, 1e-6)
+
+		_, err = engine.GetLoan(fmt.Sprintf("concurrent-new%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, loans+loans+loans*payments, events, "Every event should reach the handler")
+}
+
+func testDeleteLoan(t *testing.T, engine *Engine) {
+	loan, _ := engine.CreateLoan(WithLoanID("deleted"))
+	_ = loan.SetMetadata(ExternalRefKey, "ref-deleted")
+	assert.NoError(t, engine.RebuildRefIndex())
+	engine.SetAutoPay("deleted", 110000, 0)
+
+	assert.NoError(t, engine.DeleteLoan("deleted"))
+
+	_, err := engine.GetLoan("deleted")
+	assert.Error(t, err)
+	_, err = engine.GetLoanByRef("ref-deleted")
+	assert.Error(t, err)
+	_, exists := engine.GetAutoPay("deleted")
+	assert.False(t, exists)
+
+	assert.EqualError(t, engine.DeleteLoan("deleted"), "loan not found")
+}
@@ -0,0 +1,90 @@
+package billing
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of lifecycle event emitted by the engine
+type EventType int
+
+// Event types
+const (
+	EventLoanCreated EventType = iota
+	EventPaymentMade
+	EventAutoPayFailed
+	EventAdjusted
+	EventSuspended
+	EventResumed
+	EventDiscountApplied
+	EventPaymentReturned
+	EventPaymentReversed
+	EventInterestRateChanged
+	EventStartDateChanged
+	EventInstallmentRescheduled
+	EventReliefPeriodDeclared
+	EventLoanDeleted
+)
+
+// Event describes a change in a loan's lifecycle
+type Event struct {
+	Type   EventType
+	LoanID string
+	Amount float64
+	Time   time.Time
+	Err    error
+	Reason string    // set for adjustment, discount, suspension and returned payment events
+	Config *Config   // set for loan creation events
+	Index  int       // the index of the payment returned, set for returned payment events
+	Rate   float64   // set for interest rate change events
+	Week   int       // set for interest rate change and rescheduled installment events
+	From   time.Time // set for relief period events, and to the new start date of start date change events
+	To     time.Time // set for relief period events
+
+	// Creation records the loan's configuration and options as the write-ahead
+	// log does, so that ReplayEvents can recreate the loan exactly. It is set for
+	// loan creation events, unless the loan has a custom PaymentPolicy.
+	Creation *WALEntry
+}
+
+// creationEvent returns the event announcing the creation of a loan
+func creationEvent(loan *Loan) Event {
+	config := loan.configuration()
+	event := Event{Type: EventLoanCreated, LoanID: loan.GetID(), Time: loan.GetStartDate(), Config: &config}
+	if entry, err := creationEntry(loan); err == nil {
+		event.Creation = &entry
+	}
+	return event
+}
+
+// SubscriberBufferSize is the number of events buffered for each subscriber.
+// Events sent to a subscriber whose buffer is full are dropped.
+const SubscriberBufferSize = 64
+
+// Subscribe returns a channel receiving the engine's lifecycle events and a
+// function that unsubscribes and closes the channel. The engine never blocks on
+// a slow subscriber: once SubscriberBufferSize events are pending, further
+// events are dropped for that subscriber until it catches up.
+func (e *Engine) Subscribe() (<-chan Event, func()) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	id := e.nextSubID
+	e.nextSubID++
+
+	ch := make(chan Event, SubscriberBufferSize)
+	e.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			e.mutex.Lock()
+			defer e.mutex.Unlock()
+
+			delete(e.subscribers, id)
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
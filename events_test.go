@@ -0,0 +1,39 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Subscribe(t *testing.T) {
+	engine := NewEngine()
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+
+	events, unsubscribe := engine.Subscribe()
+
+	assert.NoError(t, engine.MakePayment("loan1", loan.GetWeeklyPayment()))
+	event := <-events
+	assert.Equal(t, EventPaymentMade, event.Type)
+	assert.Equal(t, "loan1", event.LoanID)
+	assert.Equal(t, loan.GetWeeklyPayment(), event.Amount)
+
+	unsubscribe()
+	unsubscribe()
+
+	assert.NoError(t, engine.MakePayment("loan1", loan.GetWeeklyPayment()))
+	_, open := <-events
+	assert.False(t, open, "Channel should be closed with no further events")
+}
+
+func TestEngine_Subscribe_DropsWhenFull(t *testing.T) {
+	engine := NewEngine()
+	events, unsubscribe := engine.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < SubscriberBufferSize+10; i++ {
+		_, _ = engine.CreateLoan()
+	}
+
+	assert.Len(t, events, SubscriberBufferSize, "Events beyond the buffer should be dropped")
+}
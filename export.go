@@ -0,0 +1,46 @@
+package billing
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// paymentRecord is the JSON representation of a payment in the payment ledger export
+type paymentRecord struct {
+	LoanID string    `json:"loan_id"`
+	Index  int       `json:"index"`
+	Amount float64   `json:"amount"`
+	Date   time.Time `json:"date"`
+}
+
+// ExportPaymentsJSONL writes every payment of every loan to w as one JSON object
+// per line, ordered by loan ID and then by payment date
+func (e *Engine) ExportPaymentsJSONL(w io.Writer) error {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	ids := make([]string, 0, len(e.loans))
+	for id := range e.loans {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	encoder := json.NewEncoder(w)
+	for _, id := range ids {
+		payments := e.loans[id].GetPayments()
+		sort.SliceStable(payments, func(i, j int) bool {
+			return payments[i].Date.Before(payments[j].Date)
+		})
+
+		for i, payment := range payments {
+			record := paymentRecord{LoanID: id, Index: i, Amount: payment.Amount, Date: payment.Date}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
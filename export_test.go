@@ -0,0 +1,42 @@
+package billing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_ExportPaymentsJSONL(t *testing.T) {
+	clock := newMockClock()
+	engine := NewEngine()
+	for _, id := range []string{"loan2", "loan1"} {
+		loan, _ := engine.CreateLoan(WithLoanID(id), WithClock(clock))
+		_ = engine.MakePayment(id, loan.GetWeeklyPayment())
+	}
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	_ = engine.MakePayment("loan1", 110000)
+
+	var buf bytes.Buffer
+	assert.NoError(t, engine.ExportPaymentsJSONL(&buf))
+
+	var records []paymentRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record paymentRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "Each line should be valid JSON")
+		records = append(records, record)
+	}
+
+	assert.Len(t, records, 3, "There should be one line per payment")
+	assert.Equal(t, "loan1", records[0].LoanID)
+	assert.Equal(t, 0, records[0].Index)
+	assert.Equal(t, "loan1", records[1].LoanID)
+	assert.Equal(t, 1, records[1].Index)
+	assert.True(t, records[0].Date.Before(records[1].Date))
+	assert.Equal(t, "loan2", records[2].LoanID)
+	assert.Equal(t, 110000.0, records[2].Amount)
+}
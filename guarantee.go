@@ -0,0 +1,80 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Guarantor is a third party who has co-signed a loan up to a guaranteed amount
+type Guarantor struct {
+	Name   string
+	Amount float64 // the most the guarantor can be called on for
+	Calls  []GuaranteeCall
+}
+
+// GuaranteeCall records an amount collected from the guarantor
+type GuaranteeCall struct {
+	Amount float64
+	Date   time.Time
+}
+
+// WithGuarantor records a guarantor who guarantees the loan up to amount
+func WithGuarantor(name string, amount float64) LoanOption {
+	return func(l *Loan) {
+		l.guarantor = &Guarantor{Name: name, Amount: amount}
+	}
+}
+
+// GetGuarantor returns a copy of the loan's guarantor, or nil if it has none
+func (l *Loan) GetGuarantor() *Guarantor {
+	if l.guarantor == nil {
+		return nil
+	}
+
+	guarantor := *l.guarantor
+	guarantor.Calls = make([]GuaranteeCall, len(l.guarantor.Calls))
+	copy(guarantor.Calls, l.guarantor.Calls)
+	return &guarantor
+}
+
+// GuaranteeRemaining returns how much of the guarantee has not been called yet
+func (l *Loan) GuaranteeRemaining() float64 {
+	if l.guarantor == nil {
+		return 0
+	}
+
+	remaining := l.guarantor.Amount
+	for _, call := range l.guarantor.Calls {
+		remaining -= call.Amount
+	}
+	return remaining
+}
+
+// CallGuarantee collects amount from the guarantor, reducing the outstanding
+// debt. The amount must not exceed the remaining guarantee or the outstanding debt.
+func (l *Loan) CallGuarantee(amount float64) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if l.guarantor == nil {
+		return errors.New("loan has no guarantor")
+	}
+	if amount <= 0 {
+		return errors.New("guarantee call amount must be positive")
+	}
+	if remaining := l.GuaranteeRemaining(); amount > remaining+paymentTolerance {
+		return fmt.Errorf("guarantee call must not exceed the remaining guarantee of %.2f", remaining)
+	}
+	if amount > l.outstandingDebt+paymentTolerance {
+		return errors.New("guarantee call must not exceed the outstanding debt")
+	}
+
+	l.guarantor.Calls = append(l.guarantor.Calls, GuaranteeCall{Amount: amount, Date: l.clock.Now()})
+	l.outstandingDebt -= amount
+	l.clearDust()
+	l.updateStatus()
+
+	return nil
+}
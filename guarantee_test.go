@@ -0,0 +1,45 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_CallGuarantee(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithGuarantor("Budi", 300000), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.Equal(t, 300000.0, loan.GuaranteeRemaining())
+
+	assert.NoError(t, loan.CallGuarantee(200000))
+	assert.Equal(t, 900000.0, loan.GetOutstanding())
+	assert.Equal(t, 100000.0, loan.GuaranteeRemaining())
+
+	err := loan.CallGuarantee(150000)
+	assert.EqualError(t, err, "guarantee call must not exceed the remaining guarantee of 100000.00")
+	assert.Equal(t, 900000.0, loan.GetOutstanding())
+
+	assert.NoError(t, loan.CallGuarantee(100000))
+	assert.Equal(t, 0.0, loan.GuaranteeRemaining())
+
+	guarantor := loan.GetGuarantor()
+	assert.Equal(t, "Budi", guarantor.Name)
+	assert.Equal(t, []GuaranteeCall{
+		{Amount: 200000, Date: clock.Now()},
+		{Amount: 100000, Date: clock.Now()},
+	}, guarantor.Calls)
+}
+
+func TestLoan_CallGuarantee_Invalid(t *testing.T) {
+	loan := NewLoan()
+	assert.EqualError(t, loan.CallGuarantee(1000), "loan has no guarantor")
+	assert.Nil(t, loan.GetGuarantor())
+
+	guaranteed := NewLoan(WithGuarantor("Budi", 300000))
+	assert.EqualError(t, guaranteed.CallGuarantee(0), "guarantee call amount must be positive")
+}
@@ -0,0 +1,62 @@
+package billing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// icsDateFormat and icsTimestampFormat are the iCalendar DATE and UTC DATE-TIME formats
+const (
+	icsDateFormat      = "20060102"
+	icsTimestampFormat = "20060102T150405Z"
+)
+
+// WriteScheduleICS writes the unpaid installments that are not yet overdue to w
+// as an iCalendar (RFC 5545) calendar, with an all-day event on each due date
+func (l *Loan) WriteScheduleICS(w io.Writer) error {
+	entries, err := l.NextNInstallments(l.totalWeeks)
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(w)
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(buf, format+"\r\n", args...)
+	}
+
+	stamp := l.clock.Now().UTC().Format(icsTimestampFormat)
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//aladhims//billing//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, entry := range entries {
+		if entry.Overdue {
+			continue
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s-week-%d@billing", l.id, entry.Week)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART;VALUE=DATE:%s", entry.DueDate.Format(icsDateFormat))
+		line("SUMMARY:Loan payment due")
+		line("DESCRIPTION:Installment %d of %d: %.2f", entry.Week+1, l.totalWeeks, entry.Amount)
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	return buf.Flush()
+}
+
+// WriteScheduleICS writes the upcoming installments of a loan to w as an iCalendar calendar
+func (e *Engine) WriteScheduleICS(id string, w io.Writer) error {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	return loan.WriteScheduleICS(w)
+}
@@ -0,0 +1,89 @@
+package billing
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// icsContentLine matches an unfolded iCalendar content line: a name, optional
+// parameters and a value
+var icsContentLine = regexp.MustCompile(`^[A-Z-]+(;[A-Z-]+=[^;:]+)*:.*$`)
+
+// parseICS checks that data is a well-formed iCalendar stream and returns the
+// properties of each VEVENT it contains
+func parseICS(t *testing.T, data string) []map[string]string {
+	assert.True(t, strings.HasSuffix(data, "\r\n"), "Lines must end with CRLF")
+
+	var stack []string
+	var events []map[string]string
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+		if !assert.Regexp(t, icsContentLine, line) {
+			continue
+		}
+		name := strings.SplitN(strings.SplitN(line, ":", 2)[0], ";", 2)[0]
+		value := strings.SplitN(line, ":", 2)[1]
+
+		switch name {
+		case "BEGIN":
+			stack = append(stack, value)
+			if value == "VEVENT" {
+				events = append(events, map[string]string{})
+			}
+		case "END":
+			if assert.NotEmpty(t, stack) {
+				assert.Equal(t, stack[len(stack)-1], value, "Components must be properly nested")
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if len(stack) > 0 && stack[len(stack)-1] == "VEVENT" {
+				events[len(events)-1][name] = value
+			}
+		}
+	}
+
+	assert.Empty(t, stack, "Every component must be closed")
+	return events
+}
+
+func TestLoan_WriteScheduleICS(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   4,
+	}))
+	assert.NoError(t, loan.MakePayment(275000))
+	clock.Advance(5 * HoursPerDay * time.Hour)
+
+	var buf bytes.Buffer
+	assert.NoError(t, loan.WriteScheduleICS(&buf))
+
+	assert.True(t, strings.HasPrefix(buf.String(), "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	events := parseICS(t, buf.String())
+
+	assert.Len(t, events, 3, "Only unpaid installments should be included")
+	for _, event := range events {
+		assert.Equal(t, "Loan payment due", event["SUMMARY"])
+		assert.NotEmpty(t, event["UID"])
+		assert.NotEmpty(t, event["DTSTAMP"])
+	}
+	assert.Equal(t, "20240115", events[0]["DTSTART"])
+	assert.Equal(t, "Installment 2 of 4: 275000.00", events[0]["DESCRIPTION"])
+	assert.Equal(t, "20240129", events[2]["DTSTART"])
+}
+
+func TestEngine_WriteScheduleICS(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(newMockClock()))
+
+	var buf bytes.Buffer
+	assert.NoError(t, engine.WriteScheduleICS("loan1", &buf))
+	assert.Len(t, parseICS(t, buf.String()), 50)
+
+	assert.EqualError(t, engine.WriteScheduleICS("missing", &buf), "loan not found")
+}
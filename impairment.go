@@ -0,0 +1,71 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Impairment records a write-down of a loan's carrying value for accounting
+type Impairment struct {
+	Amount float64
+	Reason string
+	Date   time.Time
+}
+
+// Impair writes down the loan's carrying value by amount without changing the
+// outstanding debt, which remains collectable. The amount must be positive and
+// must not exceed the current carrying value.
+func (l *Loan) Impair(amount float64, reason string) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if amount <= 0 {
+		return errors.New("impairment amount must be positive")
+	}
+	if carrying := l.CarryingValue(); amount > carrying+paymentTolerance {
+		return fmt.Errorf("impairment must not exceed the carrying value of %.2f", carrying)
+	}
+
+	l.impairments = append(l.impairments, Impairment{Amount: amount, Reason: reason, Date: l.clock.Now()})
+	return nil
+}
+
+// TotalImpairment returns the sum of all impairments recorded on the loan
+func (l *Loan) TotalImpairment() float64 {
+	total := 0.0
+	for _, impairment := range l.impairments {
+		total += impairment.Amount
+	}
+	return total
+}
+
+// CarryingValue returns the outstanding debt net of impairments, never below zero
+func (l *Loan) CarryingValue() float64 {
+	carrying := l.outstandingDebt - l.TotalImpairment()
+	if carrying < 0 {
+		return 0
+	}
+	return carrying
+}
+
+// GetImpairments returns a copy of the impairments slice
+func (l *Loan) GetImpairments() []Impairment {
+	impairmentsCopy := make([]Impairment, len(l.impairments))
+	copy(impairmentsCopy, l.impairments)
+	return impairmentsCopy
+}
+
+// TotalImpairment returns the sum of impairments recorded across the engine's
+// loans in each currency, keyed by ISO 4217 code
+func (e *Engine) TotalImpairment() map[string]float64 {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	totals := make(map[string]float64)
+	for _, loan := range e.loans {
+		totals[loan.currency] += loan.TotalImpairment()
+	}
+	return totals
+}
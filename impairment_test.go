@@ -0,0 +1,43 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_Impair(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.Impair(300000, "borrower unreachable"))
+
+	assert.Equal(t, 1100000.0, loan.GetOutstanding(), "Contractual debt should be unchanged")
+	assert.Equal(t, 800000.0, loan.CarryingValue())
+	assert.Equal(t, []Impairment{{Amount: 300000, Reason: "borrower unreachable", Date: clock.Now()}}, loan.GetImpairments())
+
+	assert.EqualError(t, loan.Impair(900000, "too much"), "impairment must not exceed the carrying value of 800000.00")
+	assert.EqualError(t, loan.Impair(0, "none"), "impairment amount must be positive")
+
+	assert.NoError(t, loan.MakePayment(22000))
+	assert.Equal(t, 778000.0, loan.CarryingValue(), "Payments should reduce the carrying value")
+}
+
+func TestEngine_TotalImpairment(t *testing.T) {
+	engine := NewEngine()
+	first, _ := engine.CreateLoan(WithLoanID("loan1"))
+	second, _ := engine.CreateLoan(WithLoanID("loan2"))
+	_, _ = engine.CreateLoan(WithLoanID("loan3"))
+	usd, _ := engine.CreateLoan(WithLoanID("loan4"), WithCurrency("USD"))
+
+	_ = first.Impair(100000, "restructuring")
+	_ = first.Impair(50000, "restructuring")
+	_ = second.Impair(200000, "fraud")
+	_ = usd.Impair(500, "fraud")
+
+	assert.Equal(t, map[string]float64{"IDR": 350000, "USD": 500}, engine.TotalImpairment())
+}
@@ -0,0 +1,105 @@
+package billing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// importDateLayout is the layout of the start_date column read by ImportCSV
+const importDateLayout = "2006-01-02"
+
+// ImportCSV creates loans from CSV rows with the columns id, principal, rate,
+// weeks and start_date (YYYY-MM-DD). The first row is a header and is skipped.
+// Each row is validated like NewLoanValidated; rows that fail are reported in
+// errs, identified by their line number, and do not stop the import.
+func (e *Engine) ImportCSV(r io.Reader) (imported int, errs []error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 5
+	reader.TrimLeadingSpace = true
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if line == 1 {
+			continue
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				errs = append(errs, err)
+				break
+			}
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+
+		loan, err := loanFromRecord(record)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+		if _, exists := e.loans[loan.GetID()]; exists {
+			errs = append(errs, fmt.Errorf("line %d: loan with ID %s already exists", line, loan.GetID()))
+			continue
+		}
+		if err := e.logCreation(loan); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+
+		e.store(loan)
+		e.emit(creationEvent(loan))
+		imported++
+	}
+
+	return imported, errs
+}
+
+// loanFromRecord builds a validated loan from an id, principal, rate, weeks,
+// start_date CSV record
+func loanFromRecord(record []string) (*Loan, error) {
+	id := record[0]
+	if id == "" {
+		return nil, errors.New("id must not be empty")
+	}
+
+	principal, err := strconv.ParseFloat(record[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid principal %q", record[1])
+	}
+	rate, err := strconv.ParseFloat(record[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate %q", record[2])
+	}
+	weeks, err := strconv.Atoi(record[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid weeks %q", record[3])
+	}
+	startDate, err := time.Parse(importDateLayout, record[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_date %q", record[4])
+	}
+
+	loan, err := NewLoanValidated(WithLoanID(id), WithLoanConfig(Config{
+		Principal:    principal,
+		InterestRate: rate,
+		TotalWeeks:   weeks,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if err := loan.SetStartDate(startDate); err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
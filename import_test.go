@@ -0,0 +1,41 @@
+package billing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_ImportCSV(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("existing"))
+
+	input := `id,principal,rate,weeks,start_date
+loan1,1000000,0.10,50,2024-01-01
+loan2,abc,0.10,50,2024-01-01
+loan3,2000000,0.20,25,2024-02-05
+loan4,1000000,0.10,0,2024-01-01
+existing,1000000,0.10,50,2024-01-01
+loan5,1000000,0.10
+`
+
+	imported, errs := engine.ImportCSV(strings.NewReader(input))
+
+	assert.Equal(t, 2, imported)
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], `line 3: invalid principal "abc"`)
+	assert.EqualError(t, errs[1], "line 5: total weeks must be positive")
+	assert.EqualError(t, errs[2], "line 6: loan with ID existing already exists")
+	assert.Contains(t, errs[3].Error(), "line 7:")
+
+	loan, err := engine.GetLoan("loan3")
+	assert.NoError(t, err)
+	assert.Equal(t, 2000000.0, loan.GetPrincipal())
+	assert.Equal(t, 0.20, loan.GetInterestRate())
+	assert.Equal(t, time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), loan.GetStartDate())
+
+	_, err = engine.GetLoan("loan2")
+	assert.Error(t, err, "Bad row should not be imported")
+}
@@ -3,6 +3,10 @@ package billing
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,15 +20,41 @@ const (
 	Active LoanStatus = iota
 	Delinquent
 	Closed
+	Suspended
+	PastDue
 )
 
+// String returns the name of the loan status
+func (s LoanStatus) String() string {
+	switch s {
+	case Active:
+		return "Active"
+	case Delinquent:
+		return "Delinquent"
+	case Closed:
+		return "Closed"
+	case Suspended:
+		return "Suspended"
+	case PastDue:
+		return "PastDue"
+	default:
+		return fmt.Sprintf("LoanStatus(%d)", int(s))
+	}
+}
+
 // Loan-related durations
 const (
 	DaysPerWeek          = 7
+	DaysPerYear          = 365
 	HoursPerDay          = 24
+	PastDueThreshold     = DaysPerWeek * HoursPerDay * time.Hour
 	DelinquencyThreshold = 2 * DaysPerWeek * HoursPerDay * time.Hour
 )
 
+// paymentTolerance is the largest difference treated as floating-point noise
+// when matching payment amounts against installments and balances
+const paymentTolerance = 1e-6
+
 const (
 	// DefaultPrincipal is the default loan principal amount in IDR
 	DefaultPrincipal = 5_000_000 // 5 million IDR
@@ -34,6 +64,25 @@ const (
 
 	// DefaultLoanDurationWeeks is the default loan duration in weeks
 	DefaultLoanDurationWeeks = 50
+
+	// DefaultCurrency is the ISO 4217 currency of loans created without WithCurrency
+	DefaultCurrency = "IDR"
+)
+
+// ErrNotModifiable is returned when a loan's terms are changed while its status
+// does not permit modifications
+var ErrNotModifiable = errors.New("loan cannot be modified in its current status")
+
+// ErrLoanImmutable is returned when a loan created WithImmutable is mutated
+var ErrLoanImmutable = errors.New("loan is immutable")
+
+// Limits enforced by NewLoanValidated. Setting a limit to zero disables its check.
+var (
+	// MaxTotalWeeks is the longest loan term in weeks that may be created
+	MaxTotalWeeks = 520 // 10 years
+
+	// MaxPrincipal is the largest loan principal that may be created
+	MaxPrincipal = 10_000_000_000.0 // 10 billion IDR
 )
 
 // Config holds the configuration for loan creation
@@ -41,6 +90,73 @@ type Config struct {
 	Principal    float64
 	InterestRate float64
 	TotalWeeks   int
+
+	// NegAmCap caps how high the outstanding balance may grow through partial
+	// payments, as a multiple of the principal. Zero disables the cap.
+	NegAmCap float64
+
+	// ServicingFee is the fee charged per week for servicing the loan
+	ServicingFee float64
+
+	// UpfrontFee is the one-off fee charged when the loan is originated
+	UpfrontFee float64
+
+	// OriginationFee is a fee financed into the loan: it is added to the
+	// principal, so interest is charged on it and it is repaid in installments
+	OriginationFee float64
+
+	// DayCount is the day count convention used for accrued interest. Defaults to Actual365.
+	DayCount DayCountConvention
+
+	// AllocationOrder determines how payments are split between principal and
+	// interest. Defaults to InterestFirst.
+	AllocationOrder AllocationOrder
+
+	// MinorUnit is the smallest currency unit installments are rounded to, e.g. 1
+	// for whole IDR. The rounding residual is collected in the final installment.
+	// Zero disables rounding.
+	MinorUnit float64
+
+	// MinPaymentsBeforePayoff is the number of installments that must be paid
+	// before the loan may be paid off early. Zero always allows payoff.
+	MinPaymentsBeforePayoff int
+
+	// InterestMethod determines how interest is charged. Defaults to FlatInterest.
+	InterestMethod InterestMethod
+
+	// MaxCatchupInstallments caps how many missed installments a single payment
+	// must cover, so arrears can be caught up incrementally. Zero means no cap.
+	MaxCatchupInstallments int
+
+	// PenaltyRate is the annual rate of penalty interest charged each week on
+	// overdue installments. Zero disables penalty interest.
+	PenaltyRate float64
+
+	// CapitalizePenalties adds accrued penalty interest to the outstanding debt,
+	// so further penalty interest accrues on it, instead of tracking it as a
+	// separate fee
+	CapitalizePenalties bool
+
+	// LateFee is the flat fee charged for each installment that is not settled
+	// by the end of its week. It is tracked apart from the outstanding debt.
+	LateFee float64
+
+	// WaiveFirstLateFee waives the first late fee the loan incurs as goodwill
+	WaiveFirstLateFee bool
+
+	// BalloonAmount is the large final payment of a balloon loan. The regular
+	// installments repay the rest of the total over all but the final week.
+	// Zero disables the balloon.
+	BalloonAmount float64
+}
+
+// CostBreakdown itemizes the total cost of credit of a loan
+type CostBreakdown struct {
+	Interest        float64
+	ServicingFees   float64
+	UpfrontFees     float64
+	OriginationFees float64
+	Total           float64
 }
 
 // DefaultConfig provides default values for loan configuration
@@ -50,23 +166,176 @@ var DefaultConfig = Config{
 	TotalWeeks:   DefaultLoanDurationWeeks, // TODO: can consider to improve on duration instead of weekly-based duration
 }
 
+// Clock provides the current time to a loan
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock backed by time.Now
+type systemClock struct{}
+
+// Now returns the current local time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
 // Payment represents a single payment made towards a loan
 type Payment struct {
+	Amount  float64
+	Date    time.Time
+	Subsidy float64 // the part of Amount paid by a subsidy provider
+	Credit  float64 // the part of Amount held as overpayment credit rather than applied to the debt
+
+	Installments int     // how many installments, from the first unsettled one, the payment settled
+	Carried      float64 // paid towards those installments by earlier partial payments
+	Charges      float64 // collected with the payment for the loan's charges, on top of Amount
+
+	oneOffs []int // the one-off charges the payment collected
+}
+
+// Adjustment represents a manual correction to the outstanding balance of a loan.
+// A positive amount reduces the outstanding balance and a negative amount increases it.
+type Adjustment struct {
 	Amount float64
+	Reason string
 	Date   time.Time
 }
 
+// PaymentWithBalance is a payment annotated with the outstanding balance after it was applied
+type PaymentWithBalance struct {
+	Payment
+	BalanceAfter float64
+}
+
+// VarianceEntry compares the planned installment of a week with what was actually paid
+type VarianceEntry struct {
+	Week               int
+	Planned            float64
+	Actual             float64
+	CumulativeVariance float64
+}
+
+// ScheduleEntry is an upcoming installment with the date it falls due
+type ScheduleEntry struct {
+	Week    int
+	DueDate time.Time
+	Amount  float64
+	Overdue bool
+}
+
+// StatusChange records a transition of the loan from one status to another
+type StatusChange struct {
+	From LoanStatus
+	To   LoanStatus
+	Date time.Time
+}
+
+// installmentChange records the weekly installment in effect from a given week onward
+type installmentChange struct {
+	fromWeek int
+	amount   float64
+}
+
 // Loan represents a loan with its properties and methods
 type Loan struct {
-	id              string
-	principal       float64
-	interestRate    float64
-	totalWeeks      int
-	weeklyPayment   float64
-	startDate       time.Time
-	payments        []Payment
-	outstandingDebt float64
-	status          LoanStatus
+	loanState
+
+	schedule      []float64
+	scheduleMutex sync.Mutex
+}
+
+// loanState holds every field of a loan except its schedule cache, so that the
+// state can be copied and restored as a whole
+type loanState struct {
+	id                   string
+	principal            float64
+	interestRate         float64
+	totalWeeks           int
+	weeklyPayment        float64
+	startDate            time.Time
+	payments             []Payment
+	outstandingDebt      float64
+	status               LoanStatus
+	installmentChanges   []installmentChange
+	negAmCap             float64
+	servicingFee         float64
+	upfrontFee           float64
+	originationFee       float64
+	dayCount             DayCountConvention
+	allocationOrder      AllocationOrder
+	minorUnit            float64
+	roundingResidual     float64
+	minPaymentsPayoff    int
+	interestMethod       InterestMethod
+	maxCatchup           int
+	penaltyRate          float64
+	capitalizePenalties  bool
+	penaltyCycles        int
+	capitalizedPenalties float64
+	penaltyFees          float64
+	lateFee              float64
+	waiveFirstLateFee    bool
+	lateFeeCycles        int
+	lateFees             float64
+	waivedFees           float64
+	alignFirstPayment    bool
+	billingDay           time.Weekday
+	firstPaymentStub     float64
+	guarantor            *Guarantor
+	installmentOverrides map[int]float64
+	deferrals            []deferral
+	outstandingCache     *uint64
+	purpose              Purpose
+	productType          string
+	borrowerID           string
+	impairments          []Impairment
+	discounts            []Discount
+	overpaymentCredit    bool
+	creditBalance        float64
+	creditApplications   []Payment
+	partialPaid          float64 // paid by partial payments towards the next unsettled installment
+	optionErr            error   // the first invalid option, reported by NewLoanValidated
+	configDependent      string  // the first option applied that must follow WithLoanConfig
+	clock                Clock
+	suspensions          []suspension
+	statusBeforeSuspend  LoanStatus
+	adjustments          []Adjustment
+	reliefPeriods        []period
+	metadata             map[string]string
+	immutable            bool
+	subsidy              float64
+	principalCollected   float64
+	interestCollected    float64
+	charges              []Charge
+	chargesCollected     float64
+	disbursements        []Disbursement
+	undrawn              float64
+	currency             string
+	paymentPolicy        PaymentPolicy
+	returnedPayments     []ReturnedPayment
+	balloon              float64
+	statusHistory        []StatusChange
+	repayableTotal       float64 // set by WithRepayableAndInstallment, with repayableInstallment
+	repayableInstallment float64
+}
+
+// period is a span of time; a zero end means the period is ongoing
+type period struct {
+	from time.Time
+	to   time.Time
+}
+
+// deferral records an overdue installment that was rescheduled to the end of the term
+type deferral struct {
+	week int // the week the installment was originally due
+	date time.Time
+}
+
+// suspension records a period during which the loan was suspended
+type suspension struct {
+	from   time.Time
+	to     time.Time // zero while the suspension is ongoing
+	reason string
 }
 
 // LoanOption defines a function type for loan options
@@ -79,30 +348,132 @@ func WithLoanID(id string) LoanOption {
 	}
 }
 
-// WithLoanConfig sets a custom configuration for the loan
+// WithClock sets the clock used by the loan for all time-based calculations
+func WithClock(clock Clock) LoanOption {
+	return func(l *Loan) {
+		l.clock = clock
+	}
+}
+
+// WithAlignedFirstPayment pro-rates the first installment to cover only the stub
+// period from the start date to the next billing day, so that later installments
+// fall on that weekday. The amount taken off the first installment is added to
+// the final one, keeping the total owed unchanged.
+func WithAlignedFirstPayment(billingDay time.Weekday) LoanOption {
+	return func(l *Loan) {
+		l.alignFirstPayment = true
+		l.billingDay = billingDay
+	}
+}
+
+// WithImmutable marks the loan as read-only, for loans mirrored from a system of
+// record: every method that would change it returns ErrLoanImmutable and its
+// status is no longer recomputed locally
+func WithImmutable() LoanOption {
+	return func(l *Loan) {
+		l.immutable = true
+	}
+}
+
+// WithCurrency sets the ISO 4217 code of the currency the loan is denominated in
+func WithCurrency(code string) LoanOption {
+	return func(l *Loan) {
+		l.currency = code
+	}
+}
+
+// WithLoanConfig sets a custom configuration for the loan. It must precede the
+// options that adjust the schedule it sets up, such as WithBanknoteRounding;
+// one that comes earlier is reported by NewLoanValidated.
 func WithLoanConfig(config Config) LoanOption {
 	return func(l *Loan) {
-		l.principal = config.Principal
+		if l.configDependent != "" {
+			l.optionErr = fmt.Errorf("%s must follow WithLoanConfig", l.configDependent)
+		}
+
+		l.principal = config.Principal + config.OriginationFee
 		l.interestRate = config.InterestRate
 		l.totalWeeks = config.TotalWeeks
+		l.negAmCap = config.NegAmCap
+		l.servicingFee = config.ServicingFee
+		l.upfrontFee = config.UpfrontFee
+		l.originationFee = config.OriginationFee
+		l.dayCount = config.DayCount
+		l.allocationOrder = config.AllocationOrder
+		l.minorUnit = config.MinorUnit
+		l.minPaymentsPayoff = config.MinPaymentsBeforePayoff
+		l.interestMethod = config.InterestMethod
+		l.maxCatchup = config.MaxCatchupInstallments
+		l.penaltyRate = config.PenaltyRate
+		l.capitalizePenalties = config.CapitalizePenalties
+		l.lateFee = config.LateFee
+		l.waiveFirstLateFee = config.WaiveFirstLateFee
+		l.balloon = config.BalloonAmount
+
+		if config.InterestMethod == DecliningBalance && config.TotalWeeks > 0 {
+			l.applyDecliningBalance()
+			return
+		}
 
-		totalInterest := config.Principal * config.InterestRate
-		totalAmount := config.Principal + totalInterest
-		l.weeklyPayment = totalAmount / float64(config.TotalWeeks)
+		totalInterest := l.principal * config.InterestRate
+		totalAmount := l.principal + totalInterest
+		l.weeklyPayment = l.roundToMinorUnit(totalAmount / float64(config.TotalWeeks))
+		l.roundingResidual = l.residualFor(totalAmount, l.weeklyPayment, config.TotalWeeks)
 		l.outstandingDebt = totalAmount
+
+		if l.balloon > 0 && config.TotalWeeks > 1 {
+			l.applyBalloon()
+		}
+	}
+}
+
+// followConfig records that the named option adjusts the schedule WithLoanConfig
+// sets up, so a later WithLoanConfig would silently override it
+func (l *Loan) followConfig(option string) {
+	if l.configDependent == "" {
+		l.configDependent = option
+	}
+}
+
+// WithRepayableAndInstallment sets up a flat-interest loan from the total
+// repayable amount and the weekly installment instead of the principal. The term
+// is the number of installments needed to repay the total, with a smaller final
+// installment if needed, and the principal is derived from the total using the
+// interest rate already configured, so this option must follow WithLoanConfig.
+// Invalid amounts are reported by NewLoanValidated.
+func WithRepayableAndInstallment(total, installment float64) LoanOption {
+	return func(l *Loan) {
+		if total <= 0 || installment <= 0 {
+			l.optionErr = errors.New("repayable total and installment must be positive")
+			return
+		}
+		if installment > total {
+			l.optionErr = errors.New("installment must not exceed the repayable total")
+			return
+		}
+
+		l.repayableTotal, l.repayableInstallment = total, installment
+		l.totalWeeks = int(math.Ceil(total/installment - paymentTolerance))
+		l.principal = total / (1 + l.interestRate)
+		l.interestMethod = FlatInterest
+		l.weeklyPayment = installment
+		l.roundingResidual = total - installment*float64(l.totalWeeks)
+		l.outstandingDebt = total
 	}
 }
 
 // NewLoan creates a new loan with the given options
 func NewLoan(options ...LoanOption) *Loan {
-	loan := &Loan{
-		id:           uuid.New().String(),
-		principal:    DefaultConfig.Principal,
-		interestRate: DefaultConfig.InterestRate,
-		totalWeeks:   DefaultConfig.TotalWeeks,
-		startDate:    time.Now(),
-		status:       Active,
-	}
+	loan := &Loan{loanState: loanState{
+		id:            uuid.New().String(),
+		principal:     DefaultConfig.Principal,
+		interestRate:  DefaultConfig.InterestRate,
+		totalWeeks:    DefaultConfig.TotalWeeks,
+		status:        Active,
+		currency:      DefaultCurrency,
+		clock:         systemClock{},
+		paymentPolicy: StrictPaymentPolicy{},
+	}}
 
 	totalInterest := loan.principal * loan.interestRate
 	totalAmount := loan.principal + totalInterest
@@ -113,9 +484,143 @@ func NewLoan(options ...LoanOption) *Loan {
 		option(loan)
 	}
 
+	if loan.startDate.IsZero() {
+		loan.startDate = loan.clock.Now()
+	}
+	loan.applyFirstPaymentStub()
+
 	return loan
 }
 
+// configuration returns the configuration the loan was created with
+func (l *Loan) configuration() Config {
+	return Config{
+		Principal:               l.principal + l.undrawn - l.originationFee,
+		InterestRate:            l.interestRate,
+		TotalWeeks:              l.totalWeeks,
+		NegAmCap:                l.negAmCap,
+		ServicingFee:            l.servicingFee,
+		UpfrontFee:              l.upfrontFee,
+		OriginationFee:          l.originationFee,
+		DayCount:                l.dayCount,
+		AllocationOrder:         l.allocationOrder,
+		MinorUnit:               l.minorUnit,
+		MinPaymentsBeforePayoff: l.minPaymentsPayoff,
+		InterestMethod:          l.interestMethod,
+		MaxCatchupInstallments:  l.maxCatchup,
+		PenaltyRate:             l.penaltyRate,
+		CapitalizePenalties:     l.capitalizePenalties,
+		LateFee:                 l.lateFee,
+		WaiveFirstLateFee:       l.waiveFirstLateFee,
+		BalloonAmount:           l.balloon,
+	}
+}
+
+// NewLoanValidated creates a new loan with the given options, returning an
+// error if the resulting loan configuration is invalid or exceeds the limits
+func NewLoanValidated(options ...LoanOption) (*Loan, error) {
+	loan := NewLoan(options...)
+	if err := loan.validate(); err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+// validate reports the first invalid option the loan was created with, or an
+// invalid configuration
+func (l *Loan) validate() error {
+	if l.optionErr != nil {
+		return l.optionErr
+	}
+	return l.configuration().Validate()
+}
+
+// Validate checks that the configuration describes a valid loan within the
+// limits, returning an error that names the first invalid field. It is the
+// validation NewLoanValidated and the engine apply, so a configuration can be
+// checked on its own, e.g. while a form is being filled in.
+func (c Config) Validate() error {
+	if c.Principal <= 0 {
+		return errors.New("principal must be positive")
+	}
+	if MaxPrincipal > 0 && c.Principal > MaxPrincipal {
+		return fmt.Errorf("principal must not exceed %.2f", MaxPrincipal)
+	}
+	if c.InterestRate < 0 {
+		return errors.New("interest rate must not be negative")
+	}
+	if c.TotalWeeks <= 0 {
+		return errors.New("total weeks must be positive")
+	}
+	if MaxTotalWeeks > 0 && c.TotalWeeks > MaxTotalWeeks {
+		return fmt.Errorf("total weeks must not exceed %d", MaxTotalWeeks)
+	}
+	if c.NegAmCap < 0 {
+		return errors.New("negative amortization cap must not be negative")
+	}
+	if c.ServicingFee < 0 {
+		return errors.New("servicing fee must not be negative")
+	}
+	if c.UpfrontFee < 0 {
+		return errors.New("upfront fee must not be negative")
+	}
+	if c.UpfrontFee >= c.Principal {
+		return errors.New("upfront fee must be less than the principal")
+	}
+	if c.OriginationFee < 0 {
+		return errors.New("origination fee must not be negative")
+	}
+	if c.DayCount != Actual365 && c.DayCount != Thirty360 {
+		return fmt.Errorf("unknown day count convention %d", c.DayCount)
+	}
+	if c.AllocationOrder != InterestFirst && c.AllocationOrder != PrincipalFirst {
+		return fmt.Errorf("unknown allocation order %d", c.AllocationOrder)
+	}
+	if c.InterestMethod != FlatInterest && c.InterestMethod != DecliningBalance {
+		return fmt.Errorf("unknown interest method %d", c.InterestMethod)
+	}
+	if c.MinorUnit < 0 {
+		return errors.New("minor unit must not be negative")
+	}
+	if c.MinPaymentsBeforePayoff < 0 {
+		return errors.New("minimum payments before payoff must not be negative")
+	}
+	if c.MinPaymentsBeforePayoff > c.TotalWeeks {
+		return errors.New("minimum payments before payoff must not exceed the total weeks")
+	}
+	if c.MaxCatchupInstallments < 0 {
+		return errors.New("maximum catch-up installments must not be negative")
+	}
+	if c.PenaltyRate < 0 {
+		return errors.New("penalty rate must not be negative")
+	}
+	if c.CapitalizePenalties && c.PenaltyRate == 0 {
+		return errors.New("capitalizing penalties requires a penalty rate")
+	}
+	if c.LateFee < 0 {
+		return errors.New("late fee must not be negative")
+	}
+	if c.WaiveFirstLateFee && c.LateFee == 0 {
+		return errors.New("waiving the first late fee requires a late fee")
+	}
+	if c.BalloonAmount < 0 {
+		return errors.New("balloon amount must not be negative")
+	}
+	if c.BalloonAmount > 0 {
+		if c.InterestMethod != FlatInterest {
+			return errors.New("balloon payments are only supported for flat-interest loans")
+		}
+		if c.TotalWeeks < 2 {
+			return errors.New("a balloon payment requires at least two weeks")
+		}
+		if c.BalloonAmount >= (c.Principal+c.OriginationFee)*(1+c.InterestRate) {
+			return errors.New("balloon amount must be less than the total repayable")
+		}
+	}
+	return nil
+}
+
 // GetID returns the ID of the loan
 func (l *Loan) GetID() string {
 	return l.id
@@ -126,7 +631,13 @@ func (l *Loan) GetOutstanding() float64 {
 	return l.outstandingDebt
 }
 
-// GetPrincipal returns the principal amount of the loan
+// GetCurrency returns the ISO 4217 code of the currency of the loan
+func (l *Loan) GetCurrency() string {
+	return l.currency
+}
+
+// GetPrincipal returns the principal amount of the loan, including any
+// financed origination fee
 func (l *Loan) GetPrincipal() float64 {
 	return l.principal
 }
@@ -156,6 +667,35 @@ func (l *Loan) GetStatus() LoanStatus {
 	return l.status
 }
 
+// GetStatusHistory returns a copy of the status changes of the loan, oldest first
+func (l *Loan) GetStatusHistory() []StatusChange {
+	historyCopy := make([]StatusChange, len(l.statusHistory))
+	copy(historyCopy, l.statusHistory)
+	return historyCopy
+}
+
+// setStatus changes the status of the loan and records the change. Changes made
+// at the same instant are coalesced into one, so a status that is recomputed
+// and then corrected within an operation is recorded once, or not at all if it
+// ends up unchanged.
+func (l *Loan) setStatus(status LoanStatus) {
+	if status == l.status {
+		return
+	}
+
+	now := l.clock.Now()
+	if n := len(l.statusHistory); n > 0 && l.statusHistory[n-1].Date.Equal(now) {
+		if l.statusHistory[n-1].From == status {
+			l.statusHistory = l.statusHistory[:n-1]
+		} else {
+			l.statusHistory[n-1].To = status
+		}
+	} else {
+		l.statusHistory = append(l.statusHistory, StatusChange{From: l.status, To: status, Date: now})
+	}
+	l.status = status
+}
+
 // GetPayments returns a copy of the payments slice
 func (l *Loan) GetPayments() []Payment {
 	paymentsCopy := make([]Payment, len(l.payments))
@@ -163,55 +703,1365 @@ func (l *Loan) GetPayments() []Payment {
 	return paymentsCopy
 }
 
-// IsDelinquent checks if the loan is delinquent
+// IsDelinquent checks if the loan is delinquent: two or more installments are
+// past their due date
 func (l *Loan) IsDelinquent() bool {
+	return l.overdueInstallments() >= 2
+}
+
+// overdueInstallments returns how many unsettled installments are still unpaid
+// PastDueThreshold after falling due. Each installment falls due at the start
+// of its week, as MakePayment expects it, so the grace period ends with the
+// week, consistent with DaysPastDue. Time spent suspended or in a relief period
+// is not counted.
+func (l *Loan) overdueInstallments() int {
+	settled := l.settledInstallments()
+	if l.outstandingDebt <= 0 || settled >= l.totalWeeks {
+		return 0
+	}
+
+	elapsed := l.activeDuration(l.startDate, l.clock.Now()) - PastDueThreshold
+	if elapsed <= 0 {
+		return 0
+	}
+
+	// The installment of week w is overdue once elapsed exceeds w weeks
+	week := time.Duration(DaysPerWeek) * HoursPerDay * time.Hour
+	due := int((elapsed + week - 1) / week)
+	if due > l.totalWeeks {
+		due = l.totalWeeks
+	}
+	if due <= settled {
+		return 0
+	}
+	return due - settled
+}
+
+// lastActivity returns the date of the last payment or rescheduled installment,
+// or the start date if there has been neither
+func (l *Loan) lastActivity() time.Time {
+	last := l.startDate
 	if len(l.payments) > 0 {
-		lastPaymentDate := l.payments[len(l.payments)-1].Date
-		return time.Since(lastPaymentDate) > DelinquencyThreshold
+		last = l.payments[len(l.payments)-1].Date
+	}
+	if len(l.deferrals) > 0 && l.deferrals[len(l.deferrals)-1].date.After(last) {
+		last = l.deferrals[len(l.deferrals)-1].date
+	}
+	if len(l.creditApplications) > 0 && l.creditApplications[len(l.creditApplications)-1].Date.After(last) {
+		last = l.creditApplications[len(l.creditApplications)-1].Date
 	}
+	return last
+}
 
-	return time.Since(l.startDate) > DelinquencyThreshold
+// settledInstallments returns how many installments, counted from week 0, have
+// been settled by a payment, by credit, or by rescheduling them to the end of the term
+func (l *Loan) settledInstallments() int {
+	return l.paidInstallments() + len(l.deferrals) + len(l.creditApplications)
 }
 
-// MakePayment records a payment for the loan
-func (l *Loan) MakePayment(amount float64) error {
-	currentWeek := int(time.Since(l.startDate).Hours() / (DaysPerWeek * HoursPerDay))
-	expectedPayments := currentWeek + 1 // +1 because payments start from week 0
-	actualPayments := len(l.payments)
-	missedPayments := expectedPayments - actualPayments
+// paidInstallments returns how many installments have been settled by payments,
+// which may each settle several at once when catching up or paying off
+func (l *Loan) paidInstallments() int {
+	paid := 0
+	for _, payment := range l.payments {
+		paid += payment.Installments
+	}
+	return paid
+}
 
-	if missedPayments > 0 {
-		expectedAmount := float64(missedPayments) * l.weeklyPayment
-		if amount < expectedAmount {
-			return fmt.Errorf("payment amount must be at least %.2f for %d missed payments", expectedAmount, missedPayments)
+// unsettledInstallments returns how many installments of the term are still to be settled
+func (l *Loan) unsettledInstallments() int {
+	if unsettled := l.totalWeeks - l.settledInstallments(); unsettled > 0 {
+		return unsettled
+	}
+	return 0
+}
+
+// installmentsCovered returns how many of the next n unsettled installments an
+// amount pays in full
+func (l *Loan) installmentsCovered(amount float64, n int) int {
+	settled := l.settledInstallments()
+	covered := 0
+	total := 0.0
+	for covered < n && settled+covered < l.totalWeeks {
+		total += l.installmentForWeek(settled + covered)
+		if amount < total-paymentTolerance {
+			break
 		}
-	} else if amount != l.weeklyPayment {
-		return errors.New("payment amount must be equal to the weekly payment")
+		covered++
+	}
+	return covered
+}
+
+// activeDurationSince returns the time elapsed since t, excluding any time the
+// loan spent suspended
+func (l *Loan) activeDurationSince(t time.Time) time.Duration {
+	return l.activeDuration(t, l.clock.Now())
+}
+
+// activeDuration returns the time between from and to, excluding any time the
+// loan spent suspended or in a relief period
+func (l *Loan) activeDuration(from, to time.Time) time.Duration {
+	return to.Sub(from) - l.excludedDuration(from, to)
+}
+
+// excludedDuration returns how much of the interval [from, to] the loan spent
+// suspended or in a relief period, counting overlapping periods once
+func (l *Loan) excludedDuration(from, to time.Time) time.Duration {
+	periods := make([]period, 0, len(l.suspensions)+len(l.reliefPeriods))
+	for _, s := range l.suspensions {
+		periods = append(periods, period{from: s.from, to: s.to})
+	}
+	periods = append(periods, l.reliefPeriods...)
+
+	var clipped []period
+	for _, p := range periods {
+		start, end := p.from, p.to
+		if end.IsZero() || end.After(to) {
+			end = to
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(start) {
+			clipped = append(clipped, period{from: start, to: end})
+		}
+	}
+
+	sort.Slice(clipped, func(i, j int) bool {
+		return clipped[i].from.Before(clipped[j].from)
+	})
+
+	var total time.Duration
+	var coveredUntil time.Time
+	for _, p := range clipped {
+		if p.from.Before(coveredUntil) {
+			p.from = coveredUntil
+		}
+		if p.to.After(p.from) {
+			total += p.to.Sub(p.from)
+			coveredUntil = p.to
+		}
+	}
+	return total
+}
+
+// AddReliefPeriod declares a period, such as a government-declared disaster
+// relief period, during which no delinquency accrues. The period is excluded
+// from missed-payment and delinquency calculations.
+func (l *Loan) AddReliefPeriod(from, to time.Time) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if !to.After(from) {
+		return errors.New("relief period must end after it starts")
+	}
+
+	l.reliefPeriods = append(l.reliefPeriods, period{from: from, to: to})
+	l.updateStatus()
+
+	return nil
+}
+
+// IsSuspended checks if the loan is currently suspended
+func (l *Loan) IsSuspended() bool {
+	return l.status == Suspended
+}
+
+// Suspend freezes the loan, e.g. while the borrower is in a dispute. While
+// suspended no delinquency accrues: the suspended period is excluded from
+// missed-payment and delinquency calculations.
+func (l *Loan) Suspend(reason string) error {
+	if l.immutable {
+		return ErrLoanImmutable
 	}
 
+	if l.status == Closed {
+		return errors.New("cannot suspend a closed loan")
+	}
+	if l.IsSuspended() {
+		return errors.New("loan is already suspended")
+	}
+
+	l.suspensions = append(l.suspensions, suspension{from: l.clock.Now(), reason: reason})
+	l.statusBeforeSuspend = l.status
+	l.setStatus(Suspended)
+
+	return nil
+}
+
+// Resume lifts a suspension and restores the status the loan had before it was suspended
+func (l *Loan) Resume() error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if !l.IsSuspended() {
+		return errors.New("loan is not suspended")
+	}
+
+	l.suspensions[len(l.suspensions)-1].to = l.clock.Now()
+	l.setStatus(l.statusBeforeSuspend)
+
+	return nil
+}
+
+// missedPayments returns the number of installments due so far that have not been paid
+func (l *Loan) missedPayments() int {
+	return l.missedPaymentsAsOf(l.clock.Now())
+}
+
+// activeWeekAsOf returns the loan week, counted from zero, in which t falls,
+// not counting time spent suspended or in a relief period
+func (l *Loan) activeWeekAsOf(t time.Time) int {
+	return int(l.activeDuration(l.startDate, t).Hours() / (DaysPerWeek * HoursPerDay))
+}
+
+// missedPaymentsAsOf returns the number of installments due by t that have not been paid
+func (l *Loan) missedPaymentsAsOf(t time.Time) int {
+	currentWeek := l.activeWeekAsOf(t)
+	expectedPayments := currentWeek + 1 // +1 because payments start from week 0
+	return expectedPayments - l.settledInstallments()
+}
+
+// missedAmount returns the total of the next missed installments following the ones already paid
+func (l *Loan) missedAmount(missedPayments int) float64 {
+	total := 0.0
+	for week := l.settledInstallments(); week < l.settledInstallments()+missedPayments; week++ {
+		total += l.installmentForWeek(week)
+	}
+	return total
+}
+
+// amountDue returns the amount due now: the total of all missed installments,
+// or the next installment when none is missed
+func (l *Loan) amountDue() float64 {
+	if missedPayments := l.missedPayments(); missedPayments > 0 {
+		return l.missedAmount(missedPayments)
+	}
+	return l.installmentForWeek(l.settledInstallments())
+}
+
+// borrowerAmount returns what the borrower pays to settle the next n
+// installments: their total less the subsidy covering them, plus the charges
+// collected with them
+func (l *Loan) borrowerAmount(n int) float64 {
+	return l.missedAmount(n) - l.subsidyFor(n) + l.chargesDue(n)
+}
+
+// borrowerAmountDue returns what the borrower pays now: the net amount of all
+// missed installments, or of the next installment when none is missed
+func (l *Loan) borrowerAmountDue() float64 {
+	if missedPayments := l.missedPayments(); missedPayments > 0 {
+		return l.borrowerAmount(missedPayments)
+	}
+	return l.borrowerAmount(1)
+}
+
+// updateStatus recomputes the loan status from its outstanding debt and the
+// installments past their due date: PastDue with one, Delinquent with two or more
+func (l *Loan) updateStatus() {
+	l.accruePenalties()
+	l.assessLateFees()
+	l.applyCredit()
+	l.publishOutstanding()
+
 	if l.outstandingDebt <= 0 {
-		return errors.New("loan is already fully paid")
+		l.setStatus(Closed)
+		return
+	} else if l.IsSuspended() {
+		return
 	}
 
-	l.payments = append(l.payments, Payment{Amount: amount, Date: time.Now()})
-	l.outstandingDebt -= amount
+	switch overdue := l.overdueInstallments(); {
+	case overdue >= 2:
+		l.setStatus(Delinquent)
+	case overdue == 1:
+		l.setStatus(PastDue)
+	default:
+		l.setStatus(Active)
+	}
+}
 
+// DaysPastDue returns how many days the oldest unpaid installment is overdue,
+// taking each installment to fall due at the end of its week. Time spent
+// suspended or in a relief period is not counted. It returns zero for a loan
+// that is current or closed.
+func (l *Loan) DaysPastDue() int {
 	if l.outstandingDebt <= 0 {
-		l.status = Closed
-	} else if l.IsDelinquent() {
-		l.status = Delinquent
-	} else {
-		l.status = Active
+		return 0
+	}
+	return l.daysPastDueAsOf(l.clock.Now(), l.settledInstallments())
+}
+
+// daysPastDueAsOf returns how many days the oldest unpaid installment was
+// overdue at t, given how many installments had been settled by then
+func (l *Loan) daysPastDueAsOf(t time.Time, settled int) int {
+	if settled >= l.totalWeeks {
+		return 0
 	}
 
-	return nil
+	elapsedDays := int(l.activeDuration(l.startDate, t).Hours() / HoursPerDay)
+	dueDays := (settled + 1) * DaysPerWeek
+	if elapsedDays <= dueDays {
+		return 0
+	}
+	return elapsedDays - dueDays
 }
 
-// GetBillingSchedule returns the weekly payment schedule for the loan
-func (l *Loan) GetBillingSchedule() []float64 {
-	schedule := make([]float64, l.totalWeeks)
-	for i := range schedule {
-		schedule[i] = l.weeklyPayment
+// DPDPoint is the days past due of a loan as of a date
+type DPDPoint struct {
+	Date        time.Time
+	DaysPastDue int
+}
+
+// DPDHistory reconstructs the loan's days past due for every day from the start
+// date up to now, from the payments, credit applications and rescheduled
+// installments made by each day
+func (l *Loan) DPDHistory() []DPDPoint {
+	settlements := l.settlements()
+	now := l.clock.Now()
+	var history []DPDPoint
+	settled := 0
+	for date := l.startDate; !date.After(now); date = date.AddDate(0, 0, 1) {
+		for settled < len(settlements) && !settlements[settled].Date.After(date) {
+			settled++
+		}
+		history = append(history, DPDPoint{Date: date, DaysPastDue: l.daysPastDueAsOf(date, settled)})
 	}
-	return schedule
+
+	return history
+}
+
+// TimelinessScore rates the repayment history from 0 to 100. Each installment
+// due so far scores 1 if settled by its due date, 0.5 if settled late and 0 if
+// still unsettled, and installment i is weighted by i+1 so recent behavior
+// counts more. A loan with nothing due yet scores 100.
+func (l *Loan) TimelinessScore() float64 {
+	settlements := l.settlements()
+	now := l.clock.Now()
+
+	var score, weights float64
+	for week := 0; week < l.totalWeeks; week++ {
+		dueDate := l.dueDate(week)
+		settled := week < len(settlements)
+		if !settled && !dueDate.Before(now) {
+			break
+		}
+
+		weight := float64(week + 1)
+		weights += weight
+		switch {
+		case !settled:
+		case settlements[week].Date.After(dueDate):
+			score += weight / 2
+		default:
+			score += weight
+		}
+	}
+
+	if weights == 0 {
+		return 100
+	}
+	return 100 * score / weights
+}
+
+// settlements returns everything that settled an installment, in date order, so
+// that the i-th settlement settled the installment of week i: payments, once for
+// each installment they settled, credit applications, and rescheduled
+// installments with a zero amount
+func (l *Loan) settlements() []Payment {
+	var settlements []Payment
+	for _, payment := range l.payments {
+		for i := 0; i < payment.Installments; i++ {
+			settlements = append(settlements, payment)
+		}
+	}
+	settlements = append(settlements, l.creditApplications...)
+	for _, d := range l.deferrals {
+		settlements = append(settlements, Payment{Date: d.date})
+	}
+	sort.SliceStable(settlements, func(i, j int) bool {
+		return settlements[i].Date.Before(settlements[j].Date)
+	})
+	return settlements
+}
+
+// RefreshStatus recomputes the loan status against the current time: PastDue
+// once an installment is past its due date, at the end of its week, Delinquent
+// once two are, and Active again when caught up
+func (l *Loan) RefreshStatus() {
+	if l.immutable {
+		return
+	}
+	l.updateStatus()
+}
+
+// MakePayment records a payment for the loan. Whether the amount is accepted,
+// and how it is applied, is decided by the loan's payment policy.
+func (l *Loan) MakePayment(amount float64) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	return l.paymentPolicy.Apply(l, amount)
+}
+
+// makeScheduledPayment records a payment that settles the next installment, or
+// every missed installment when the loan is overdue
+func (l *Loan) makeScheduledPayment(amount float64) error {
+	l.accruePenalties()
+	l.assessLateFees()
+	l.applyCredit()
+	if l.outstandingDebt <= 0 {
+		return errors.New("loan is already fully paid")
+	}
+
+	missedPayments := l.missedPayments()
+	required := l.catchupInstallments()
+	credit := 0.0
+
+	// The amount is what the borrower pays; the subsidy covers the rest of
+	// each installment the payment must settle
+	subsidy := l.subsidyFor(required)
+	amount += subsidy
+
+	// Charges are paid on top of the installments and do not reduce the debt
+	charges := l.chargesDue(required)
+	amount -= charges
+
+	// Earlier partial payments count towards the installments this one settles
+	paid := amount + l.partialPaid
+
+	if missedPayments > 0 {
+		expectedAmount := math.Min(l.missedAmount(required), l.outstandingDebt+l.partialPaid)
+		if paid < expectedAmount-paymentTolerance {
+			return fmt.Errorf("payment amount must be at least %.2f for %d missed payments", expectedAmount-l.partialPaid-subsidy+charges, required)
+		}
+		if excess := paid - l.missedAmount(missedPayments); l.overpaymentCredit && excess > 0 {
+			credit = excess
+		}
+	} else if installment := math.Min(l.installmentForWeek(l.settledInstallments()), l.outstandingDebt+l.partialPaid); l.overpaymentCredit && paid > installment {
+		credit = paid - installment
+	} else if math.Abs(paid-installment) > paymentTolerance {
+		return errors.New("payment amount must be equal to the weekly payment")
+	}
+
+	if amount-credit > l.outstandingDebt+paymentTolerance {
+		return fmt.Errorf("payment amount must not exceed the outstanding balance of %.2f", l.outstandingDebt-subsidy+charges)
+	}
+
+	installments := 1
+	if missedPayments > 0 {
+		installments = l.installmentsCovered(paid-credit, missedPayments)
+	}
+
+	oneOffs := l.collectCharges(charges)
+	l.recordPayment(Payment{Amount: amount, Date: l.clock.Now(), Subsidy: subsidy, Credit: credit, Installments: installments,
+		Charges: charges, oneOffs: oneOffs})
+	l.outstandingDebt -= amount - credit
+	l.creditBalance += credit
+	l.clearDust()
+	l.updateStatus()
+
+	return nil
+}
+
+// catchupInstallments returns how many missed installments a payment must
+// settle: all of them, limited to the maximum catch-up if one is set
+func (l *Loan) catchupInstallments() int {
+	required := l.missedPayments()
+	if l.maxCatchup > 0 && required > l.maxCatchup {
+		required = l.maxCatchup
+	}
+	return required
+}
+
+// clearDust zeroes an outstanding balance that is only floating-point noise left
+// by paying a final installment that absorbed the rounding residual
+func (l *Loan) clearDust() {
+	if math.Abs(l.outstandingDebt) < paymentTolerance {
+		l.outstandingDebt = 0
+	}
+}
+
+// MakePartialPayment records a payment that may be smaller than the amount due.
+// It settles an installment only once the partial payments towards it add up to
+// the full installment, so a loan in arrears stays overdue until they are
+// cleared. Once the outstanding balance has reached the negative amortization cap, further
+// shortfalls are rejected and only payments covering the amount due are accepted.
+func (l *Loan) MakePartialPayment(amount float64) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	return l.makePartialPayment(amount)
+}
+
+// makePartialPayment records a payment of any positive amount against the
+// outstanding balance
+func (l *Loan) makePartialPayment(amount float64) error {
+	if amount <= 0 {
+		return errors.New("payment amount must be positive")
+	}
+
+	if l.outstandingDebt <= 0 {
+		return errors.New("loan is already fully paid")
+	}
+
+	if amount < l.amountDue() && l.IsAtNegAmCap() {
+		return fmt.Errorf("outstanding has reached the negative amortization cap of %.2f", l.negAmCap*l.principal)
+	}
+
+	// A shortfall settles an installment only once the partial payments towards
+	// it add up to the full amount
+	paid := l.partialPaid + amount
+	installments := l.installmentsCovered(paid, l.unsettledInstallments())
+	carry := math.Max(0, paid-l.missedAmount(installments))
+
+	l.recordPayment(Payment{Amount: amount, Date: l.clock.Now(), Installments: installments})
+	l.partialPaid = carry
+	l.outstandingDebt -= amount
+	l.updateStatus()
+
+	return nil
+}
+
+// IsAtNegAmCap checks if the outstanding balance has reached the negative amortization cap
+func (l *Loan) IsAtNegAmCap() bool {
+	if l.negAmCap <= 0 {
+		return false
+	}
+	return l.outstandingDebt >= l.negAmCap*l.principal
+}
+
+// GetBillingSchedule returns a copy of the weekly payment schedule for the loan
+func (l *Loan) GetBillingSchedule() []float64 {
+	schedule := l.billingSchedule()
+	scheduleCopy := make([]float64, len(schedule))
+	copy(scheduleCopy, schedule)
+	return scheduleCopy
+}
+
+// billingSchedule returns the weekly payment schedule for the loan. The
+// schedule is computed on first use and cached until a change to the loan alters
+// its installments, so the returned slice is shared and must not be modified.
+func (l *Loan) billingSchedule() []float64 {
+	l.scheduleMutex.Lock()
+	defer l.scheduleMutex.Unlock()
+
+	if l.schedule == nil {
+		schedule := make([]float64, l.totalWeeks)
+		for i := range schedule {
+			schedule[i] = l.installmentForWeek(i)
+		}
+		l.schedule = schedule
+	}
+	return l.schedule
+}
+
+// invalidateSchedule discards the cached billing schedule; it must be called
+// whenever the installments of the loan change
+func (l *Loan) invalidateSchedule() {
+	l.scheduleMutex.Lock()
+	defer l.scheduleMutex.Unlock()
+
+	l.schedule = nil
+}
+
+// installmentForWeek returns the installment due in the given week, taking
+// any installment changes and the rounding residual into account
+func (l *Loan) installmentForWeek(week int) float64 {
+	if amount, ok := l.installmentOverrides[week]; ok {
+		return amount
+	}
+
+	amount := l.weeklyPayment
+	for _, change := range l.installmentChanges {
+		if change.fromWeek > week {
+			break
+		}
+		amount = change.amount
+	}
+	if week == 0 {
+		amount -= l.firstPaymentStub
+	}
+	if week == l.totalWeeks-1 {
+		amount += l.roundingResidual + l.firstPaymentStub
+	}
+	return amount
+}
+
+// applyFirstPaymentStub recomputes how much is taken off the first installment
+// so that it only covers the days from the start date to the billing day
+func (l *Loan) applyFirstPaymentStub() {
+	if !l.alignFirstPayment {
+		return
+	}
+
+	stubDays := (int(l.billingDay) - int(l.startDate.Weekday()) + DaysPerWeek) % DaysPerWeek
+	l.firstPaymentStub = 0
+	if stubDays > 0 {
+		first := l.roundToMinorUnit(l.weeklyPayment * float64(stubDays) / DaysPerWeek)
+		l.firstPaymentStub = l.weeklyPayment - first
+	}
+	l.invalidateSchedule()
+}
+
+// IsImmutable reports whether the loan was created WithImmutable
+func (l *Loan) IsImmutable() bool {
+	return l.immutable
+}
+
+// IsModifiable reports whether the loan's status permits changing its terms.
+// Only Active and PastDue loans may be modified.
+func (l *Loan) IsModifiable() bool {
+	return l.status == Active || l.status == PastDue
+}
+
+// rateChange describes the effect of reamortizing the installments from a given
+// week at a new interest rate
+type rateChange struct {
+	installment    float64 // the new regular installment
+	remainingTotal float64 // the total of the reamortized installments
+	oldRemaining   float64 // the total of the same installments before the change
+}
+
+// rateChangeFor validates a rate change and computes its effect without applying it
+func (l *Loan) rateChangeFor(newRate float64, effectiveWeek int) (rateChange, error) {
+	if !l.IsModifiable() {
+		return rateChange{}, ErrNotModifiable
+	}
+	if l.interestMethod != FlatInterest {
+		return rateChange{}, errors.New("interest rate changes are only supported for flat-interest loans")
+	}
+	if newRate < 0 {
+		return rateChange{}, errors.New("interest rate must not be negative")
+	}
+	if effectiveWeek < 0 || effectiveWeek >= l.totalWeeks {
+		return rateChange{}, fmt.Errorf("effective week must be between 0 and %d", l.totalWeeks-1)
+	}
+	if len(l.deferrals) > 0 && effectiveWeek <= l.deferrals[len(l.deferrals)-1].week {
+		return rateChange{}, errors.New("effective week must be after any rescheduled installment")
+	}
+	if l.balloon > 0 {
+		return rateChange{}, errors.New("interest rate changes are not supported for balloon loans")
+	}
+
+	remainingWeeks := l.totalWeeks - effectiveWeek
+	remainingPrincipal := l.principal * float64(remainingWeeks) / float64(l.totalWeeks)
+	change := rateChange{remainingTotal: remainingPrincipal * (1 + newRate)}
+	change.installment = l.roundToMinorUnit(change.remainingTotal / float64(remainingWeeks))
+
+	for week := effectiveWeek; week < l.totalWeeks; week++ {
+		change.oldRemaining += l.installmentForWeek(week)
+	}
+
+	return change, nil
+}
+
+// SimulateRateChange previews ChangeInterestRate without changing the loan,
+// returning the change in total interest and the new regular installment
+func (l *Loan) SimulateRateChange(newRate float64, effectiveWeek int) (deltaInterest float64, newInstallment float64, err error) {
+	change, err := l.rateChangeFor(newRate, effectiveWeek)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return change.remainingTotal - change.oldRemaining, change.installment, nil
+}
+
+// ChangeInterestRate changes the interest rate of the loan from effectiveWeek onward.
+// The remaining principal is re-charged at the new rate and the installments from
+// effectiveWeek are reamortized; installments before effectiveWeek are unchanged.
+func (l *Loan) ChangeInterestRate(newRate float64, effectiveWeek int) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	rc, err := l.rateChangeFor(newRate, effectiveWeek)
+	if err != nil {
+		return err
+	}
+
+	l.reamortize(effectiveWeek, rc)
+	l.outstandingDebt += rc.remainingTotal - rc.oldRemaining
+	l.publishOutstanding()
+	l.interestRate = newRate
+
+	return nil
+}
+
+// reamortize replaces the installments from effectiveWeek onward with the
+// regular installment of the change, collecting its rounding residual in the
+// final installment. It does not touch the outstanding debt.
+func (l *Loan) reamortize(effectiveWeek int, rc rateChange) {
+	if len(l.installmentChanges) == 0 {
+		l.installmentChanges = []installmentChange{{fromWeek: 0, amount: l.weeklyPayment}}
+	}
+	kept := l.installmentChanges[:0]
+	for _, change := range l.installmentChanges {
+		if change.fromWeek < effectiveWeek {
+			kept = append(kept, change)
+		}
+	}
+	l.installmentChanges = append(kept, installmentChange{fromWeek: effectiveWeek, amount: rc.installment})
+	for week := range l.installmentOverrides {
+		if week >= effectiveWeek {
+			delete(l.installmentOverrides, week)
+		}
+	}
+
+	l.roundingResidual = l.residualFor(rc.remainingTotal, rc.installment, l.totalWeeks-effectiveWeek)
+	l.weeklyPayment = rc.installment
+	l.invalidateSchedule()
+}
+
+// WeeksElapsed returns the number of whole weeks since the loan started,
+// including time spent suspended or in a relief period
+func (l *Loan) WeeksElapsed() int {
+	return l.weekOf(l.clock.Now())
+}
+
+// weekOf returns the loan week, counted from zero, in which t falls
+func (l *Loan) weekOf(t time.Time) int {
+	week := int(t.Sub(l.startDate).Hours() / (DaysPerWeek * HoursPerDay))
+	if week < 0 {
+		return 0
+	}
+	return week
+}
+
+// ScheduleVariance returns, for each week up to the current one, the planned
+// installment, the amount actually paid in that week and the cumulative variance
+// (actual minus planned). Payments are attributed to the week they were made in;
+// payments made after the term are attributed to the final week.
+func (l *Loan) ScheduleVariance() []VarianceEntry {
+	if l.totalWeeks <= 0 {
+		return nil
+	}
+
+	lastWeek := l.weekOf(l.clock.Now())
+	if lastWeek >= l.totalWeeks {
+		lastWeek = l.totalWeeks - 1
+	}
+
+	actual := make([]float64, lastWeek+1)
+	for _, payment := range l.payments {
+		week := l.weekOf(payment.Date)
+		if week > lastWeek {
+			week = lastWeek
+		}
+		actual[week] += payment.Amount
+	}
+
+	entries := make([]VarianceEntry, lastWeek+1)
+	cumulative := 0.0
+	for week := range entries {
+		planned := l.installmentForWeek(week)
+		cumulative += actual[week] - planned
+		entries[week] = VarianceEntry{
+			Week:               week,
+			Planned:            planned,
+			Actual:             actual[week],
+			CumulativeVariance: cumulative,
+		}
+	}
+
+	return entries
+}
+
+// AccruedInterestAsOf returns the interest accrued on the outstanding principal
+// from the last payment (or the start date if no payment was made) until t, in
+// whole days at the nominal annual rate using the loan's day count convention.
+// It is for display only and does not change the flat-interest payoff amount.
+func (l *Loan) AccruedInterestAsOf(t time.Time) float64 {
+	days := l.dayCount.dayCount(l.lastActivity(), t)
+	if days <= 0 || l.outstandingDebt <= 0 {
+		return 0
+	}
+
+	outstandingPrincipal := l.outstandingDebt / (1 + l.interestRate)
+	return outstandingPrincipal * l.interestRate * float64(days) / l.dayCount.daysPerYear()
+}
+
+// TotalCostOfCredit returns the total cost of credit over the life of the loan:
+// the projected interest from the billing schedule plus all servicing and upfront fees
+func (l *Loan) TotalCostOfCredit() CostBreakdown {
+	totalRepayable := 0.0
+	for _, installment := range l.billingSchedule() {
+		totalRepayable += installment
+	}
+
+	breakdown := CostBreakdown{
+		Interest:        totalRepayable - l.principal,
+		ServicingFees:   l.servicingFee * float64(l.totalWeeks),
+		UpfrontFees:     l.upfrontFee,
+		OriginationFees: l.originationFee,
+	}
+	breakdown.Total = breakdown.Interest + breakdown.ServicingFees + breakdown.UpfrontFees + breakdown.OriginationFees
+
+	return breakdown
+}
+
+// SetStartDate corrects the start date of the loan, keeping its payment history.
+// The status is recomputed against the new date. The start date must not be in
+// the future or after the first payment.
+func (l *Loan) SetStartDate(t time.Time) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if t.After(l.clock.Now()) {
+		return errors.New("start date must not be in the future")
+	}
+	if len(l.payments) > 0 && t.After(l.payments[0].Date) {
+		return errors.New("start date must not be after the first payment")
+	}
+
+	l.startDate = t
+	if len(l.payments) == 0 {
+		l.applyFirstPaymentStub()
+	}
+	l.updateStatus()
+
+	return nil
+}
+
+// MinimumToBecomeCurrent returns the minimum amount the borrower must pay now to
+// clear all missed installments, so that the loan is no longer behind: the
+// installments less any subsidy covering them, plus the charges collected with
+// them. It returns zero when no installment is missed or the loan is fully paid.
+func (l *Loan) MinimumToBecomeCurrent() float64 {
+	if l.outstandingDebt <= 0 {
+		return 0
+	}
+
+	missedPayments := l.missedPayments()
+	if missedPayments <= 0 {
+		return 0
+	}
+
+	minimum := l.borrowerAmount(missedPayments)
+	if missed := l.missedAmount(missedPayments); missed > l.outstandingDebt {
+		minimum -= missed - l.outstandingDebt
+	}
+	return minimum
+}
+
+// debtChangeKind identifies the record of a loan that changed its outstanding debt
+type debtChangeKind int
+
+const (
+	paymentChange debtChangeKind = iota
+	creditApplicationChange
+	returnedPaymentChange
+	adjustmentChange
+	discountChange
+	guaranteeCallChange
+	disbursementChange
+)
+
+// debtChange is a dated change of the outstanding debt, made by the record of
+// the given kind at index among the records of that kind
+type debtChange struct {
+	date    time.Time
+	kind    debtChangeKind
+	index   int
+	delta   float64 // positive when the debt grew
+	balance float64 // the outstanding debt after the change
+}
+
+// debtChanges returns every dated change of the outstanding debt, oldest first,
+// each with the balance it left. The balances are found by unwinding the
+// changes from the current outstanding debt, so changes that are not dated,
+// such as accrued penalties and late fees or interest rate changes, show in
+// the balances of the changes made before them.
+func (l *Loan) debtChanges() []debtChange {
+	var changes []debtChange
+	for i, payment := range l.payments {
+		changes = append(changes, debtChange{date: payment.Date, kind: paymentChange, index: i, delta: -(payment.Amount - payment.Credit)})
+	}
+	for i, application := range l.creditApplications {
+		changes = append(changes, debtChange{date: application.Date, kind: creditApplicationChange, index: i, delta: -application.Amount})
+	}
+	for i, returned := range l.returnedPayments {
+		changes = append(changes, debtChange{date: returned.Date, kind: returnedPaymentChange, index: i, delta: returned.Payment.Amount + returned.Fee})
+	}
+	for i, adjustment := range l.adjustments {
+		changes = append(changes, debtChange{date: adjustment.Date, kind: adjustmentChange, index: i, delta: -adjustment.Amount})
+	}
+	for i, discount := range l.discounts {
+		changes = append(changes, debtChange{date: discount.Date, kind: discountChange, index: i, delta: -discount.Amount})
+	}
+	if l.guarantor != nil {
+		for i, call := range l.guarantor.Calls {
+			changes = append(changes, debtChange{date: call.Date, kind: guaranteeCallChange, index: i, delta: -call.Amount})
+		}
+	}
+	for i, disbursement := range l.disbursements {
+		changes = append(changes, debtChange{date: disbursement.Date, kind: disbursementChange, index: i, delta: disbursement.Repayable})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].date.Before(changes[j].date)
+	})
+
+	balance := l.outstandingDebt
+	for i := len(changes) - 1; i >= 0; i-- {
+		changes[i].balance = balance
+		balance -= changes[i].delta
+	}
+
+	return changes
+}
+
+// PaymentsWithBalance returns the payments of the loan, each annotated with the
+// outstanding balance after it was applied, by unwinding the dated changes of
+// the debt from the current outstanding balance
+func (l *Loan) PaymentsWithBalance() []PaymentWithBalance {
+	entries := make([]PaymentWithBalance, len(l.payments))
+	for _, change := range l.debtChanges() {
+		if change.kind == paymentChange {
+			entries[change.index] = PaymentWithBalance{Payment: l.payments[change.index], BalanceAfter: change.balance}
+		}
+	}
+
+	return entries
+}
+
+// WeeksPaidAhead returns how many installments have been paid beyond those due
+// so far. The installment of the current week counts as due, consistent with
+// MakePayment, so it returns zero for a loan that is on track or behind.
+func (l *Loan) WeeksPaidAhead() int {
+	ahead := -l.missedPayments()
+	if ahead < 0 {
+		return 0
+	}
+	return ahead
+}
+
+// TopUpAfterRateChange returns the amount needed for the installments paid
+// ahead of schedule to cover what those installments cost now, after a rate
+// increase reamortized them. Each paid-ahead installment falls short by the
+// difference between its recomputed amount and what was paid for it. Credit
+// balances are not counted, since credit settles installments at their current
+// amount when they fall due.
+func (l *Loan) TopUpAfterRateChange() float64 {
+	ahead := l.WeeksPaidAhead()
+	if ahead == 0 || l.outstandingDebt <= 0 {
+		return 0
+	}
+
+	settlements := l.settlements()
+	topUp := 0.0
+	for week := len(settlements) - ahead; week < len(settlements); week++ {
+		if shortfall := l.installmentForWeek(week) - settlements[week].Amount; shortfall > paymentTolerance {
+			topUp += shortfall
+		}
+	}
+	return topUp
+}
+
+// IsPaidAhead checks if the loan has installments paid in advance
+func (l *Loan) IsPaidAhead() bool {
+	return l.WeeksPaidAhead() > 0
+}
+
+// Adjust applies a manual adjustment, such as a goodwill credit or a correction,
+// to the outstanding balance. A positive amount reduces the outstanding balance
+// and a negative amount increases it. The adjustment is recorded in the history.
+func (l *Loan) Adjust(amount float64, reason string) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if amount == 0 {
+		return errors.New("adjustment amount must not be zero")
+	}
+	if amount > l.outstandingDebt {
+		return errors.New("adjustment must not exceed the outstanding debt")
+	}
+
+	l.adjustments = append(l.adjustments, Adjustment{Amount: amount, Reason: reason, Date: l.clock.Now()})
+	l.outstandingDebt -= amount
+	l.updateStatus()
+
+	return nil
+}
+
+// GetAdjustments returns a copy of the adjustments slice
+func (l *Loan) GetAdjustments() []Adjustment {
+	adjustmentsCopy := make([]Adjustment, len(l.adjustments))
+	copy(adjustmentsCopy, l.adjustments)
+	return adjustmentsCopy
+}
+
+// CanPayOff checks if the loan may be paid off now. When it may not, the
+// returned error explains why.
+func (l *Loan) CanPayOff() (bool, error) {
+	if l.outstandingDebt <= 0 {
+		return false, errors.New("loan is already fully paid")
+	}
+	if l.paidInstallments() < l.minPaymentsPayoff {
+		return false, fmt.Errorf("loan cannot be paid off before %d installments are paid", l.minPaymentsPayoff)
+	}
+	return true, nil
+}
+
+// PayOff pays the full outstanding debt in a single payment, closing the loan
+func (l *Loan) PayOff() error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if ok, err := l.CanPayOff(); !ok {
+		return err
+	}
+
+	l.recordPayment(Payment{Amount: l.outstandingDebt, Date: l.clock.Now(), Installments: l.unsettledInstallments()})
+	l.outstandingDebt = 0
+	l.updateStatus()
+
+	return nil
+}
+
+// ReverseLastPayment undoes the most recent payment, adding its amount back to
+// the outstanding debt. It is not supported for loans holding overpayment
+// credit, whose payments may have been split between the debt and the credit.
+func (l *Loan) ReverseLastPayment() error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if len(l.payments) == 0 {
+		return errors.New("loan has no payments to reverse")
+	}
+
+	_, err := l.ReversePaymentAt(len(l.payments) - 1)
+	return err
+}
+
+// ReversePaymentAt removes the payment at index in the payment history, adds
+// its amount back to the outstanding debt and recomputes the status from the
+// remaining history. It returns the removed payment.
+func (l *Loan) ReversePaymentAt(index int) (Payment, error) {
+	if l.immutable {
+		return Payment{}, ErrLoanImmutable
+	}
+
+	if index < 0 || index >= len(l.payments) {
+		return Payment{}, fmt.Errorf("payment index %d is out of range", index)
+	}
+	if l.overpaymentCredit {
+		return Payment{}, errors.New("payment reversal is not supported for loans holding overpayment credit")
+	}
+
+	removed := l.payments[index]
+	l.payments = append(l.payments[:index], l.payments[index+1:]...)
+	// Only the latest payment's carried shortfall is known to be unspent
+	if index == len(l.payments) {
+		l.partialPaid = removed.Carried
+	}
+	l.uncollectCharges(removed)
+	l.principalCollected, l.interestCollected = l.allocatedTotals()
+	l.outstandingDebt += removed.Amount
+	l.updateStatus()
+
+	return removed, nil
+}
+
+// RemainingWeeks returns the number of weekly installments still needed to
+// repay the outstanding balance, counted from the end of the billing schedule.
+// It returns zero for a closed loan.
+func (l *Loan) RemainingWeeks() int {
+	remaining := l.outstandingDebt
+	if remaining <= 0 {
+		return 0
+	}
+
+	const epsilon = 1e-6
+	schedule := l.billingSchedule()
+	weeks := 0
+	for i := len(schedule) - 1; i >= 0 && remaining > epsilon; i-- {
+		remaining -= schedule[i]
+		weeks++
+	}
+	if remaining > epsilon && l.weeklyPayment > 0 {
+		weeks += int(math.Ceil(remaining/l.weeklyPayment - epsilon))
+	}
+
+	return weeks
+}
+
+// RemainingTerm returns the remaining term of the loan as a duration
+func (l *Loan) RemainingTerm() time.Duration {
+	return time.Duration(l.RemainingWeeks()) * DaysPerWeek * HoursPerDay * time.Hour
+}
+
+// MaturityDate returns the date the final installment falls due, at the end of
+// the last week of the term, including any weeks added by rescheduled
+// installments. For a closed loan it returns the date of its last payment.
+func (l *Loan) MaturityDate() time.Time {
+	if l.status == Closed {
+		return l.lastActivity()
+	}
+	return l.dueDate(l.totalWeeks - 1)
+}
+
+// dueDate returns the date the installment of the given week, counted from
+// zero, falls due, at the end of that week
+func (l *Loan) dueDate(week int) time.Time {
+	return l.startDate.AddDate(0, 0, (week+1)*DaysPerWeek)
+}
+
+// PreviewCatchup returns the weeks, counted from zero as in ScheduleVariance,
+// whose installments a payment of the given amount would cover, starting from
+// the first unpaid installment, and the remainder left over. It does not
+// record the payment.
+func (l *Loan) PreviewCatchup(amount float64) ([]int, float64, error) {
+	if amount <= 0 {
+		return nil, 0, errors.New("payment amount must be positive")
+	}
+	if l.outstandingDebt <= 0 {
+		return nil, 0, errors.New("loan is already fully paid")
+	}
+
+	const epsilon = 1e-6
+	var weeks []int
+	remaining := amount
+	for week := l.settledInstallments(); week < l.totalWeeks; week++ {
+		installment := l.installmentForWeek(week)
+		if remaining < installment-epsilon {
+			break
+		}
+		weeks = append(weeks, week)
+		remaining -= installment
+	}
+	if remaining < epsilon {
+		remaining = 0
+	}
+
+	return weeks, remaining, nil
+}
+
+// Progress returns how much of the total repayable amount has been repaid, as a
+// percentage between 0 and 100
+func (l *Loan) Progress() float64 {
+	if l.status == Closed {
+		return 100
+	}
+
+	totalRepayable := 0.0
+	for _, installment := range l.billingSchedule() {
+		totalRepayable += installment
+	}
+	if totalRepayable <= 0 {
+		return 0
+	}
+
+	progress := (totalRepayable - l.outstandingDebt) / totalRepayable * 100
+	return math.Max(0, math.Min(100, progress))
+}
+
+// String renders the loan as a readable multi-line statement
+func (l *Loan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Loan %s\n", l.id)
+	fmt.Fprintf(&b, "  Principal:      %.2f\n", l.principal)
+	fmt.Fprintf(&b, "  Interest rate:  %.2f%%\n", l.interestRate*100)
+	fmt.Fprintf(&b, "  Term:           %d weeks\n", l.totalWeeks)
+	fmt.Fprintf(&b, "  Weekly payment: %.2f\n", l.weeklyPayment)
+	fmt.Fprintf(&b, "  Outstanding:    %.2f\n", l.outstandingDebt)
+	fmt.Fprintf(&b, "  Status:         %s\n", l.status)
+	fmt.Fprintf(&b, "  Payments made:  %d\n", len(l.payments))
+	return b.String()
+}
+
+// ToMap returns the loan's fields and derived figures as a map suitable for
+// text/template. Amounts are formatted with two decimals, the rate as a
+// percentage and dates as YYYY-MM-DD.
+func (l *Loan) ToMap() map[string]interface{} {
+	amount := func(v float64) string {
+		return fmt.Sprintf("%.2f", v)
+	}
+
+	amountDue := 0.0
+	if l.outstandingDebt > 0 {
+		amountDue = l.amountDue()
+	}
+
+	return map[string]interface{}{
+		"ID":             l.id,
+		"BorrowerID":     l.borrowerID,
+		"Purpose":        l.purpose.String(),
+		"ProductType":    l.productType,
+		"Currency":       l.currency,
+		"Principal":      amount(l.principal),
+		"InterestRate":   fmt.Sprintf("%.2f%%", l.interestRate*100),
+		"TotalWeeks":     l.totalWeeks,
+		"WeeklyPayment":  amount(l.weeklyPayment),
+		"StartDate":      l.startDate.Format("2006-01-02"),
+		"Outstanding":    amount(l.outstandingDebt),
+		"CarryingValue":  amount(l.CarryingValue()),
+		"Status":         l.status.String(),
+		"PaymentCount":   len(l.payments),
+		"RemainingWeeks": l.RemainingWeeks(),
+		"AmountDue":      amount(amountDue),
+		"Progress":       fmt.Sprintf("%.1f%%", l.Progress()),
+	}
+}
+
+// NextNInstallments returns up to n unpaid installments starting from the first
+// unpaid one, each due at the end of its week. Installments already past their
+// due date are marked Overdue. Fewer than n are returned near the end of the term.
+func (l *Loan) NextNInstallments(n int) ([]ScheduleEntry, error) {
+	if n <= 0 {
+		return nil, errors.New("n must be positive")
+	}
+
+	now := l.clock.Now()
+	var entries []ScheduleEntry
+	for week := l.settledInstallments(); week < l.totalWeeks && len(entries) < n; week++ {
+		dueDate := l.dueDate(week)
+		entries = append(entries, ScheduleEntry{
+			Week:    week,
+			DueDate: dueDate,
+			Amount:  l.installmentForWeek(week),
+			Overdue: dueDate.Before(now),
+		})
+	}
+
+	return entries, nil
+}
+
+// ProjectWithLumpSums projects the remaining schedule if, on top of each
+// installment, the lump sum given for its week were paid as well, and returns
+// the projected entries with the number of weeks until the loan is paid off.
+// Lump sums for weeks already settled or past the term, and amounts that are
+// not positive, are ignored. The loan itself is not changed.
+func (l *Loan) ProjectWithLumpSums(lumps map[int]float64) ([]ScheduleEntry, int) {
+	now := l.clock.Now()
+	balance := l.outstandingDebt
+	var entries []ScheduleEntry
+	for week := l.settledInstallments(); week < l.totalWeeks && balance > paymentTolerance; week++ {
+		amount := l.installmentForWeek(week)
+		if lump := lumps[week]; lump > 0 {
+			amount += lump
+		}
+		amount = math.Min(amount, balance)
+		balance -= amount
+
+		dueDate := l.dueDate(week)
+		entries = append(entries, ScheduleEntry{
+			Week:    week,
+			DueDate: dueDate,
+			Amount:  amount,
+			Overdue: dueDate.Before(now),
+		})
+	}
+
+	return entries, len(entries)
+}
+
+// RescheduleInstallment defers the overdue, unpaid installment of the given week
+// to a new final week, extending the term by one week. The deferred installment
+// no longer counts as missed and the reschedule counts as account activity.
+func (l *Loan) RescheduleInstallment(week int) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if l.outstandingDebt <= 0 {
+		return errors.New("loan is already fully paid")
+	}
+	settled := l.settledInstallments()
+	if week < settled || week >= l.totalWeeks {
+		return fmt.Errorf("installment for week %d is not unpaid", week)
+	}
+	if week >= settled+l.missedPayments() {
+		return fmt.Errorf("installment for week %d is not overdue", week)
+	}
+
+	final := l.totalWeeks - 1
+	deferred := l.installmentForWeek(week)
+	firstUnpaid := l.installmentForWeek(settled)
+	lastInstallment := l.installmentForWeek(final)
+
+	// Installments are settled in week order, so the first unpaid week is the
+	// one marked settled; when a later week is deferred it takes that week's amount
+	if l.installmentOverrides == nil {
+		l.installmentOverrides = make(map[int]float64)
+	}
+	l.installmentOverrides[final] = lastInstallment
+	if week != settled {
+		l.installmentOverrides[week] = firstUnpaid
+	}
+	l.installmentOverrides[settled] = 0
+	l.installmentOverrides[final+1] = deferred
+
+	l.deferrals = append(l.deferrals, deferral{week: week, date: l.clock.Now()})
+	l.totalWeeks++
+	l.invalidateSchedule()
+	l.updateStatus()
+
+	return nil
+}
+
+// PaymentToCloseByWeek returns the single amount that, paid now, settles the
+// loan as if it were fully repaid by the given week: every unpaid installment up
+// to and including that week, plus only the principal portion of the later
+// installments, whose interest is rebated, less any adjustments or discounts
+// already taken off the debt. No prepayment penalty applies.
+func (l *Loan) PaymentToCloseByWeek(week int) (float64, error) {
+	if l.outstandingDebt <= 0 {
+		return 0, errors.New("loan is already fully paid")
+	}
+	if currentWeek := l.activeWeekAsOf(l.clock.Now()); week <= currentWeek || week >= l.totalWeeks {
+		return 0, fmt.Errorf("week must be after the current week %d and before %d", currentWeek, l.totalWeeks)
+	}
+
+	amount, scheduled := 0.0, 0.0
+	for _, entry := range l.AmortizationSchedule()[l.settledInstallments():] {
+		scheduled += entry.Payment
+		if entry.Week <= week {
+			amount += entry.Payment
+		} else {
+			amount += entry.Principal
+		}
+	}
+
+	// Adjustments and discounts reduce the debt without settling installments,
+	// so pass any reduction below the scheduled remainder on to the borrower
+	if reduction := scheduled - l.outstandingDebt; reduction > 0 {
+		amount -= reduction
+	}
+	return math.Max(0, math.Min(amount, l.outstandingDebt)), nil
+}
+
+// CloseByWeek pays the amount returned by PaymentToCloseByWeek and closes the
+// loan, recording the rebated interest as an adjustment
+func (l *Loan) CloseByWeek(week int) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	amount, err := l.PaymentToCloseByWeek(week)
+	if err != nil {
+		return err
+	}
+	if ok, err := l.CanPayOff(); !ok {
+		return err
+	}
+
+	rebate := l.outstandingDebt - amount
+	l.recordPayment(Payment{Amount: amount, Date: l.clock.Now(), Installments: l.unsettledInstallments()})
+	if rebate > paymentTolerance {
+		l.adjustments = append(l.adjustments, Adjustment{Amount: rebate, Reason: "interest rebate for early closure", Date: l.clock.Now()})
+	}
+	l.outstandingDebt = 0
+	l.updateStatus()
+
+	return nil
 }
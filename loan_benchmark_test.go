@@ -0,0 +1,22 @@
+package billing
+
+import "testing"
+
+func BenchmarkLoan_GetBillingSchedule(b *testing.B) {
+	loan := NewLoan()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = loan.GetBillingSchedule()
+	}
+}
+
+func BenchmarkLoan_GetBillingSchedule_Uncached(b *testing.B) {
+	loan := NewLoan()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loan.invalidateSchedule()
+		_ = loan.GetBillingSchedule()
+	}
+}
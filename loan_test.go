@@ -1,13 +1,32 @@
 package billing
 
 import (
+	"strings"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
+// mockClock is a Clock whose time only moves when advanced explicitly
+type mockClock struct {
+	now time.Time
+}
+
+func newMockClock() *mockClock {
+	return &mockClock{now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *mockClock) Now() time.Time {
+	return c.now
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
 func TestNewLoan(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -68,6 +87,109 @@ func TestNewLoan(t *testing.T) {
 	}
 }
 
+func TestNewLoanValidated(t *testing.T) {
+	defer func(weeks int, principal float64) {
+		MaxTotalWeeks, MaxPrincipal = weeks, principal
+	}(MaxTotalWeeks, MaxPrincipal)
+	MaxTotalWeeks = 100
+	MaxPrincipal = 10000000
+
+	tests := []struct {
+		name          string
+		config        Config
+		expectedError string
+	}{
+		{"Term just under limit", Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 100}, ""},
+		{"Term just over limit", Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 101}, "total weeks must not exceed 100"},
+		{"Principal just under limit", Config{Principal: 10000000, InterestRate: 0.10, TotalWeeks: 50}, ""},
+		{"Principal just over limit", Config{Principal: 10000001, InterestRate: 0.10, TotalWeeks: 50}, "principal must not exceed 10000000.00"},
+		{"Zero weeks", Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 0}, "total weeks must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan, err := NewLoanValidated(WithLoanConfig(tt.config))
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Nil(t, loan)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, loan)
+			}
+		})
+	}
+
+	t.Run("Zero limits disable the checks", func(t *testing.T) {
+		MaxTotalWeeks, MaxPrincipal = 0, 0
+		_, err := NewLoanValidated(WithLoanConfig(Config{Principal: 1e12, InterestRate: 0.10, TotalWeeks: 10000}))
+		assert.NoError(t, err)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+	with := func(change func(c *Config)) Config {
+		c := valid
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		config        Config
+		expectedError string
+	}{
+		{"Valid", valid, ""},
+		{"Valid with every field", Config{
+			Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50, NegAmCap: 1.2, ServicingFee: 500,
+			UpfrontFee: 10000, OriginationFee: 20000, DayCount: Thirty360, AllocationOrder: PrincipalFirst,
+			MinorUnit: 100, MinPaymentsBeforePayoff: 10, InterestMethod: DecliningBalance, MaxCatchupInstallments: 2,
+			PenaltyRate: 0.05, CapitalizePenalties: true, LateFee: 5000, WaiveFirstLateFee: true,
+		}, ""},
+		{"Zero principal", with(func(c *Config) { c.Principal = 0 }), "principal must be positive"},
+		{"Negative interest rate", with(func(c *Config) { c.InterestRate = -0.01 }), "interest rate must not be negative"},
+		{"Zero weeks", with(func(c *Config) { c.TotalWeeks = 0 }), "total weeks must be positive"},
+		{"Negative neg-am cap", with(func(c *Config) { c.NegAmCap = -1 }), "negative amortization cap must not be negative"},
+		{"Negative servicing fee", with(func(c *Config) { c.ServicingFee = -1 }), "servicing fee must not be negative"},
+		{"Negative upfront fee", with(func(c *Config) { c.UpfrontFee = -1 }), "upfront fee must not be negative"},
+		{"Upfront fee covering the principal", with(func(c *Config) { c.UpfrontFee = 1000000 }), "upfront fee must be less than the principal"},
+		{"Negative origination fee", with(func(c *Config) { c.OriginationFee = -1 }), "origination fee must not be negative"},
+		{"Unknown day count", with(func(c *Config) { c.DayCount = 5 }), "unknown day count convention 5"},
+		{"Unknown allocation order", with(func(c *Config) { c.AllocationOrder = 5 }), "unknown allocation order 5"},
+		{"Unknown interest method", with(func(c *Config) { c.InterestMethod = 5 }), "unknown interest method 5"},
+		{"Negative minor unit", with(func(c *Config) { c.MinorUnit = -1 }), "minor unit must not be negative"},
+		{"Negative payments before payoff", with(func(c *Config) { c.MinPaymentsBeforePayoff = -1 }), "minimum payments before payoff must not be negative"},
+		{"Payments before payoff beyond the term", with(func(c *Config) { c.MinPaymentsBeforePayoff = 51 }), "minimum payments before payoff must not exceed the total weeks"},
+		{"Negative catch-up cap", with(func(c *Config) { c.MaxCatchupInstallments = -1 }), "maximum catch-up installments must not be negative"},
+		{"Negative penalty rate", with(func(c *Config) { c.PenaltyRate = -0.01 }), "penalty rate must not be negative"},
+		{"Capitalized penalties without a rate", with(func(c *Config) { c.CapitalizePenalties = true }), "capitalizing penalties requires a penalty rate"},
+		{"Negative late fee", with(func(c *Config) { c.LateFee = -1 }), "late fee must not be negative"},
+		{"Waived late fee without a fee", with(func(c *Config) { c.WaiveFirstLateFee = true }), "waiving the first late fee requires a late fee"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.expectedError)
+
+			_, err = NewLoanValidated(WithLoanConfig(tt.config))
+			assert.EqualError(t, err, tt.expectedError, "NewLoanValidated should apply the same validation")
+		})
+	}
+
+	t.Run("Engine", func(t *testing.T) {
+		engine := NewEngine()
+		_, err := engine.CreateLoan(WithLoanID("loan1"), WithLoanConfig(with(func(c *Config) { c.LateFee = -1 })))
+		assert.EqualError(t, err, "late fee must not be negative")
+		_, err = engine.GetLoan("loan1")
+		assert.Error(t, err, "An invalid loan should not be stored")
+	})
+}
+
 func TestLoan_GetOutstanding(t *testing.T) {
 	loan := NewLoan(WithLoanConfig(Config{
 		Principal:    1000000,
@@ -107,21 +229,21 @@ func TestLoan_IsDelinquent(t *testing.T) {
 			expected: true,
 		},
 		{
-			name: "Last payment within two weeks",
+			name: "First installment paid, one overdue",
 			setupLoan: func() *Loan {
 				loan := NewLoan()
 				loan.MakePayment(loan.GetWeeklyPayment())
-				loan.payments[0].Date = time.Now().Add(-13 * 24 * time.Hour)
+				loan.startDate = time.Now().Add(-15 * 24 * time.Hour)
 				return loan
 			},
 			expected: false,
 		},
 		{
-			name: "Last payment over two weeks ago",
+			name: "First installment paid, two overdue",
 			setupLoan: func() *Loan {
 				loan := NewLoan()
 				loan.MakePayment(loan.GetWeeklyPayment())
-				loan.payments[0].Date = time.Now().Add(-15 * 24 * time.Hour)
+				loan.startDate = time.Now().Add(-22 * 24 * time.Hour)
 				return loan
 			},
 			expected: true,
@@ -233,8 +355,8 @@ func TestLoan_MakePayment(t *testing.T) {
 				}))
 				loan.startDate = time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour)
 				loan.payments = []Payment{
-					{Amount: 0, Date: time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour)},
-					{Amount: 0, Date: time.Now().Add(-2 * DaysPerWeek * HoursPerDay * time.Hour)},
+					{Amount: 0, Date: time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour), Installments: 1},
+					{Amount: 0, Date: time.Now().Add(-2 * DaysPerWeek * HoursPerDay * time.Hour), Installments: 1},
 				}
 				return loan
 			},
@@ -253,8 +375,8 @@ func TestLoan_MakePayment(t *testing.T) {
 				}))
 				loan.startDate = time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour)
 				loan.payments = []Payment{
-					{Amount: 0, Date: time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour)},
-					{Amount: 0, Date: time.Now().Add(-2 * DaysPerWeek * HoursPerDay * time.Hour)},
+					{Amount: 0, Date: time.Now().Add(-3 * DaysPerWeek * HoursPerDay * time.Hour), Installments: 1},
+					{Amount: 0, Date: time.Now().Add(-2 * DaysPerWeek * HoursPerDay * time.Hour), Installments: 1},
 				}
 				return loan
 			},
@@ -296,3 +418,1418 @@ func TestLoan_GetBillingSchedule(t *testing.T) {
 		assert.InDelta(t, 22000, payment, 0.01, "Each payment should be 22000")
 	}
 }
+
+func TestLoan_GetBillingSchedule_Cache(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	first := loan.billingSchedule()
+	second := loan.billingSchedule()
+	assert.Same(t, &first[0], &second[0], "Repeated calls should use the cached schedule")
+
+	returned := loan.GetBillingSchedule()
+	returned[0] = 0
+	assert.Equal(t, 22000.0, loan.GetBillingSchedule()[0], "Modifying the returned schedule should not corrupt the cache")
+
+	assert.NoError(t, loan.ChangeInterestRate(0.20, 10))
+
+	reamortized := loan.billingSchedule()
+	assert.NotSame(t, &first[0], &reamortized[0], "Cache should be invalidated by a rate change")
+	assert.InDelta(t, 22000, reamortized[9], 0.01)
+	assert.InDelta(t, 24000, reamortized[10], 0.01)
+}
+
+func TestLoan_ChangeInterestRate(t *testing.T) {
+	tests := []struct {
+		name          string
+		newRate       float64
+		effectiveWeek int
+		expectedError string
+	}{
+		{"Negative rate", -0.05, 10, "interest rate must not be negative"},
+		{"Effective week before start", 0.20, -1, "effective week must be between 0 and 49"},
+		{"Effective week after term", 0.20, 50, "effective week must be between 0 and 49"},
+		{"Valid rate change", 0.20, 10, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan(WithLoanConfig(Config{
+				Principal:    1000000,
+				InterestRate: 0.10,
+				TotalWeeks:   50,
+			}))
+
+			err := loan.ChangeInterestRate(tt.newRate, tt.effectiveWeek)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Equal(t, 0.10, loan.GetInterestRate())
+				assert.Equal(t, 1100000.0, loan.GetOutstanding())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.newRate, loan.GetInterestRate())
+
+			schedule := loan.GetBillingSchedule()
+			assert.Len(t, schedule, 50)
+			for week, payment := range schedule {
+				if week < tt.effectiveWeek {
+					assert.InDelta(t, 22000, payment, 0.01, "Installments before the effective week should be unchanged")
+				} else {
+					assert.InDelta(t, 24000, payment, 0.01, "Installments from the effective week should reflect the new rate")
+				}
+			}
+			assert.InDelta(t, 24000, loan.GetWeeklyPayment(), 0.01)
+			assert.InDelta(t, 1180000, loan.GetOutstanding(), 0.01)
+		})
+	}
+}
+
+func TestLoan_MakePartialPayment(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+		NegAmCap:     1.2,
+	}))
+
+	assert.False(t, loan.IsAtNegAmCap())
+	assert.NoError(t, loan.MakePartialPayment(10000), "Shortfall should be accepted below the cap")
+	assert.InDelta(t, 1090000, loan.GetOutstanding(), 0.01)
+
+	assert.NoError(t, loan.ChangeInterestRate(0.30, 0))
+	assert.True(t, loan.IsAtNegAmCap())
+
+	err := loan.MakePartialPayment(10000)
+	assert.EqualError(t, err, "outstanding has reached the negative amortization cap of 1200000.00")
+	assert.Len(t, loan.GetPayments(), 1, "Rejected payment should not be recorded")
+
+	assert.EqualError(t, loan.MakePartialPayment(0), "payment amount must be positive")
+}
+
+func TestLoan_SuspendResume(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	assert.Equal(t, clock.Now(), loan.GetStartDate(), "StartDate should come from the injected clock")
+
+	assert.NoError(t, loan.Suspend("borrower dispute"))
+	assert.Equal(t, Suspended, loan.GetStatus())
+	assert.EqualError(t, loan.Suspend("again"), "loan is already suspended")
+
+	clock.Advance(3 * DaysPerWeek * HoursPerDay * time.Hour)
+	assert.False(t, loan.IsDelinquent(), "No delinquency should accrue while suspended")
+
+	assert.NoError(t, loan.Resume())
+	assert.Equal(t, Active, loan.GetStatus(), "Pre-suspension status should be restored")
+	assert.False(t, loan.IsDelinquent(), "Loan should not be retroactively delinquent")
+	assert.EqualError(t, loan.Resume(), "loan is not suspended")
+
+	assert.NoError(t, loan.MakePayment(22000), "Suspended weeks should not count as missed payments")
+	assert.Equal(t, Active, loan.GetStatus())
+
+	clock.Advance(4 * DaysPerWeek * HoursPerDay * time.Hour)
+	assert.True(t, loan.IsDelinquent(), "Delinquency should accrue again after resuming")
+}
+
+func TestLoan_ScheduleVariance(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.MakePayment(22000))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	assert.NoError(t, loan.MakePartialPayment(11000))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	assert.NoError(t, loan.MakePayment(33000))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+
+	variance := loan.ScheduleVariance()
+
+	assert.Equal(t, []VarianceEntry{
+		{Week: 0, Planned: 22000, Actual: 22000, CumulativeVariance: 0},
+		{Week: 1, Planned: 22000, Actual: 11000, CumulativeVariance: -11000},
+		{Week: 2, Planned: 22000, Actual: 33000, CumulativeVariance: 0},
+		{Week: 3, Planned: 22000, Actual: 0, CumulativeVariance: -22000},
+	}, variance)
+}
+
+func TestLoan_AccruedInterestAsOf(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	assert.NoError(t, loan.MakePayment(22000))
+
+	day := HoursPerDay * time.Hour
+	oneDay := loan.AccruedInterestAsOf(clock.Now().Add(day))
+
+	assert.Equal(t, 0.0, loan.AccruedInterestAsOf(clock.Now()), "No interest accrues on the payment date")
+	assert.InDelta(t, 980000*0.10/365, oneDay, 0.01, "Interest accrues on the outstanding principal")
+	assert.InDelta(t, 3*oneDay, loan.AccruedInterestAsOf(clock.Now().Add(3*day)), 0.01)
+	assert.InDelta(t, 10*oneDay, loan.AccruedInterestAsOf(clock.Now().Add(10*day+time.Hour)), 0.01, "Partial days should not accrue")
+	assert.Equal(t, 1078000.0, loan.GetOutstanding(), "Accrued interest should not change the outstanding")
+}
+
+func TestLoan_TotalCostOfCredit(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected CostBreakdown
+	}{
+		{
+			name: "Base loan",
+			config: Config{
+				Principal:    1000000,
+				InterestRate: 0.10,
+				TotalWeeks:   50,
+			},
+			expected: CostBreakdown{Interest: 100000, Total: 100000},
+		},
+		{
+			name: "Loan with fees",
+			config: Config{
+				Principal:    1000000,
+				InterestRate: 0.10,
+				TotalWeeks:   50,
+				ServicingFee: 500,
+				UpfrontFee:   10000,
+			},
+			expected: CostBreakdown{Interest: 100000, ServicingFees: 25000, UpfrontFees: 10000, Total: 135000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan(WithLoanConfig(tt.config))
+			breakdown := loan.TotalCostOfCredit()
+
+			assert.InDelta(t, tt.expected.Interest, breakdown.Interest, 0.01)
+			assert.InDelta(t, tt.expected.ServicingFees, breakdown.ServicingFees, 0.01)
+			assert.InDelta(t, tt.expected.UpfrontFees, breakdown.UpfrontFees, 0.01)
+			assert.InDelta(t, tt.expected.Total, breakdown.Total, 0.01)
+		})
+	}
+}
+
+func TestLoan_SetStartDate(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	tests := []struct {
+		name           string
+		setupLoan      func(clock *mockClock) *Loan
+		startDate      func(clock *mockClock) time.Time
+		expectedError  string
+		expectedStatus LoanStatus
+	}{
+		{
+			name: "Earlier start makes loan delinquent",
+			setupLoan: func(clock *mockClock) *Loan {
+				return NewLoan(WithClock(clock))
+			},
+			startDate:      func(clock *mockClock) time.Time { return clock.Now().Add(-3 * week) },
+			expectedStatus: Delinquent,
+		},
+		{
+			name: "Earlier start within threshold stays active",
+			setupLoan: func(clock *mockClock) *Loan {
+				return NewLoan(WithClock(clock))
+			},
+			startDate:      func(clock *mockClock) time.Time { return clock.Now().Add(-1 * week) },
+			expectedStatus: Active,
+		},
+		{
+			name: "Future start date",
+			setupLoan: func(clock *mockClock) *Loan {
+				return NewLoan(WithClock(clock))
+			},
+			startDate:      func(clock *mockClock) time.Time { return clock.Now().Add(week) },
+			expectedError:  "start date must not be in the future",
+			expectedStatus: Active,
+		},
+		{
+			name: "Start date after first payment",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				clock.Advance(week)
+				return loan
+			},
+			startDate:      func(clock *mockClock) time.Time { return clock.Now() },
+			expectedError:  "start date must not be after the first payment",
+			expectedStatus: Active,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newMockClock()
+			loan := tt.setupLoan(clock)
+			payments := loan.GetPayments()
+			startDate := tt.startDate(clock)
+
+			err := loan.SetStartDate(startDate)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, startDate, loan.GetStartDate())
+			}
+
+			assert.Equal(t, payments, loan.GetPayments(), "Payments should be kept")
+			assert.Equal(t, tt.expectedStatus, loan.GetStatus())
+		})
+	}
+}
+
+func TestLoan_MinimumToBecomeCurrent(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	tests := []struct {
+		name      string
+		setupLoan func(clock *mockClock) *Loan
+		expected  float64
+	}{
+		{
+			name: "Two weeks behind",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				clock.Advance(week)
+				return loan
+			},
+			expected: 2 * 110000,
+		},
+		{
+			name: "Current installment due",
+			setupLoan: func(clock *mockClock) *Loan {
+				return NewLoan(WithClock(clock))
+			},
+			expected: 110000,
+		},
+		{
+			name: "Up to date",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				return loan
+			},
+			expected: 0,
+		},
+		{
+			name: "Capped at outstanding",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				loan.outstandingDebt = 50000
+				clock.Advance(week)
+				return loan
+			},
+			expected: 50000,
+		},
+		{
+			name: "Net of subsidy",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock), WithSubsidy(10000))
+				clock.Advance(week)
+				return loan
+			},
+			expected: 2 * 100000,
+		},
+		{
+			name: "Including charges",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.AddCharge("insurance", 500, true)
+				_ = loan.AddCharge("admin", 2000, false)
+				clock.Advance(week)
+				return loan
+			},
+			expected: 2*110000 + 2*500 + 2000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newMockClock()
+			loan := tt.setupLoan(clock)
+			outstanding, missed := loan.GetOutstanding(), loan.missedPayments()
+
+			minimum := loan.MinimumToBecomeCurrent()
+			assert.InDelta(t, tt.expected, minimum, 0.01)
+
+			if minimum > 0 && minimum < loan.GetOutstanding() {
+				assert.NoError(t, loan.MakePayment(minimum), "Paying the minimum should be accepted")
+				assert.InDelta(t, loan.GetWeeklyPayment()*float64(missed), outstanding-loan.GetOutstanding(), 0.01, "Paying the minimum should settle exactly the missed installments")
+				assert.False(t, loan.IsDelinquent())
+				assert.Equal(t, Active, loan.GetStatus())
+			}
+		})
+	}
+}
+
+func TestLoan_PaymentsWithBalance(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+		clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	}
+
+	entries := loan.PaymentsWithBalance()
+
+	assert.Len(t, entries, 3)
+	cumulative := 0.0
+	for i, entry := range entries {
+		cumulative += entry.Amount
+		assert.Equal(t, loan.GetPayments()[i], entry.Payment)
+		assert.InDelta(t, 1100000-cumulative, entry.BalanceAfter, 0.01)
+	}
+	assert.Equal(t, loan.GetOutstanding(), entries[2].BalanceAfter)
+}
+
+func TestLoan_PaymentsWithBalance_OtherDebtChanges(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithOverpaymentCredit(), WithGuarantor("guarantor", 100000), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.MakePayment(44000))
+	clock.Advance(HoursPerDay * time.Hour)
+	assert.NoError(t, loan.Adjust(5000, "goodwill"))
+	assert.NoError(t, loan.ApplyDiscount(3000, "promo"))
+	assert.NoError(t, loan.CallGuarantee(10000))
+	clock.Advance(week)
+	loan.RefreshStatus()
+	assert.Len(t, loan.creditApplications, 1)
+	clock.Advance(week)
+	assert.NoError(t, loan.MakePayment(22000))
+
+	entries := loan.PaymentsWithBalance()
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 22000.0, entries[0].Credit)
+	assert.InDelta(t, 1078000, entries[0].BalanceAfter, 0.01, "Only the part of the payment not held as credit should reduce the debt")
+	assert.InDelta(t, 1078000-5000-3000-10000-22000-22000, entries[1].BalanceAfter, 0.01)
+	assert.Equal(t, loan.GetOutstanding(), entries[1].BalanceAfter)
+}
+
+func TestLoan_WeeksPaidAhead(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	tests := []struct {
+		name          string
+		setupLoan     func(clock *mockClock) *Loan
+		expectedWeeks int
+	}{
+		{
+			name: "Ahead after paying two installments at start",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				return loan
+			},
+			expectedWeeks: 1,
+		},
+		{
+			name: "Ahead after paying three installments at start",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				for i := 0; i < 3; i++ {
+					_ = loan.MakePayment(loan.GetWeeklyPayment())
+				}
+				return loan
+			},
+			expectedWeeks: 2,
+		},
+		{
+			name: "On track",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				clock.Advance(week)
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				return loan
+			},
+			expectedWeeks: 0,
+		},
+		{
+			name: "Behind",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				clock.Advance(2 * week)
+				return loan
+			},
+			expectedWeeks: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := tt.setupLoan(newMockClock())
+
+			assert.Equal(t, tt.expectedWeeks, loan.WeeksPaidAhead())
+			assert.Equal(t, tt.expectedWeeks > 0, loan.IsPaidAhead())
+		})
+	}
+}
+
+func TestLoan_Adjust(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupLoan      func() *Loan
+		amount         float64
+		expectedError  string
+		expectedDebt   float64
+		expectedStatus LoanStatus
+	}{
+		{
+			name:           "Goodwill credit",
+			setupLoan:      func() *Loan { return NewLoan() },
+			amount:         50000,
+			expectedDebt:   5450000,
+			expectedStatus: Active,
+		},
+		{
+			name:           "Correction increasing the balance",
+			setupLoan:      func() *Loan { return NewLoan() },
+			amount:         -50000,
+			expectedDebt:   5550000,
+			expectedStatus: Active,
+		},
+		{
+			name: "Credit closing the loan",
+			setupLoan: func() *Loan {
+				loan := NewLoan()
+				loan.outstandingDebt = 50000
+				return loan
+			},
+			amount:         50000,
+			expectedDebt:   0,
+			expectedStatus: Closed,
+		},
+		{
+			name:           "Zero adjustment",
+			setupLoan:      func() *Loan { return NewLoan() },
+			amount:         0,
+			expectedError:  "adjustment amount must not be zero",
+			expectedDebt:   5500000,
+			expectedStatus: Active,
+		},
+		{
+			name:           "Credit exceeding the outstanding",
+			setupLoan:      func() *Loan { return NewLoan() },
+			amount:         6000000,
+			expectedError:  "adjustment must not exceed the outstanding debt",
+			expectedDebt:   5500000,
+			expectedStatus: Active,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := tt.setupLoan()
+			err := loan.Adjust(tt.amount, "goodwill")
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Empty(t, loan.GetAdjustments())
+			} else {
+				assert.NoError(t, err)
+				adjustments := loan.GetAdjustments()
+				assert.Len(t, adjustments, 1)
+				assert.Equal(t, tt.amount, adjustments[0].Amount)
+				assert.Equal(t, "goodwill", adjustments[0].Reason)
+			}
+
+			assert.InDelta(t, tt.expectedDebt, loan.GetOutstanding(), 0.01)
+			assert.Equal(t, tt.expectedStatus, loan.GetStatus())
+		})
+	}
+}
+
+func TestLoan_PayOff(t *testing.T) {
+	tests := []struct {
+		name             string
+		minPayments      int
+		previousPayments int
+		expectedError    string
+	}{
+		{"No lock-in", 0, 0, ""},
+		{"Blocked before lock-in", 3, 2, "loan cannot be paid off before 3 installments are paid"},
+		{"Allowed after lock-in", 3, 3, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan(WithLoanConfig(Config{
+				Principal:               1000000,
+				InterestRate:            0.10,
+				TotalWeeks:              50,
+				MinPaymentsBeforePayoff: tt.minPayments,
+			}))
+			for i := 0; i < tt.previousPayments; i++ {
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+			}
+			outstanding := loan.GetOutstanding()
+
+			ok, err := loan.CanPayOff()
+			payOffErr := loan.PayOff()
+
+			if tt.expectedError != "" {
+				assert.False(t, ok)
+				assert.EqualError(t, err, tt.expectedError)
+				assert.EqualError(t, payOffErr, tt.expectedError)
+				assert.Equal(t, outstanding, loan.GetOutstanding())
+				assert.Equal(t, Active, loan.GetStatus())
+			} else {
+				assert.True(t, ok)
+				assert.NoError(t, err)
+				assert.NoError(t, payOffErr)
+				assert.Equal(t, 0.0, loan.GetOutstanding())
+				assert.Equal(t, Closed, loan.GetStatus())
+				assert.Equal(t, outstanding, loan.GetPayments()[tt.previousPayments].Amount)
+			}
+		})
+	}
+
+	t.Run("Already paid off", func(t *testing.T) {
+		loan := NewLoan()
+		assert.NoError(t, loan.PayOff())
+		assert.EqualError(t, loan.PayOff(), "loan is already fully paid")
+	})
+}
+
+func TestLoan_AddReliefPeriod(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock))
+	start := clock.Now()
+	clock.Advance(3 * week)
+	assert.True(t, loan.IsDelinquent())
+
+	assert.EqualError(t, loan.AddReliefPeriod(start, start), "relief period must end after it starts")
+
+	assert.NoError(t, loan.AddReliefPeriod(start.Add(week), start.Add(3*week)))
+	assert.False(t, loan.IsDelinquent(), "Relief period should not count towards delinquency")
+	assert.Equal(t, Active, loan.GetStatus())
+	assert.Equal(t, 2, loan.missedPayments(), "Relief weeks should not count as missed payments")
+
+	assert.NoError(t, loan.Suspend("dispute"))
+	clock.Advance(week)
+	assert.NoError(t, loan.Resume())
+	assert.NoError(t, loan.AddReliefPeriod(start.Add(3*week), start.Add(4*week)))
+	assert.Equal(t, time.Duration(3*week), loan.excludedDuration(start, clock.Now()), "Overlapping periods should be counted once")
+}
+
+func TestLoan_RemainingTerm(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	tests := []struct {
+		name          string
+		setupLoan     func() *Loan
+		expectedWeeks int
+	}{
+		{
+			name:          "New loan",
+			setupLoan:     func() *Loan { return NewLoan() },
+			expectedWeeks: 50,
+		},
+		{
+			name: "Partially paid",
+			setupLoan: func() *Loan {
+				loan := NewLoan()
+				for i := 0; i < 3; i++ {
+					_ = loan.MakePayment(loan.GetWeeklyPayment())
+				}
+				return loan
+			},
+			expectedWeeks: 47,
+		},
+		{
+			name: "Partial installment outstanding",
+			setupLoan: func() *Loan {
+				loan := NewLoan()
+				_ = loan.MakePartialPayment(50000)
+				return loan
+			},
+			expectedWeeks: 50,
+		},
+		{
+			name: "Closed",
+			setupLoan: func() *Loan {
+				loan := NewLoan()
+				_ = loan.PayOff()
+				return loan
+			},
+			expectedWeeks: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := tt.setupLoan()
+
+			assert.Equal(t, tt.expectedWeeks, loan.RemainingWeeks())
+			assert.Equal(t, time.Duration(tt.expectedWeeks)*week, loan.RemainingTerm())
+		})
+	}
+}
+
+func TestLoan_RefreshStatus(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	tests := []struct {
+		name           string
+		setupLoan      func(clock *mockClock) *Loan
+		expectedStatus LoanStatus
+	}{
+		{
+			name: "First installment not yet overdue",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				clock.Advance(week)
+				return loan
+			},
+			expectedStatus: Active,
+		},
+		{
+			name: "No missed payments",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				clock.Advance(2 * week)
+				return loan
+			},
+			expectedStatus: Active,
+		},
+		{
+			name: "One missed payment",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				clock.Advance(2*week + time.Hour)
+				return loan
+			},
+			expectedStatus: PastDue,
+		},
+		{
+			name: "Two missed payments",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.MakePayment(loan.GetWeeklyPayment())
+				clock.Advance(3*week + time.Hour)
+				return loan
+			},
+			expectedStatus: Delinquent,
+		},
+		{
+			name: "Caught up after missed payments",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				clock.Advance(2*week + time.Hour)
+				loan.RefreshStatus()
+				_ = loan.MakePayment(loan.MinimumToBecomeCurrent())
+				return loan
+			},
+			expectedStatus: Active,
+		},
+		{
+			name: "Fully paid",
+			setupLoan: func(clock *mockClock) *Loan {
+				loan := NewLoan(WithClock(clock))
+				_ = loan.PayOff()
+				clock.Advance(3 * week)
+				return loan
+			},
+			expectedStatus: Closed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := tt.setupLoan(newMockClock())
+			loan.RefreshStatus()
+			assert.Equal(t, tt.expectedStatus, loan.GetStatus())
+		})
+	}
+}
+
+func TestLoanStatus_String(t *testing.T) {
+	assert.Equal(t, "Active", Active.String())
+	assert.Equal(t, "PastDue", PastDue.String())
+	assert.Equal(t, "Delinquent", Delinquent.String())
+	assert.Equal(t, "Closed", Closed.String())
+	assert.Equal(t, "Suspended", Suspended.String())
+	assert.Equal(t, "LoanStatus(99)", LoanStatus(99).String())
+}
+
+func TestLoan_PreviewCatchup(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	tests := []struct {
+		name              string
+		amount            float64
+		expectedWeeks     []int
+		expectedRemainder float64
+		expectedError     string
+	}{
+		{"Three overdue installments", 66000, []int{1, 2, 3}, 0, ""},
+		{"With remainder", 50000, []int{1, 2}, 6000, ""},
+		{"Less than one installment", 10000, nil, 10000, ""},
+		{"Zero amount", 0, nil, 0, "payment amount must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newMockClock()
+			loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+				Principal:    1000000,
+				InterestRate: 0.10,
+				TotalWeeks:   50,
+			}))
+			_ = loan.MakePayment(22000)
+			clock.Advance(2 * week)
+
+			weeks, remainder, err := loan.PreviewCatchup(tt.amount)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedWeeks, weeks)
+			assert.InDelta(t, tt.expectedRemainder, remainder, 0.01)
+			assert.Len(t, loan.GetPayments(), 1, "Preview should not record a payment")
+			assert.Equal(t, 1078000.0, loan.GetOutstanding())
+		})
+	}
+}
+
+func TestLoan_Progress(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.Equal(t, 0.0, loan.Progress())
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+	}
+	assert.InDelta(t, 10.0, loan.Progress(), 1e-9)
+
+	for i := 5; i < 50; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+	}
+	assert.Equal(t, Closed, loan.GetStatus())
+	assert.Equal(t, 100.0, loan.Progress())
+}
+
+func TestLoan_MakePayment_MaxCatchupInstallments(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:              1000000,
+		InterestRate:           0.10,
+		TotalWeeks:             50,
+		MaxCatchupInstallments: 2,
+	}))
+	clock.Advance(4*week + time.Hour)
+
+	assert.Equal(t, 5, loan.missedPayments())
+	assert.EqualError(t, loan.MakePayment(22000), "payment amount must be at least 44000.00 for 2 missed payments")
+
+	assert.NoError(t, loan.MakePayment(44000))
+	assert.Equal(t, 1056000.0, loan.GetOutstanding())
+	assert.Equal(t, Delinquent, loan.GetStatus(), "Status should reflect the remaining arrears")
+}
+
+func TestLoan_String(t *testing.T) {
+	loan := NewLoan(WithLoanID("loan1"), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	_ = loan.MakePayment(22000)
+
+	output := loan.String()
+
+	assert.Contains(t, output, "Loan loan1")
+	assert.Contains(t, output, "Principal:      1000000.00")
+	assert.Contains(t, output, "Interest rate:  10.00%")
+	assert.Contains(t, output, "Term:           50 weeks")
+	assert.Contains(t, output, "Weekly payment: 22000.00")
+	assert.Contains(t, output, "Outstanding:    1078000.00")
+	assert.Contains(t, output, "Status:         Active")
+	assert.Contains(t, output, "Payments made:  1")
+}
+
+func TestLoan_WithAlignedFirstPayment(t *testing.T) {
+	// newMockClock starts on Monday 2024-01-01; aligning to Thursday leaves a 3-day stub
+	loan := NewLoan(WithClock(newMockClock()), WithAlignedFirstPayment(time.Thursday), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+		MinorUnit:    1,
+	}))
+
+	schedule := loan.GetBillingSchedule()
+
+	assert.Equal(t, 9429.0, schedule[0], "First installment should cover 3 of 7 days")
+	for week := 1; week < 49; week++ {
+		assert.Equal(t, 22000.0, schedule[week])
+	}
+
+	total := 0.0
+	for _, installment := range schedule {
+		total += installment
+	}
+	assert.InDelta(t, 1100000, total, 1e-6, "Schedule should still sum to the total owed")
+
+	assert.Error(t, loan.MakePayment(9000))
+	assert.NoError(t, loan.MakePayment(9429))
+	assert.Equal(t, 1090571.0, loan.GetOutstanding())
+}
+
+func TestLoan_WithAlignedFirstPayment_OnBillingDay(t *testing.T) {
+	loan := NewLoan(WithClock(newMockClock()), WithAlignedFirstPayment(time.Monday), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.Equal(t, 22000.0, loan.GetBillingSchedule()[0], "No stub when starting on the billing day")
+}
+
+func TestLoan_DaysPastDue(t *testing.T) {
+	day := HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.Equal(t, 0, loan.DaysPastDue(), "New loan should not be past due")
+
+	clock.Advance(7 * day)
+	assert.Equal(t, 0, loan.DaysPastDue(), "First installment is due at the end of week 0")
+
+	clock.Advance(3 * day)
+	assert.Equal(t, 3, loan.DaysPastDue())
+
+	assert.NoError(t, loan.MakePayment(44000))
+	assert.Equal(t, 0, loan.DaysPastDue(), "Catching up should clear days past due")
+
+	clock.Advance(30 * day)
+	assert.Equal(t, 19, loan.DaysPastDue(), "Oldest unpaid installment was due on day 21")
+}
+
+func TestLoan_NextNInstallments(t *testing.T) {
+	clock := newMockClock()
+	start := clock.Now()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    100000,
+		InterestRate: 0.10,
+		TotalWeeks:   5,
+	}))
+
+	_, err := loan.NextNInstallments(0)
+	assert.EqualError(t, err, "n must be positive")
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+	}
+	clock.Advance(30 * HoursPerDay * time.Hour)
+
+	entries, err := loan.NextNInstallments(10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ScheduleEntry{
+		{Week: 3, DueDate: start.AddDate(0, 0, 28), Amount: 22000, Overdue: true},
+		{Week: 4, DueDate: start.AddDate(0, 0, 35), Amount: 22000, Overdue: false},
+	}, entries)
+
+	entries, _ = loan.NextNInstallments(1)
+	assert.Len(t, entries, 1)
+}
+
+func TestLoan_RescheduleInstallment(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	assert.NoError(t, loan.MakePayment(22000))
+
+	clock.Advance(2*week + HoursPerDay*time.Hour)
+	loan.RefreshStatus()
+	assert.Equal(t, PastDue, loan.GetStatus())
+	assert.Equal(t, 2, loan.missedPayments())
+
+	assert.EqualError(t, loan.RescheduleInstallment(0), "installment for week 0 is not unpaid")
+	assert.EqualError(t, loan.RescheduleInstallment(3), "installment for week 3 is not overdue")
+
+	assert.NoError(t, loan.RescheduleInstallment(1))
+
+	assert.Equal(t, 51, loan.GetTotalWeeks(), "Term should grow by one week")
+	assert.Equal(t, 1, loan.missedPayments(), "Deferred installment should no longer count as missed")
+	assert.False(t, loan.IsDelinquent())
+	assert.Equal(t, Active, loan.GetStatus())
+
+	schedule := loan.GetBillingSchedule()
+	assert.Len(t, schedule, 51)
+	assert.Equal(t, 0.0, schedule[1])
+	assert.Equal(t, 22000.0, schedule[50])
+	total := 0.0
+	for _, installment := range schedule {
+		total += installment
+	}
+	assert.InDelta(t, 1100000, total, 1e-6, "Rescheduling should not change the total owed")
+
+	assert.NoError(t, loan.MakePayment(22000), "Only week 2 should be due now")
+	assert.Equal(t, 0, loan.missedPayments())
+}
+
+func TestLoan_PaymentToCloseByWeek(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+		clock.Advance(week)
+	}
+
+	_, err := loan.PaymentToCloseByWeek(10)
+	assert.EqualError(t, err, "week must be after the current week 10 and before 50")
+	_, err = loan.PaymentToCloseByWeek(50)
+	assert.Error(t, err)
+
+	// Weeks 10-29 are paid in full; weeks 30-49 repay principal only
+	amount, err := loan.PaymentToCloseByWeek(29)
+	assert.NoError(t, err)
+	assert.InDelta(t, 20*22000+20*20000, amount, 1e-6)
+
+	assert.NoError(t, loan.CloseByWeek(29))
+	assert.Equal(t, 0.0, loan.GetOutstanding())
+	assert.Equal(t, Closed, loan.GetStatus())
+	assert.InDelta(t, amount, loan.GetPayments()[10].Amount, 1e-6)
+	assert.InDelta(t, 40000, loan.GetAdjustments()[0].Amount, 1e-6, "Interest after week 29 should be rebated")
+}
+
+func TestLoan_WithRepayableAndInstallment(t *testing.T) {
+	loan, err := NewLoanValidated(
+		WithLoanConfig(Config{Principal: 1, InterestRate: 0.10, TotalWeeks: 1}),
+		WithRepayableAndInstallment(1100000, 30000),
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 37, loan.GetTotalWeeks(), "ceil(1100000 / 30000) installments are needed")
+	assert.InDelta(t, 1000000, loan.GetPrincipal(), 1e-6)
+	assert.Equal(t, 1100000.0, loan.GetOutstanding())
+
+	schedule := loan.GetBillingSchedule()
+	total := 0.0
+	for _, installment := range schedule {
+		total += installment
+	}
+	assert.InDelta(t, 1100000, total, 1e-6)
+	assert.Equal(t, 30000.0, schedule[0])
+	assert.InDelta(t, 20000, schedule[36], 1e-6, "Final installment should be the remainder")
+}
+
+func TestLoan_WithRepayableAndInstallment_Invalid(t *testing.T) {
+	_, err := NewLoanValidated(WithRepayableAndInstallment(0, 30000))
+	assert.EqualError(t, err, "repayable total and installment must be positive")
+
+	_, err = NewLoanValidated(WithRepayableAndInstallment(10000, 30000))
+	assert.EqualError(t, err, "installment must not exceed the repayable total")
+}
+
+func TestLoan_ToMap(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithLoanID("loan1"), WithClock(clock), WithBorrowerID("borrower1"), WithPurpose(Business), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	_ = loan.MakePayment(22000)
+
+	fields := loan.ToMap()
+
+	assert.Equal(t, map[string]interface{}{
+		"ID":             "loan1",
+		"BorrowerID":     "borrower1",
+		"Purpose":        "Business",
+		"ProductType":    "",
+		"Currency":       "IDR",
+		"Principal":      "1000000.00",
+		"InterestRate":   "10.00%",
+		"TotalWeeks":     50,
+		"WeeklyPayment":  "22000.00",
+		"StartDate":      "2024-01-01",
+		"Outstanding":    "1078000.00",
+		"CarryingValue":  "1078000.00",
+		"Status":         "Active",
+		"PaymentCount":   1,
+		"RemainingWeeks": 49,
+		"AmountDue":      "22000.00",
+		"Progress":       "2.0%",
+	}, fields)
+
+	tmpl := template.Must(template.New("statement").Parse("{{.ID}} owes {{.Outstanding}} ({{.Status}})"))
+	var out strings.Builder
+	assert.NoError(t, tmpl.Execute(&out, fields))
+	assert.Equal(t, "loan1 owes 1078000.00 (Active)", out.String())
+}
+
+func TestLoan_SimulateRateChange(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	deltaInterest, newInstallment, err := loan.SimulateRateChange(0.20, 25)
+
+	assert.NoError(t, err)
+	assert.InDelta(t, 50000, deltaInterest, 1e-6)
+	assert.InDelta(t, 24000, newInstallment, 1e-6)
+	assert.Greater(t, newInstallment, loan.GetWeeklyPayment())
+	assert.Equal(t, 1100000.0, loan.GetOutstanding(), "Simulation should not change the loan")
+	assert.Equal(t, 0.10, loan.GetInterestRate())
+	assert.Equal(t, 22000.0, loan.GetBillingSchedule()[49])
+
+	assert.NoError(t, loan.ChangeInterestRate(0.20, 25))
+	assert.InDelta(t, 1100000+deltaInterest, loan.GetOutstanding(), 1e-6, "Simulation should match the applied change")
+
+	_, _, err = loan.SimulateRateChange(-0.1, 25)
+	assert.EqualError(t, err, "interest rate must not be negative")
+	_, _, err = loan.SimulateRateChange(0.1, 50)
+	assert.Error(t, err)
+}
+
+func TestLoan_OriginationFee(t *testing.T) {
+	config := Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}
+	withoutFee := NewLoan(WithLoanConfig(config))
+
+	config.OriginationFee = 50000
+	withFee, err := NewLoanValidated(WithLoanConfig(config))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1050000.0, withFee.GetPrincipal(), "Fee should be financed into the principal")
+	assert.Equal(t, 23100.0, withFee.GetWeeklyPayment())
+	assert.Greater(t, withFee.GetWeeklyPayment(), withoutFee.GetWeeklyPayment())
+
+	breakdown := withFee.TotalCostOfCredit()
+	assert.InDelta(t, 105000, breakdown.Interest, 1e-6)
+	assert.Greater(t, breakdown.Interest, withoutFee.TotalCostOfCredit().Interest)
+	assert.Equal(t, 50000.0, breakdown.OriginationFees)
+	assert.InDelta(t, 155000, breakdown.Total, 1e-6)
+
+	config.OriginationFee = -1
+	_, err = NewLoanValidated(WithLoanConfig(config))
+	assert.EqualError(t, err, "origination fee must not be negative")
+}
+
+func TestLoan_IsModifiable(t *testing.T) {
+	tests := []struct {
+		status     LoanStatus
+		modifiable bool
+	}{
+		{Active, true},
+		{PastDue, true},
+		{Delinquent, false},
+		{Suspended, false},
+		{Closed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status.String(), func(t *testing.T) {
+			loan := NewLoan()
+			loan.status = tt.status
+
+			assert.Equal(t, tt.modifiable, loan.IsModifiable())
+
+			err := loan.ChangeInterestRate(0.20, 10)
+			_, _, simulateErr := loan.SimulateRateChange(0.20, 10)
+			if tt.modifiable {
+				assert.NoError(t, err)
+				assert.NoError(t, simulateErr)
+			} else {
+				assert.Equal(t, ErrNotModifiable, err)
+				assert.Equal(t, ErrNotModifiable, simulateErr)
+			}
+		})
+	}
+}
+
+func TestLoan_CatchupSettlesEveryInstallment(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:               1000000,
+		InterestRate:            0.10,
+		TotalWeeks:              50,
+		MinPaymentsBeforePayoff: 3,
+	}))
+
+	clock.Advance(2*week + 24*time.Hour)
+	assert.NoError(t, loan.MakePayment(66000))
+
+	assert.Equal(t, 3, loan.GetPayments()[0].Installments, "One payment should settle all three missed installments")
+	assert.Equal(t, 0, loan.missedPayments())
+	assert.Equal(t, 0, loan.DaysPastDue())
+
+	weeks, _, err := loan.PreviewCatchup(22000)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, weeks, "Preview should start after the installments already caught up")
+
+	ok, err := loan.CanPayOff()
+	assert.True(t, ok, "Every installment settled by the catch-up counts towards the lock-in")
+	assert.NoError(t, err)
+
+	history := loan.DPDHistory()
+	assert.Equal(t, 0, history[len(history)-1].DaysPastDue)
+	assert.InDelta(t, 75.0, loan.TimelinessScore(), 1e-9, "Weeks 0 and 1 were paid late and week 2 on time")
+
+	clock.Advance(2 * week)
+	assert.Equal(t, 1, loan.DaysPastDue(), "Week 3 was due on day 28")
+}
+
+func TestLoan_DPDHistory(t *testing.T) {
+	day := HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	assert.NoError(t, loan.MakePayment(22000))
+	clock.Advance(20 * day)
+	// Lapse: week 1 was due on day 14 and is caught up on day 20
+	assert.NoError(t, loan.MakePayment(44000))
+	clock.Advance(day)
+
+	history := loan.DPDHistory()
+
+	assert.Len(t, history, 22)
+	assert.Equal(t, clock.Now(), history[21].Date)
+	assert.Equal(t, 0, history[14].DaysPastDue)
+	assert.Equal(t, 5, history[19].DaysPastDue, "DPD should rise during the lapse")
+	assert.Equal(t, 0, history[20].DaysPastDue, "DPD should fall once caught up")
+	assert.Equal(t, loan.DaysPastDue(), history[21].DaysPastDue)
+}
+
+func TestLoan_Immutable(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithLoanID("imported"), WithClock(clock), WithImmutable())
+
+	assert.True(t, loan.IsImmutable())
+	assert.False(t, NewLoan().IsImmutable())
+
+	assert.Equal(t, "imported", loan.GetID())
+	assert.Equal(t, 5500000.0, loan.GetOutstanding())
+	assert.Equal(t, Active, loan.GetStatus())
+	assert.Len(t, loan.GetBillingSchedule(), 50)
+	assert.Equal(t, 0, loan.DaysPastDue())
+
+	assert.ErrorIs(t, loan.MakePayment(110000), ErrLoanImmutable)
+	assert.ErrorIs(t, loan.MakePartialPayment(1000), ErrLoanImmutable)
+	assert.ErrorIs(t, loan.ChangeInterestRate(0.2, 1), ErrLoanImmutable)
+	assert.ErrorIs(t, loan.Adjust(-1000, "goodwill"), ErrLoanImmutable)
+	assert.ErrorIs(t, loan.Suspend("dispute"), ErrLoanImmutable)
+	assert.ErrorIs(t, loan.SetStartDate(clock.Now()), ErrLoanImmutable)
+	assert.ErrorIs(t, loan.PayOff(), ErrLoanImmutable)
+
+	assert.Equal(t, 5500000.0, loan.GetOutstanding(), "Rejected writes should leave the loan untouched")
+	assert.Empty(t, loan.GetPayments())
+}
+
+func TestLoan_ProjectWithLumpSums(t *testing.T) {
+	loan := NewLoan(WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   10,
+	}))
+	assert.NoError(t, loan.MakePayment(110000))
+
+	entries, weeks := loan.ProjectWithLumpSums(nil)
+	assert.Equal(t, 9, weeks, "Without lump sums the projection follows the schedule")
+	assert.Len(t, entries, 9)
+
+	entries, weeks = loan.ProjectWithLumpSums(map[int]float64{0: 500000, 4: 330000, 20: 100000})
+
+	assert.Equal(t, 6, weeks, "A mid-term lump sum should shorten the payoff")
+	assert.Equal(t, 1, entries[0].Week)
+	assert.Equal(t, 440000.0, entries[3].Amount)
+	assert.Equal(t, 6, entries[5].Week)
+	assert.Equal(t, 110000.0, entries[5].Amount)
+
+	paid := 0.0
+	for _, entry := range entries {
+		paid += entry.Amount
+	}
+	assert.InDelta(t, loan.GetOutstanding(), paid, 1e-6, "The projected balance should reach zero at payoff")
+
+	assert.Equal(t, 990000.0, loan.GetOutstanding(), "Projecting should not change the loan")
+	assert.Len(t, loan.GetPayments(), 1)
+}
+
+func TestLoan_MaturityDate(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	start := clock.Now()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    100000,
+		InterestRate: 0.10,
+		TotalWeeks:   5,
+	}))
+
+	assert.Equal(t, start.AddDate(0, 0, 35), loan.MaturityDate())
+
+	clock.Advance(week)
+	assert.NoError(t, loan.RescheduleInstallment(0))
+	assert.Equal(t, start.AddDate(0, 0, 42), loan.MaturityDate(), "A deferral should extend the term by a week")
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, loan.MakePayment(loan.amountDue()))
+		clock.Advance(week)
+	}
+	assert.Equal(t, Closed, loan.GetStatus())
+	assert.Equal(t, start.Add(5*week), loan.MaturityDate(), "A closed loan should mature on its last payment")
+}
+
+func TestLoan_TopUpAfterRateChange(t *testing.T) {
+	loan := NewLoan(WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+	}
+	assert.Equal(t, 3, loan.WeeksPaidAhead())
+	assert.Equal(t, 0.0, loan.TopUpAfterRateChange(), "No top-up is needed before the rate changes")
+
+	assert.NoError(t, loan.ChangeInterestRate(0.20, 2))
+
+	// Weeks 2 and 3 were paid at 22000 but now cost 24000 each
+	assert.InDelta(t, 4000, loan.TopUpAfterRateChange(), 1e-6)
+
+	assert.NoError(t, loan.ChangeInterestRate(0.05, 2))
+	assert.Equal(t, 0.0, loan.TopUpAfterRateChange(), "A rate decrease should need no top-up")
+}
+
+func TestLoan_WeeksElapsed(t *testing.T) {
+	day := HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock))
+
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		expected int
+	}{
+		{"At origination", 0, 0},
+		{"Within the first week", 6 * day, 0},
+		{"After one week", day, 1},
+		{"After several weeks", 4*DaysPerWeek*day + 3*day, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Advance(tt.advance)
+			assert.Equal(t, tt.expected, loan.WeeksElapsed())
+		})
+	}
+}
+
+func TestLoan_ReversePaymentAt(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+		clock.Advance(week)
+	}
+	middle := loan.GetPayments()[1]
+	before := loan.GetOutstanding()
+
+	_, err := loan.ReversePaymentAt(3)
+	assert.EqualError(t, err, "payment index 3 is out of range")
+	_, err = loan.ReversePaymentAt(-1)
+	assert.Error(t, err)
+
+	removed, err := loan.ReversePaymentAt(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, middle, removed)
+	assert.Equal(t, before+middle.Amount, loan.GetOutstanding(), "The balance should increase by exactly the reversed amount")
+	assert.Len(t, loan.GetPayments(), 2)
+	assert.Equal(t, clock.Now().Add(-week), loan.GetPayments()[1].Date, "Later payments should be kept")
+	assert.Equal(t, 2, loan.missedPayments(), "The status should reflect the remaining history")
+	assert.Equal(t, Active, loan.GetStatus())
+}
+
+func TestLoan_TimelinessScore(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+
+	flawlessClock := newMockClock()
+	flawless := NewLoan(WithClock(flawlessClock), WithLoanConfig(config))
+	assert.Equal(t, 100.0, flawless.TimelinessScore(), "A new loan should have a perfect score")
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, flawless.MakePayment(22000))
+		flawlessClock.Advance(week)
+	}
+
+	missingClock := newMockClock()
+	missing := NewLoan(WithClock(missingClock), WithLoanConfig(config))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, missing.MakePayment(22000))
+		missingClock.Advance(week)
+	}
+	missingClock.Advance(2 * week)
+
+	assert.Equal(t, 100.0, flawless.TimelinessScore())
+	// Weeks 0 and 1 were on time and week 2, weighted 3, was missed
+	assert.InDelta(t, 50, missing.TimelinessScore(), 1e-9, "Recent misses should lower the score")
+}
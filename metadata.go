@@ -0,0 +1,77 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ExternalRefKey is the metadata key holding a loan's external reference, such
+// as the identifier the loan has in an upstream system
+const ExternalRefKey = "external_ref"
+
+// SetMetadata stores a free-form key/value pair on the loan. A loan created
+// WithImmutable keeps the metadata it has.
+func (l *Loan) SetMetadata(key, value string) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if l.metadata == nil {
+		l.metadata = make(map[string]string)
+	}
+	l.metadata[key] = value
+	return nil
+}
+
+// GetMetadata returns the metadata value stored under key
+func (l *Loan) GetMetadata(key string) (string, bool) {
+	value, ok := l.metadata[key]
+	return value, ok
+}
+
+// ExternalRef returns the loan's external reference, or "" if none is set
+func (l *Loan) ExternalRef() string {
+	return l.metadata[ExternalRefKey]
+}
+
+// GetLoanByRef retrieves a loan by its external reference
+func (e *Engine) GetLoanByRef(ref string) (*Loan, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	id, exists := e.refs[ref]
+	if !exists {
+		return nil, errors.New("loan not found")
+	}
+	return e.loans[id], nil
+}
+
+// RebuildRefIndex rebuilds the external reference index from the refs currently
+// stored in each loan's metadata. If two loans share a ref, an error is returned
+// and the existing index is left untouched.
+func (e *Engine) RebuildRefIndex() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	ids := make([]string, 0, len(e.loans))
+	for id := range e.loans {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	refs := make(map[string]string)
+	for _, id := range ids {
+		ref := e.loans[id].ExternalRef()
+		if ref == "" {
+			continue
+		}
+		if other, exists := refs[ref]; exists {
+			return fmt.Errorf("duplicate external ref %q on loans %s and %s", ref, other, id)
+		}
+		refs[ref] = id
+	}
+
+	e.refs = refs
+	return nil
+}
@@ -0,0 +1,53 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_RebuildRefIndex(t *testing.T) {
+	engine := NewEngine()
+	first, _ := engine.CreateLoan(WithLoanID("loan-a"))
+	second, _ := engine.CreateLoan(WithLoanID("loan-b"))
+
+	assert.NoError(t, first.SetMetadata(ExternalRefKey, "ext-1"))
+	assert.NoError(t, second.SetMetadata(ExternalRefKey, "ext-2"))
+
+	_, err := engine.GetLoanByRef("ext-1")
+	assert.EqualError(t, err, "loan not found", "Index should be stale before rebuilding")
+
+	assert.NoError(t, engine.RebuildRefIndex())
+
+	loan, err := engine.GetLoanByRef("ext-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "loan-a", loan.GetID())
+
+	loan, err = engine.GetLoanByRef("ext-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "loan-b", loan.GetID())
+}
+
+func TestEngine_RebuildRefIndex_Duplicate(t *testing.T) {
+	engine := NewEngine()
+	first, _ := engine.CreateLoan(WithLoanID("loan-a"))
+	second, _ := engine.CreateLoan(WithLoanID("loan-b"))
+
+	assert.NoError(t, first.SetMetadata(ExternalRefKey, "ext-1"))
+	assert.NoError(t, engine.RebuildRefIndex())
+
+	assert.NoError(t, second.SetMetadata(ExternalRefKey, "ext-1"))
+	assert.EqualError(t, engine.RebuildRefIndex(), `duplicate external ref "ext-1" on loans loan-a and loan-b`)
+
+	loan, err := engine.GetLoanByRef("ext-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "loan-a", loan.GetID(), "Failed rebuild should keep the previous index")
+}
+
+func TestLoan_SetMetadata_Immutable(t *testing.T) {
+	loan := NewLoan(WithImmutable())
+
+	assert.ErrorIs(t, loan.SetMetadata(ExternalRefKey, "ext-1"), ErrLoanImmutable)
+	_, ok := loan.GetMetadata(ExternalRefKey)
+	assert.False(t, ok, "An immutable loan should keep its metadata")
+}
@@ -0,0 +1,115 @@
+package billing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// EngineStats summarizes the loans held by an engine. Balances are kept per
+// currency, keyed by ISO 4217 code, since they cannot be summed across currencies.
+type EngineStats struct {
+	TotalLoans       int
+	ActiveLoans      int
+	PastDueLoans     int
+	DelinquentLoans  int
+	SuspendedLoans   int
+	ClosedLoans      int
+	TotalOutstanding map[string]float64
+}
+
+// Stats counts the engine's loans by status and totals their outstanding
+// balance in each currency
+func (e *Engine) Stats() EngineStats {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	stats := EngineStats{TotalLoans: len(e.loans), TotalOutstanding: make(map[string]float64)}
+	for _, loan := range e.loans {
+		switch loan.GetStatus() {
+		case Active:
+			stats.ActiveLoans++
+		case PastDue:
+			stats.PastDueLoans++
+		case Delinquent:
+			stats.DelinquentLoans++
+		case Suspended:
+			stats.SuspendedLoans++
+		case Closed:
+			stats.ClosedLoans++
+		}
+		stats.TotalOutstanding[loan.currency] += loan.GetOutstanding()
+	}
+
+	return stats
+}
+
+// OutstandingByStatus totals the outstanding balance of the engine's loans by
+// currency and then by status
+func (e *Engine) OutstandingByStatus() map[string]map[LoanStatus]float64 {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	totals := make(map[string]map[LoanStatus]float64)
+	for _, loan := range e.loans {
+		byStatus, ok := totals[loan.currency]
+		if !ok {
+			byStatus = make(map[LoanStatus]float64)
+			totals[loan.currency] = byStatus
+		}
+		byStatus[loan.GetStatus()] += loan.GetOutstanding()
+	}
+
+	return totals
+}
+
+// WritePrometheus writes the engine's Stats to w as gauges in the Prometheus
+// text exposition format. The outstanding total has one sample per currency,
+// labelled with its code.
+func (e *Engine) WritePrometheus(w io.Writer) error {
+	stats := e.Stats()
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"billing_loans_total", "Number of loans held by the engine.", float64(stats.TotalLoans)},
+		{"billing_loans_active", "Number of active loans.", float64(stats.ActiveLoans)},
+		{"billing_loans_past_due", "Number of past due loans.", float64(stats.PastDueLoans)},
+		{"billing_loans_delinquent", "Number of delinquent loans.", float64(stats.DelinquentLoans)},
+		{"billing_loans_suspended", "Number of suspended loans.", float64(stats.SuspendedLoans)},
+		{"billing_loans_closed", "Number of closed loans.", float64(stats.ClosedLoans)},
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, gauge := range gauges {
+		writeGaugeHeader(buf, gauge.name, gauge.help)
+		fmt.Fprintf(buf, "%s %s\n", gauge.name, formatSample(gauge.value))
+	}
+
+	currencies := make([]string, 0, len(stats.TotalOutstanding))
+	for currency := range stats.TotalOutstanding {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	writeGaugeHeader(buf, "billing_outstanding_total", "Total outstanding balance across all loans, by currency.")
+	for _, currency := range currencies {
+		fmt.Fprintf(buf, "billing_outstanding_total{currency=%q} %s\n", currency, formatSample(stats.TotalOutstanding[currency]))
+	}
+
+	return buf.Flush()
+}
+
+// writeGaugeHeader writes the HELP and TYPE lines of a gauge
+func writeGaugeHeader(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+}
+
+// formatSample formats a sample value without an exponent
+func formatSample(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,79 @@
+package billing
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Stats(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("active"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	delinquent, _ := engine.CreateLoan(WithLoanID("delinquent"), WithLoanConfig(Config{Principal: 2000000, InterestRate: 0.10, TotalWeeks: 50}))
+	delinquent.status = Delinquent
+	closed, _ := engine.CreateLoan(WithLoanID("closed"))
+	closed.status = Closed
+	closed.outstandingDebt = 0
+
+	stats := engine.Stats()
+
+	assert.Equal(t, EngineStats{
+		TotalLoans:       3,
+		ActiveLoans:      1,
+		DelinquentLoans:  1,
+		ClosedLoans:      1,
+		TotalOutstanding: map[string]float64{"IDR": 3300000},
+	}, stats)
+}
+
+func TestEngine_MultiCurrency(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("idr1"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	idr2, _ := engine.CreateLoan(WithLoanID("idr2"), WithCurrency("IDR"), WithLoanConfig(Config{Principal: 2000000, InterestRate: 0.10, TotalWeeks: 50}))
+	idr2.status = Delinquent
+	usd, _ := engine.CreateLoan(WithLoanID("usd"), WithCurrency("USD"), WithLoanConfig(Config{Principal: 1000, InterestRate: 0.10, TotalWeeks: 50}))
+
+	assert.Equal(t, "USD", usd.GetCurrency())
+	assert.Equal(t, "USD", usd.ToStructuredRecord().Principal.Currency)
+
+	stats := engine.Stats()
+	assert.Equal(t, map[string]float64{"IDR": 3300000, "USD": 1100}, stats.TotalOutstanding,
+		"Balances should not be summed across currencies")
+
+	assert.Equal(t, map[string]map[LoanStatus]float64{
+		"IDR": {Active: 1100000, Delinquent: 2200000},
+		"USD": {Active: 1100},
+	}, engine.OutstandingByStatus())
+
+	_, _, err := engine.DistributePayment([]string{"idr1", "usd"}, 100000, HighestRateFirst)
+	assert.EqualError(t, err, "loans must share a currency: usd is in USD, not IDR")
+	assert.Empty(t, usd.GetPayments())
+}
+
+func TestEngine_WritePrometheus(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("active"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	delinquent, _ := engine.CreateLoan(WithLoanID("delinquent"), WithLoanConfig(Config{Principal: 500000, InterestRate: 0.10, TotalWeeks: 50}))
+	delinquent.status = Delinquent
+	_, _ = engine.CreateLoan(WithLoanID("usd"), WithCurrency("USD"), WithLoanConfig(Config{Principal: 1000, InterestRate: 0.10, TotalWeeks: 50}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, engine.WritePrometheus(&buf))
+	output := buf.String()
+
+	assert.Contains(t, output, "billing_loans_total 3\n")
+	assert.Contains(t, output, "billing_loans_delinquent 1\n")
+	assert.Contains(t, output, "billing_outstanding_total{currency=\"IDR\"} 1650000\n")
+	assert.Contains(t, output, "billing_outstanding_total{currency=\"USD\"} 1100\n")
+	assert.Contains(t, output, "# HELP billing_loans_total ")
+	assert.Contains(t, output, "# TYPE billing_outstanding_total gauge\n")
+
+	sample := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{[a-zA-Z_][a-zA-Z0-9_]*="[^"]*"\})? [0-9.eE+-]+$`)
+	comment := regexp.MustCompile(`^# (HELP [a-zA-Z_:][a-zA-Z0-9_:]* .+|TYPE [a-zA-Z_:][a-zA-Z0-9_:]* gauge)$`)
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		assert.True(t, sample.MatchString(line) || comment.MatchString(line), "invalid line %q", line)
+	}
+}
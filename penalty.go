@@ -0,0 +1,86 @@
+package billing
+
+// GetCapitalizedPenalties returns the penalty interest that has been added to
+// the outstanding debt under CapitalizePenalties
+func (l *Loan) GetCapitalizedPenalties() float64 {
+	return l.capitalizedPenalties
+}
+
+// GetPenaltyFees returns the penalty interest tracked separately from the
+// outstanding debt when penalties are not capitalized
+func (l *Loan) GetPenaltyFees() float64 {
+	return l.penaltyFees
+}
+
+// accruePenalties charges penalty interest for every weekly cycle that has
+// started since the last accrual. Each cycle charges a week of PenaltyRate on
+// the installments overdue at its start and, when penalties are capitalized,
+// on the penalties capitalized so far.
+func (l *Loan) accruePenalties() {
+	currentWeek := l.activeWeekAsOf(l.clock.Now())
+	if l.penaltyRate <= 0 || l.outstandingDebt <= 0 {
+		l.penaltyCycles = currentWeek
+		return
+	}
+
+	settled := l.settledInstallments()
+	for cycle := l.penaltyCycles + 1; cycle <= currentWeek; cycle++ {
+		base := 0.0
+		for week := settled; week < cycle && week < l.totalWeeks; week++ {
+			base += l.installmentForWeek(week)
+		}
+		if base == 0 {
+			continue
+		}
+
+		if l.capitalizePenalties {
+			penalty := (base + l.capitalizedPenalties) * l.penaltyRate / WeeksPerYear
+			l.capitalizedPenalties += penalty
+			l.outstandingDebt += penalty
+		} else {
+			l.penaltyFees += base * l.penaltyRate / WeeksPerYear
+		}
+	}
+
+	if currentWeek > l.penaltyCycles {
+		l.penaltyCycles = currentWeek
+	}
+}
+
+// GetLateFees returns the late fees charged so far, not counting waived fees
+func (l *Loan) GetLateFees() float64 {
+	return l.lateFees
+}
+
+// WaivedFeesTotal returns the late fees waived under WaiveFirstLateFee
+func (l *Loan) WaivedFeesTotal() float64 {
+	return l.waivedFees
+}
+
+// assessLateFees charges the late fee for each installment whose week has
+// ended since the last assessment without it being settled. Under
+// WaiveFirstLateFee the first fee the loan incurs is waived instead.
+func (l *Loan) assessLateFees() {
+	currentWeek := l.activeWeekAsOf(l.clock.Now())
+	if l.lateFee <= 0 || l.outstandingDebt <= 0 {
+		l.lateFeeCycles = currentWeek
+		return
+	}
+
+	settled := l.settledInstallments()
+	for cycle := l.lateFeeCycles + 1; cycle <= currentWeek; cycle++ {
+		if week := cycle - 1; week < settled || week >= l.totalWeeks {
+			continue
+		}
+
+		if l.waiveFirstLateFee && l.lateFees == 0 && l.waivedFees == 0 {
+			l.waivedFees += l.lateFee
+		} else {
+			l.lateFees += l.lateFee
+		}
+	}
+
+	if currentWeek > l.lateFeeCycles {
+		l.lateFeeCycles = currentWeek
+	}
+}
@@ -0,0 +1,84 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_PenaltyCapitalization(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	newOverdueLoan := func(capitalize bool) *Loan {
+		clock := newMockClock()
+		loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+			Principal:           1000000,
+			InterestRate:        0.10,
+			TotalWeeks:          50,
+			PenaltyRate:         0.52, // 1% a week
+			CapitalizePenalties: capitalize,
+		}))
+		assert.NoError(t, loan.MakePayment(22000))
+		clock.Advance(5 * week)
+		loan.RefreshStatus()
+		return loan
+	}
+
+	separate := newOverdueLoan(false)
+	capitalized := newOverdueLoan(true)
+
+	// Weeks 1 to 4 are overdue for 4, 3, 2 and 1 cycles respectively
+	assert.InDelta(t, 2200, separate.GetPenaltyFees(), 1e-6)
+	assert.Equal(t, 0.0, separate.GetCapitalizedPenalties())
+	assert.Equal(t, 1078000.0, separate.GetOutstanding())
+
+	assert.InDelta(t, 2222.11022, capitalized.GetCapitalizedPenalties(), 1e-6)
+	assert.Equal(t, 0.0, capitalized.GetPenaltyFees())
+	assert.InDelta(t, 1078000+2222.11022, capitalized.GetOutstanding(), 1e-6)
+
+	separateOwed := separate.GetOutstanding() + separate.GetPenaltyFees()
+	assert.Greater(t, capitalized.GetOutstanding(), separateOwed, "Capitalized penalties should compound")
+
+	capitalized.RefreshStatus()
+	assert.InDelta(t, 2222.11022, capitalized.GetCapitalizedPenalties(), 1e-6, "A cycle should only accrue once")
+}
+
+func TestLoan_WaiveFirstLateFee(t *testing.T) {
+	week := DaysPerWeek * HoursPerDay * time.Hour
+
+	newLoan := func(waive bool) (*Loan, *mockClock) {
+		clock := newMockClock()
+		loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+			Principal:         1000000,
+			InterestRate:      0.10,
+			TotalWeeks:        50,
+			LateFee:           5000,
+			WaiveFirstLateFee: waive,
+		}))
+		assert.NoError(t, loan.MakePayment(22000))
+		return loan, clock
+	}
+
+	loan, clock := newLoan(true)
+	clock.Advance(week)
+	loan.RefreshStatus()
+	assert.Equal(t, 0.0, loan.GetLateFees()+loan.WaivedFeesTotal(), "An installment still in its week is not late")
+
+	clock.Advance(week)
+	loan.RefreshStatus()
+	assert.Equal(t, 5000.0, loan.WaivedFeesTotal(), "The first late fee should be waived")
+	assert.Equal(t, 0.0, loan.GetLateFees())
+
+	clock.Advance(week)
+	loan.RefreshStatus()
+	assert.Equal(t, 5000.0, loan.WaivedFeesTotal())
+	assert.Equal(t, 5000.0, loan.GetLateFees(), "The second late fee should be charged")
+	assert.Equal(t, 1078000.0, loan.GetOutstanding(), "Late fees are kept apart from the debt")
+
+	loan, clock = newLoan(false)
+	clock.Advance(3 * week)
+	loan.RefreshStatus()
+	assert.Equal(t, 0.0, loan.WaivedFeesTotal())
+	assert.Equal(t, 10000.0, loan.GetLateFees())
+}
@@ -0,0 +1,47 @@
+package billing
+
+// PaymentPolicy decides whether a payment tendered through MakePayment is
+// accepted and how it is applied to the loan
+type PaymentPolicy interface {
+	// Apply records the payment on the loan, or returns an error and leaves the
+	// loan unchanged if the amount is not acceptable
+	Apply(l *Loan, amount float64) error
+}
+
+// StrictPaymentPolicy is the default payment policy. A current loan must be
+// paid exactly the next installment, and an overdue loan must be paid every
+// missed installment (up to the catch-up limit) in one payment. No payment may
+// exceed the outstanding balance.
+type StrictPaymentPolicy struct{}
+
+// Apply records the payment if it settles the next installment, or every missed
+// installment when the loan is overdue
+func (StrictPaymentPolicy) Apply(l *Loan, amount float64) error {
+	return l.makeScheduledPayment(amount)
+}
+
+// LenientPaymentPolicy accepts any positive amount towards the arrears of an
+// overdue loan. Amounts short of the missed installments are recorded as a
+// partial payment, which settles an installment only once the partial payments
+// towards it add up to it; everything else is applied as under StrictPaymentPolicy.
+type LenientPaymentPolicy struct{}
+
+// Apply records a shortfall on an overdue loan as a partial payment and
+// otherwise applies the payment strictly
+func (LenientPaymentPolicy) Apply(l *Loan, amount float64) error {
+	l.applyCredit()
+	if required := l.catchupInstallments(); required > 0 {
+		minimum := l.borrowerAmount(required)
+		if amount+l.partialPaid < minimum-paymentTolerance {
+			return l.makePartialPayment(amount)
+		}
+	}
+	return l.makeScheduledPayment(amount)
+}
+
+// WithPaymentPolicy sets the policy MakePayment uses to accept and apply payments
+func WithPaymentPolicy(policy PaymentPolicy) LoanOption {
+	return func(l *Loan) {
+		l.paymentPolicy = policy
+	}
+}
@@ -0,0 +1,94 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_PaymentPolicy(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	overdueLoan := func(policy PaymentPolicy) *Loan {
+		clock := newMockClock()
+		options := []LoanOption{WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50})}
+		if policy != nil {
+			options = append(options, WithPaymentPolicy(policy))
+		}
+		loan := NewLoan(options...)
+		clock.Advance(2 * week)
+		loan.RefreshStatus()
+		return loan
+	}
+
+	t.Run("strict by default", func(t *testing.T) {
+		loan := overdueLoan(nil)
+
+		err := loan.MakePayment(30000)
+		assert.EqualError(t, err, "payment amount must be at least 66000.00 for 3 missed payments")
+		assert.Empty(t, loan.GetPayments())
+		assert.Equal(t, 1100000.0, loan.GetOutstanding())
+
+		assert.NoError(t, loan.MakePayment(66000))
+		assert.Equal(t, 1034000.0, loan.GetOutstanding())
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		loan := overdueLoan(StrictPaymentPolicy{})
+
+		assert.Error(t, loan.MakePayment(30000))
+		assert.Empty(t, loan.GetPayments())
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		loan := overdueLoan(LenientPaymentPolicy{})
+
+		assert.NoError(t, loan.MakePayment(30000), "A shortfall should be accepted towards the arrears")
+		assert.Equal(t, 1070000.0, loan.GetOutstanding())
+		assert.Len(t, loan.GetPayments(), 1)
+
+		assert.EqualError(t, loan.MakePayment(0), "payment amount must be positive")
+		assert.Len(t, loan.GetPayments(), 1)
+	})
+
+	t.Run("lenient shortfalls settle installments only in full", func(t *testing.T) {
+		clock := newMockClock()
+		loan := NewLoan(WithClock(clock), WithPaymentPolicy(LenientPaymentPolicy{}),
+			WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+		clock.Advance(3*week + time.Hour)
+
+		assert.NoError(t, loan.MakePayment(30000))
+		assert.Equal(t, 1, loan.GetPayments()[0].Installments)
+		assert.Equal(t, Delinquent, loan.GetStatus(), "Two installments should still be overdue")
+
+		assert.NoError(t, loan.MakePayment(30000))
+		assert.Equal(t, 1, loan.GetPayments()[1].Installments, "The shortfall carried over should complete one more installment")
+		assert.Equal(t, PastDue, loan.GetStatus())
+
+		assert.NoError(t, loan.MakePayment(28000), "The carried shortfall should count towards the remaining arrears")
+		assert.Equal(t, 2, loan.GetPayments()[2].Installments)
+		assert.Equal(t, 16000.0, loan.GetPayments()[2].Carried)
+		assert.Equal(t, Active, loan.GetStatus())
+		assert.Equal(t, 0, loan.missedPayments())
+		assert.Equal(t, 1012000.0, loan.GetOutstanding())
+	})
+
+	t.Run("lenient applies full catch-up strictly", func(t *testing.T) {
+		strict, lenient := overdueLoan(StrictPaymentPolicy{}), overdueLoan(LenientPaymentPolicy{})
+
+		assert.NoError(t, strict.MakePayment(66000))
+		assert.NoError(t, lenient.MakePayment(66000))
+		assert.Equal(t, strict.GetOutstanding(), lenient.GetOutstanding())
+		assert.Equal(t, strict.GetStatus(), lenient.GetStatus())
+	})
+
+	t.Run("lenient current loan", func(t *testing.T) {
+		clock := newMockClock()
+		loan := NewLoan(WithClock(clock), WithPaymentPolicy(LenientPaymentPolicy{}))
+		assert.NoError(t, loan.MakePayment(loan.GetWeeklyPayment()))
+
+		assert.EqualError(t, loan.MakePayment(1000), "payment amount must be equal to the weekly payment",
+			"A loan that is not overdue should still require the installment")
+	})
+}
@@ -0,0 +1,242 @@
+package billing
+
+import (
+	"errors"
+	"sort"
+)
+
+// ConcentrationReport describes how concentrated the outstanding balance of a
+// portfolio in a single currency is
+type ConcentrationReport struct {
+	TotalOutstanding float64
+	TopN             int
+	TopNShare        float64 // fraction of the total outstanding held by the top N loans
+	HHI              float64 // Herfindahl-Hirschman index of the outstanding shares, from 0 to 1
+}
+
+// WeightedAverageRate returns the principal-weighted average interest rate of
+// the loans that are not closed in each currency, keyed by ISO 4217 code. A
+// currency whose loans are all closed is left out.
+func (e *Engine) WeightedAverageRate() map[string]float64 {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	weighted := make(map[string]float64)
+	principal := make(map[string]float64)
+	for _, loan := range e.loans {
+		if loan.GetStatus() == Closed {
+			continue
+		}
+		weighted[loan.currency] += loan.GetPrincipal() * loan.GetInterestRate()
+		principal[loan.currency] += loan.GetPrincipal()
+	}
+
+	rates := make(map[string]float64, len(principal))
+	for currency, total := range principal {
+		if total > 0 {
+			rates[currency] = weighted[currency] / total
+		}
+	}
+	return rates
+}
+
+// ConcentrationMetrics computes, for the loans with an outstanding balance in
+// each currency, the total outstanding, the share of it held by the topN loans
+// with the largest outstanding, and the Herfindahl-Hirschman index. The reports
+// are keyed by ISO 4217 code.
+func (e *Engine) ConcentrationMetrics(topN int) (map[string]ConcentrationReport, error) {
+	if topN <= 0 {
+		return nil, errors.New("topN must be positive")
+	}
+
+	unlock := e.rlockAll()
+	defer unlock()
+
+	balances := make(map[string][]float64)
+	for _, loan := range e.loans {
+		if outstanding := loan.GetOutstanding(); outstanding > 0 {
+			balances[loan.currency] = append(balances[loan.currency], outstanding)
+		}
+	}
+
+	reports := make(map[string]ConcentrationReport, len(balances))
+	for currency, currencyBalances := range balances {
+		reports[currency] = concentration(currencyBalances, topN)
+	}
+	return reports, nil
+}
+
+// concentration computes the concentration report of positive balances in a
+// single currency
+func concentration(balances []float64, topN int) ConcentrationReport {
+	report := ConcentrationReport{TopN: topN}
+	for _, balance := range balances {
+		report.TotalOutstanding += balance
+	}
+
+	sort.Sort(sort.Reverse(sort.Float64Slice(balances)))
+	for i, balance := range balances {
+		share := balance / report.TotalOutstanding
+		if i < topN {
+			report.TopNShare += share
+		}
+		report.HHI += share * share
+	}
+
+	return report
+}
+
+// DelinquencyRate returns the fraction of the loans that are not closed that
+// are delinquent as of each loan's clock, by count across the portfolio and by
+// outstanding balance in each currency, keyed by ISO 4217 code. The rate by
+// count is zero for an empty portfolio, and a currency with nothing outstanding
+// is left out of the rates by value.
+func (e *Engine) DelinquencyRate() (byCount float64, byValue map[string]float64) {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	var total, delinquent int
+	totalOutstanding := make(map[string]float64)
+	delinquentOutstanding := make(map[string]float64)
+	for _, loan := range e.loans {
+		if loan.GetStatus() == Closed {
+			continue
+		}
+
+		total++
+		totalOutstanding[loan.currency] += loan.GetOutstanding()
+		if loan.IsDelinquent() {
+			delinquent++
+			delinquentOutstanding[loan.currency] += loan.GetOutstanding()
+		}
+	}
+
+	if total > 0 {
+		byCount = float64(delinquent) / float64(total)
+	}
+	byValue = make(map[string]float64, len(totalOutstanding))
+	for currency, outstanding := range totalOutstanding {
+		if outstanding > 0 {
+			byValue[currency] = delinquentOutstanding[currency] / outstanding
+		}
+	}
+	return byCount, byValue
+}
+
+// DPD bucket labels used by DPDBuckets
+const (
+	DPDCurrent = "0"
+	DPD1To30   = "1-30"
+	DPD31To60  = "31-60"
+	DPD61To90  = "61-90"
+	DPDOver90  = "90+"
+)
+
+// DPDBuckets counts the loans that are not closed in each standard
+// days-past-due bucket. Every bucket is present in the result, even when empty.
+func (e *Engine) DPDBuckets() map[string]int {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	buckets := map[string]int{
+		DPDCurrent: 0,
+		DPD1To30:   0,
+		DPD31To60:  0,
+		DPD61To90:  0,
+		DPDOver90:  0,
+	}
+
+	for _, loan := range e.loans {
+		if loan.GetStatus() == Closed {
+			continue
+		}
+
+		dpd := loan.DaysPastDue()
+		switch {
+		case dpd == 0:
+			buckets[DPDCurrent]++
+		case dpd <= 30:
+			buckets[DPD1To30]++
+		case dpd <= 60:
+			buckets[DPD31To60]++
+		case dpd <= 90:
+			buckets[DPD61To90]++
+		default:
+			buckets[DPDOver90]++
+		}
+	}
+
+	return buckets
+}
+
+// CashFlowForecast returns the installments expected across all active loans in
+// each currency, keyed by ISO 4217 code, in each of the next weeks, where index
+// 0 is the current week. Installments already overdue are expected in the
+// current week; suspended and closed loans are left out.
+func (e *Engine) CashFlowForecast(weeks int) (map[string][]float64, error) {
+	if weeks <= 0 {
+		return nil, errors.New("weeks must be positive")
+	}
+
+	unlock := e.rlockAll()
+	defer unlock()
+
+	forecasts := make(map[string][]float64)
+	for _, loan := range e.loans {
+		if loan.GetStatus() == Closed || loan.IsSuspended() {
+			continue
+		}
+
+		forecast, ok := forecasts[loan.currency]
+		if !ok {
+			forecast = make([]float64, weeks)
+			forecasts[loan.currency] = forecast
+		}
+
+		currentWeek := loan.activeWeekAsOf(loan.clock.Now())
+		for week := loan.settledInstallments(); week < loan.totalWeeks; week++ {
+			offset := week - currentWeek
+			if offset < 0 {
+				offset = 0
+			}
+			if offset >= weeks {
+				break
+			}
+			forecast[offset] += loan.installmentForWeek(week)
+		}
+	}
+
+	return forecasts, nil
+}
+
+// FindByOutstandingRange returns the loans whose outstanding balance lies
+// between min and max inclusive, sorted by outstanding descending. A max of zero
+// or less means there is no upper bound.
+func (e *Engine) FindByOutstandingRange(min, max float64) ([]*Loan, error) {
+	if min < 0 {
+		return nil, errors.New("min must not be negative")
+	}
+	if max > 0 && min > max {
+		return nil, errors.New("min must not exceed max")
+	}
+
+	unlock := e.rlockAll()
+	defer unlock()
+
+	var found []*Loan
+	for _, loan := range e.loans {
+		outstanding := loan.GetOutstanding()
+		if outstanding >= min && (max <= 0 || outstanding <= max) {
+			found = append(found, loan)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].GetOutstanding() != found[j].GetOutstanding() {
+			return found[i].GetOutstanding() > found[j].GetOutstanding()
+		}
+		return found[i].GetID() < found[j].GetID()
+	})
+
+	return found, nil
+}
@@ -0,0 +1,237 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_WeightedAverageRate(t *testing.T) {
+	engine := NewEngine()
+	assert.Empty(t, engine.WeightedAverageRate(), "Empty portfolio should have no rates")
+
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	_, _ = engine.CreateLoan(WithLoanID("loan2"), WithLoanConfig(Config{
+		Principal:    3000000,
+		InterestRate: 0.20,
+		TotalWeeks:   50,
+	}))
+	closed, _ := engine.CreateLoan(WithLoanID("loan3"), WithLoanConfig(Config{
+		Principal:    5000000,
+		InterestRate: 0.50,
+		TotalWeeks:   50,
+	}))
+	closed.status = Closed
+	_, _ = engine.CreateLoan(WithLoanID("loan4"), WithCurrency("USD"), WithLoanConfig(Config{
+		Principal:    1000,
+		InterestRate: 0.05,
+		TotalWeeks:   50,
+	}))
+
+	rates := engine.WeightedAverageRate()
+
+	assert.InDelta(t, 0.175, rates["IDR"], 1e-9, "Rate should be weighted by principal")
+	assert.NotEqual(t, 0.15, rates["IDR"], "Weighted rate should differ from the simple average")
+	assert.InDelta(t, 0.05, rates["USD"], 1e-9, "Loans in other currencies should be weighted apart")
+}
+
+func TestEngine_ConcentrationMetrics(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.ConcentrationMetrics(0)
+	assert.EqualError(t, err, "topN must be positive")
+
+	reports, err := engine.ConcentrationMetrics(1)
+	assert.NoError(t, err)
+	assert.Empty(t, reports, "Empty portfolio should have no reports")
+
+	for id, principal := range map[string]float64{"loan1": 600000, "loan2": 300000, "loan3": 100000} {
+		_, _ = engine.CreateLoan(WithLoanID(id), WithLoanConfig(Config{
+			Principal:    principal,
+			InterestRate: 0,
+			TotalWeeks:   50,
+		}))
+	}
+	_, _ = engine.CreateLoan(WithLoanID("loan4"), WithCurrency("USD"), WithLoanConfig(Config{
+		Principal:    5000,
+		InterestRate: 0,
+		TotalWeeks:   50,
+	}))
+
+	reports, err = engine.ConcentrationMetrics(1)
+
+	assert.NoError(t, err)
+	report := reports["IDR"]
+	assert.InDelta(t, 1000000, report.TotalOutstanding, 0.01)
+	assert.InDelta(t, 0.6, report.TopNShare, 1e-9)
+	assert.InDelta(t, 0.36+0.09+0.01, report.HHI, 1e-9)
+	assert.Equal(t, ConcentrationReport{TotalOutstanding: 5000, TopN: 1, TopNShare: 1, HHI: 1}, reports["USD"],
+		"Each currency should be reported apart")
+
+	reports, _ = engine.ConcentrationMetrics(5)
+	assert.InDelta(t, 1.0, reports["IDR"].TopNShare, 1e-9, "Top N beyond the portfolio size covers everything")
+}
+
+func TestEngine_DPDBuckets(t *testing.T) {
+	day := HoursPerDay * time.Hour
+	engine := NewEngine()
+	now := newMockClock().Now()
+
+	ages := map[string]int{
+		"current": 0,
+		"late5":   12,
+		"late30":  37,
+		"late45":  52,
+		"late90":  97,
+		"late120": 127,
+	}
+	for id, age := range ages {
+		clock := newMockClock()
+		_, _ = engine.CreateLoan(WithLoanID(id), WithClock(clock))
+		_ = engine.SetStartDate(id, now.Add(-time.Duration(age)*day))
+	}
+	closed, _ := engine.CreateLoan(WithLoanID("closed"), WithClock(newMockClock()))
+	_ = closed.SetStartDate(now.Add(-200 * day))
+	closed.status = Closed
+
+	buckets := engine.DPDBuckets()
+
+	assert.Equal(t, map[string]int{
+		DPDCurrent: 1,
+		DPD1To30:   2,
+		DPD31To60:  1,
+		DPD61To90:  1,
+		DPDOver90:  1,
+	}, buckets)
+}
+
+func TestEngine_CashFlowForecast(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.CashFlowForecast(0)
+	assert.EqualError(t, err, "weeks must be positive")
+
+	clock := newMockClock()
+	_, _ = engine.CreateLoan(WithLoanID("short"), WithClock(clock), WithLoanConfig(Config{
+		Principal:    100000,
+		InterestRate: 0.10,
+		TotalWeeks:   2,
+	}))
+	_, _ = engine.CreateLoan(WithLoanID("long"), WithClock(clock), WithLoanConfig(Config{
+		Principal:    200000,
+		InterestRate: 0.10,
+		TotalWeeks:   4,
+	}))
+	closed, _ := engine.CreateLoan(WithLoanID("closed"), WithClock(clock))
+	closed.status = Closed
+
+	forecast, err := engine.CashFlowForecast(6)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]float64{"IDR": {110000, 110000, 55000, 55000, 0, 0}}, forecast)
+
+	_, _ = engine.CreateLoan(WithLoanID("usd"), WithClock(clock), WithCurrency("USD"), WithLoanConfig(Config{
+		Principal:    1000,
+		InterestRate: 0.10,
+		TotalWeeks:   2,
+	}))
+	clock.Advance(2 * DaysPerWeek * HoursPerDay * time.Hour)
+	forecast, _ = engine.CashFlowForecast(3)
+	assert.Equal(t, []float64{275000, 55000, 0}, forecast["IDR"], "Overdue installments should be expected now")
+	assert.Equal(t, []float64{1100, 0, 0}, forecast["USD"], "Installments in other currencies should be forecast apart")
+}
+
+func TestEngine_CashFlowForecast_CreditSettled(t *testing.T) {
+	engine := NewEngine()
+	clock := newMockClock()
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithOverpaymentCredit(), WithLoanConfig(Config{
+		Principal:    200000,
+		InterestRate: 0.10,
+		TotalWeeks:   4,
+	}))
+
+	assert.NoError(t, engine.MakePayment("loan1", 110000))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	loan.RefreshStatus()
+	assert.Len(t, loan.creditApplications, 1)
+
+	forecast, err := engine.CashFlowForecast(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 55000, 55000}, forecast["IDR"], "An installment settled by credit should not be forecast")
+}
+
+func TestEngine_FindByOutstandingRange(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.FindByOutstandingRange(-1, 0)
+	assert.EqualError(t, err, "min must not be negative")
+	_, err = engine.FindByOutstandingRange(200, 100)
+	assert.EqualError(t, err, "min must not exceed max")
+
+	for id, principal := range map[string]float64{"small": 100000, "medium": 500000, "large": 1000000, "huge": 5000000} {
+		_, _ = engine.CreateLoan(WithLoanID(id), WithLoanConfig(Config{
+			Principal:    principal,
+			InterestRate: 0,
+			TotalWeeks:   50,
+		}))
+	}
+
+	ids := func(loans []*Loan) []string {
+		var result []string
+		for _, loan := range loans {
+			result = append(result, loan.GetID())
+		}
+		return result
+	}
+
+	loans, err := engine.FindByOutstandingRange(100000, 1000000)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"large", "medium", "small"}, ids(loans), "Bounds should be inclusive and sorted descending")
+
+	loans, _ = engine.FindByOutstandingRange(500000, 0)
+	assert.Equal(t, []string{"huge", "large", "medium"}, ids(loans), "A zero max should mean no upper bound")
+
+	loans, _ = engine.FindByOutstandingRange(200000, 400000)
+	assert.Empty(t, loans)
+}
+
+func TestEngine_DelinquencyRate(t *testing.T) {
+	engine := NewEngine()
+	byCount, byValue := engine.DelinquencyRate()
+	assert.Equal(t, 0.0, byCount, "Empty portfolio should have zero rates")
+	assert.Empty(t, byValue)
+
+	now := newMockClock().Now()
+	loans := map[string]struct {
+		principal float64
+		age       time.Duration
+	}{
+		"current1":    {1000000, 0},
+		"current2":    {1000000, 0},
+		"pastdue":     {1000000, 10 * HoursPerDay * time.Hour},
+		"delinquent":  {3000000, 3 * DaysPerWeek * HoursPerDay * time.Hour},
+		"closedStale": {5000000, 5 * DaysPerWeek * HoursPerDay * time.Hour},
+	}
+	for id, l := range loans {
+		_, _ = engine.CreateLoan(WithLoanID(id), WithClock(newMockClock()), WithLoanConfig(Config{
+			Principal:    l.principal,
+			InterestRate: 0,
+			TotalWeeks:   50,
+		}))
+		_ = engine.SetStartDate(id, now.Add(-l.age))
+	}
+	closed, _ := engine.GetLoan("closedStale")
+	closed.status = Closed
+	_, _ = engine.CreateLoan(WithLoanID("usd"), WithClock(newMockClock()), WithCurrency("USD"))
+
+	byCount, byValue = engine.DelinquencyRate()
+
+	assert.InDelta(t, 0.2, byCount, 1e-9)
+	assert.InDelta(t, 0.5, byValue["IDR"], 1e-9)
+	assert.Equal(t, 0.0, byValue["USD"], "Balances in other currencies should be rated apart")
+}
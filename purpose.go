@@ -0,0 +1,95 @@
+package billing
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Purpose classifies what a loan is used for
+type Purpose int
+
+// Loan purposes
+const (
+	UnspecifiedPurpose Purpose = iota
+	Personal
+	Business
+	Education
+)
+
+// purposeNames maps each purpose to its name
+var purposeNames = map[Purpose]string{
+	UnspecifiedPurpose: "Unspecified",
+	Personal:           "Personal",
+	Business:           "Business",
+	Education:          "Education",
+}
+
+// String returns the name of the purpose
+func (p Purpose) String() string {
+	if name, ok := purposeNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Purpose(%d)", int(p))
+}
+
+// MarshalText encodes the purpose by name, so it serializes as a string in JSON
+func (p Purpose) MarshalText() ([]byte, error) {
+	if _, ok := purposeNames[p]; !ok {
+		return nil, fmt.Errorf("unknown purpose %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a purpose from its name
+func (p *Purpose) UnmarshalText(text []byte) error {
+	for purpose, name := range purposeNames {
+		if name == string(text) {
+			*p = purpose
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown purpose %q", string(text))
+}
+
+// WithPurpose sets what the loan is used for
+func WithPurpose(purpose Purpose) LoanOption {
+	return func(l *Loan) {
+		l.purpose = purpose
+	}
+}
+
+// WithProductType sets the lending product the loan was issued under
+func WithProductType(productType string) LoanOption {
+	return func(l *Loan) {
+		l.productType = productType
+	}
+}
+
+// GetPurpose returns what the loan is used for
+func (l *Loan) GetPurpose() Purpose {
+	return l.purpose
+}
+
+// GetProductType returns the lending product the loan was issued under
+func (l *Loan) GetProductType() string {
+	return l.productType
+}
+
+// FindByPurpose returns the loans with the given purpose, sorted by ID
+func (e *Engine) FindByPurpose(purpose Purpose) []*Loan {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	var found []*Loan
+	for _, loan := range e.loans {
+		if loan.purpose == purpose {
+			found = append(found, loan)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].GetID() < found[j].GetID()
+	})
+
+	return found
+}
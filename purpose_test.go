@@ -0,0 +1,57 @@
+package billing
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_FindByPurpose(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("loan3"), WithPurpose(Business))
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithPurpose(Business), WithProductType("working-capital"))
+	_, _ = engine.CreateLoan(WithLoanID("loan2"), WithPurpose(Education))
+	_, _ = engine.CreateLoan(WithLoanID("loan4"))
+
+	var ids []string
+	for _, loan := range engine.FindByPurpose(Business) {
+		ids = append(ids, loan.GetID())
+	}
+	assert.Equal(t, []string{"loan1", "loan3"}, ids)
+
+	assert.Len(t, engine.FindByPurpose(Education), 1)
+	assert.Len(t, engine.FindByPurpose(Personal), 0)
+	assert.Len(t, engine.FindByPurpose(UnspecifiedPurpose), 1)
+}
+
+func TestPurpose_String(t *testing.T) {
+	assert.Equal(t, "Personal", Personal.String())
+	assert.Equal(t, "Unspecified", UnspecifiedPurpose.String())
+	assert.Equal(t, "Purpose(9)", Purpose(9).String())
+}
+
+func TestLoanSnapshot_PurposeJSON(t *testing.T) {
+	loan := NewLoan(WithPurpose(Education), WithProductType("student"))
+	snapshot := loan.Snapshot()
+
+	data, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Purpose":"Education"`)
+	assert.Contains(t, string(data), `"ProductType":"student"`)
+
+	var decoded LoanSnapshot
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, Education, decoded.Purpose)
+	assert.Equal(t, "student", decoded.ProductType)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"Purpose":"Holiday"}`), &decoded))
+}
+
+func TestDiffSnapshots_Purpose(t *testing.T) {
+	a := NewLoan(WithLoanID("loan1")).Snapshot()
+	b := a
+	b.Purpose = Business
+
+	assert.Equal(t, []FieldChange{{Field: "Purpose", Old: UnspecifiedPurpose, New: Business}}, DiffSnapshots(a, b))
+}
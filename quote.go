@@ -0,0 +1,88 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// LoanQuote describes the terms of a loan before it is created
+type LoanQuote struct {
+	Principal      float64
+	InterestRate   float64
+	TotalWeeks     int
+	WeeklyPayment  float64
+	TotalRepayable float64
+	TotalInterest  float64
+	Schedule       []float64
+}
+
+// Quote computes the terms of a loan with the given configuration without
+// creating it, so they can be shown before committing the loan to an engine
+func Quote(cfg Config) (LoanQuote, error) {
+	if err := cfg.Validate(); err != nil {
+		return LoanQuote{}, err
+	}
+
+	loan := NewLoan(WithLoanConfig(cfg))
+
+	return LoanQuote{
+		Principal:      loan.GetPrincipal(),
+		InterestRate:   loan.GetInterestRate(),
+		TotalWeeks:     loan.GetTotalWeeks(),
+		WeeklyPayment:  loan.GetWeeklyPayment(),
+		TotalRepayable: loan.GetOutstanding(),
+		TotalInterest:  loan.GetOutstanding() - loan.GetPrincipal(),
+		Schedule:       loan.GetBillingSchedule(),
+	}, nil
+}
+
+// TermForInstallment returns the number of weeks needed to repay a flat-interest
+// loan with weekly installments of the given size, the last one possibly
+// smaller. Since flat interest does not grow with the term, any positive
+// installment repays the loan eventually; it is too small when the term it
+// needs exceeds MaxTotalWeeks.
+func TermForInstallment(principal, rate, installment float64) (int, error) {
+	if installment <= 0 {
+		return 0, errors.New("installment must be positive")
+	}
+
+	weeks := int(math.Ceil(principal*(1+rate)/installment - paymentTolerance))
+	if MaxTotalWeeks > 0 && weeks > MaxTotalWeeks {
+		return 0, fmt.Errorf("installment is too small to repay the loan within %d weeks", MaxTotalWeeks)
+	}
+
+	if err := (Config{Principal: principal, InterestRate: rate, TotalWeeks: weeks}).Validate(); err != nil {
+		return 0, err
+	}
+
+	return weeks, nil
+}
+
+// MaxAffordablePrincipal returns the largest principal of a flat-interest loan
+// at the given rate and term whose weekly installment stays within dtiRatio of
+// the borrower's weekly disposable income. The ratio must be in (0, 1]. The
+// result is capped at MaxPrincipal.
+func MaxAffordablePrincipal(income, dtiRatio, rate float64, weeks int) (float64, error) {
+	if income < 0 {
+		return 0, errors.New("income must not be negative")
+	}
+	if dtiRatio <= 0 || dtiRatio > 1 {
+		return 0, errors.New("debt-to-income ratio must be greater than 0 and at most 1")
+	}
+	if rate < 0 {
+		return 0, errors.New("interest rate must not be negative")
+	}
+	if weeks <= 0 {
+		return 0, errors.New("total weeks must be positive")
+	}
+	if MaxTotalWeeks > 0 && weeks > MaxTotalWeeks {
+		return 0, fmt.Errorf("total weeks must not exceed %d", MaxTotalWeeks)
+	}
+
+	principal := income * dtiRatio * float64(weeks) / (1 + rate)
+	if MaxPrincipal > 0 && principal > MaxPrincipal {
+		principal = MaxPrincipal
+	}
+	return principal, nil
+}
@@ -0,0 +1,91 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		expectedError string
+	}{
+		{"Valid config", Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}, ""},
+		{"Zero principal", Config{Principal: 0, InterestRate: 0.10, TotalWeeks: 50}, "principal must be positive"},
+		{"Negative rate", Config{Principal: 1000000, InterestRate: -0.10, TotalWeeks: 50}, "interest rate must not be negative"},
+		{"Zero weeks", Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 0}, "total weeks must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := Quote(tt.config)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.InDelta(t, 22000, quote.WeeklyPayment, 0.01)
+			assert.InDelta(t, 1100000, quote.TotalRepayable, 0.01)
+			assert.InDelta(t, 100000, quote.TotalInterest, 0.01)
+			assert.Len(t, quote.Schedule, 50)
+
+			engine := NewEngine()
+			loan, _ := engine.CreateLoan(WithLoanConfig(tt.config))
+			assert.Equal(t, loan.GetWeeklyPayment(), quote.WeeklyPayment)
+			assert.Equal(t, loan.GetOutstanding(), quote.TotalRepayable)
+			assert.Equal(t, loan.GetBillingSchedule(), quote.Schedule)
+		})
+	}
+}
+
+func TestTermForInstallment(t *testing.T) {
+	weeks, err := TermForInstallment(1000000, 0.10, 22000)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, weeks)
+
+	weeks, err = TermForInstallment(1000000, 0.10, 30000)
+	assert.NoError(t, err)
+	assert.Equal(t, 37, weeks, "A partial final installment should need a whole week")
+
+	shorter, _ := TermForInstallment(1000000, 0.10, 60000)
+	assert.Less(t, shorter, weeks, "A larger installment should need fewer weeks")
+
+	_, err = TermForInstallment(1000000, 0.10, 0)
+	assert.EqualError(t, err, "installment must be positive")
+
+	_, err = TermForInstallment(1000000, 0.10, 1000)
+	assert.EqualError(t, err, "installment is too small to repay the loan within 520 weeks")
+
+	_, err = TermForInstallment(0, 0.10, 1000)
+	assert.EqualError(t, err, "principal must be positive")
+}
+
+func TestMaxAffordablePrincipal(t *testing.T) {
+	principal, err := MaxAffordablePrincipal(44000, 0.5, 0.10, 50)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1000000, principal, 1e-6)
+
+	loan := NewLoan(WithLoanConfig(Config{Principal: principal, InterestRate: 0.10, TotalWeeks: 50}))
+	assert.LessOrEqual(t, loan.GetWeeklyPayment(), 44000*0.5+1e-6, "The installment should stay within the ratio")
+
+	higher, _ := MaxAffordablePrincipal(66000, 0.5, 0.10, 50)
+	assert.Greater(t, higher, principal, "A higher income should afford a higher principal")
+
+	capped, _ := MaxAffordablePrincipal(1e9, 1, 0.10, 50)
+	assert.Equal(t, MaxPrincipal, capped)
+
+	_, err = MaxAffordablePrincipal(44000, 0, 0.10, 50)
+	assert.EqualError(t, err, "debt-to-income ratio must be greater than 0 and at most 1")
+
+	_, err = MaxAffordablePrincipal(44000, 1.5, 0.10, 50)
+	assert.EqualError(t, err, "debt-to-income ratio must be greater than 0 and at most 1")
+
+	_, err = MaxAffordablePrincipal(-1, 0.5, 0.10, 50)
+	assert.EqualError(t, err, "income must not be negative")
+
+	_, err = MaxAffordablePrincipal(44000, 0.5, 0.10, 0)
+	assert.EqualError(t, err, "total weeks must be positive")
+}
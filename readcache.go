@@ -0,0 +1,31 @@
+package billing
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// WithReadCache makes GetOutstanding serve each loan's outstanding balance from
+// a per-loan cache read with an atomic load, without taking the engine lock.
+// The cache is refreshed whenever the loan's balance changes, including through
+// methods called directly on the loan.
+func WithReadCache() EngineOption {
+	return func(e *Engine) {
+		e.readCache = true
+	}
+}
+
+// enableOutstandingCache starts publishing the loan's outstanding balance to a
+// cache and returns it
+func (l *Loan) enableOutstandingCache() *uint64 {
+	l.outstandingCache = new(uint64)
+	l.publishOutstanding()
+	return l.outstandingCache
+}
+
+// publishOutstanding refreshes the cached outstanding balance, if enabled
+func (l *Loan) publishOutstanding() {
+	if l.outstandingCache != nil {
+		atomic.StoreUint64(l.outstandingCache, math.Float64bits(l.outstandingDebt))
+	}
+}
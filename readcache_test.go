@@ -0,0 +1,86 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_WithReadCache(t *testing.T) {
+	engine := NewEngine(WithReadCache())
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"), WithClock(newMockClock()))
+
+	outstanding, err := engine.GetOutstanding("loan1")
+	assert.NoError(t, err)
+	assert.Equal(t, 5500000.0, outstanding)
+
+	assert.NoError(t, engine.MakePayment("loan1", loan.GetWeeklyPayment()))
+	outstanding, _ = engine.GetOutstanding("loan1")
+	assert.Equal(t, 5390000.0, outstanding)
+
+	assert.NoError(t, loan.Adjust(90000, "goodwill"))
+	outstanding, _ = engine.GetOutstanding("loan1")
+	assert.Equal(t, 5300000.0, outstanding, "Direct loan changes should refresh the cache")
+
+	assert.NoError(t, engine.ChangeInterestRate("loan1", 0.20, 1))
+	outstanding, _ = engine.GetOutstanding("loan1")
+	assert.Equal(t, loan.GetOutstanding(), outstanding)
+
+	assert.NoError(t, loan.PayOff())
+	outstanding, _ = engine.GetOutstanding("loan1")
+	assert.Equal(t, 0.0, outstanding)
+
+	_, err = engine.GetOutstanding("non-existent")
+	assert.Error(t, err)
+}
+
+func TestEngine_WithReadCache_Transaction(t *testing.T) {
+	engine := NewEngine(WithReadCache())
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	err := engine.Transaction(func(tx *Txn) error {
+		assert.NoError(t, tx.MakePayment("loan1", 22000))
+		outstanding, _ := engine.GetOutstanding("loan1")
+		assert.Equal(t, 1100000.0, outstanding, "An uncommitted payment should not be published")
+		return errors.New("abort")
+	})
+	assert.Error(t, err)
+	outstanding, _ := engine.GetOutstanding("loan1")
+	assert.Equal(t, 1100000.0, outstanding)
+
+	assert.NoError(t, engine.Transaction(func(tx *Txn) error {
+		return tx.MakePayment("loan1", 22000)
+	}))
+	outstanding, _ = engine.GetOutstanding("loan1")
+	assert.Equal(t, 1078000.0, outstanding, "A committed payment should be published")
+
+	assert.NoError(t, engine.MakePayment("loan1", 22000))
+	outstanding, _ = engine.GetOutstanding("loan1")
+	assert.Equal(t, 1056000.0, outstanding, "The cache should stay attached after the transaction")
+}
+
+func benchmarkGetOutstanding(b *testing.B, options ...EngineOption) {
+	engine := NewEngine(options...)
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = engine.GetOutstanding("loan1")
+		}
+	})
+}
+
+func BenchmarkEngine_GetOutstanding(b *testing.B) {
+	benchmarkGetOutstanding(b)
+}
+
+func BenchmarkEngine_GetOutstanding_ReadCache(b *testing.B) {
+	benchmarkGetOutstanding(b, WithReadCache())
+}
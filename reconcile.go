@@ -0,0 +1,78 @@
+package billing
+
+import (
+	"math"
+	"sort"
+)
+
+// ReconcileTolerance is the largest difference between the engine's and an
+// external outstanding balance that Reconcile still treats as matching
+var ReconcileTolerance = 0.01
+
+// DiscrepancyKind identifies how a loan's balances disagree
+type DiscrepancyKind int
+
+const (
+	// BalanceMismatch is a loan whose outstanding balances differ
+	BalanceMismatch DiscrepancyKind = iota
+	// MissingExternally is a loan held by the engine but absent from the external feed
+	MissingExternally
+	// MissingInEngine is a loan in the external feed that the engine does not hold
+	MissingInEngine
+)
+
+// String returns the string representation of the discrepancy kind
+func (k DiscrepancyKind) String() string {
+	switch k {
+	case BalanceMismatch:
+		return "BalanceMismatch"
+	case MissingExternally:
+		return "MissingExternally"
+	case MissingInEngine:
+		return "MissingInEngine"
+	default:
+		return "Unknown"
+	}
+}
+
+// Discrepancy is a loan whose balance in the engine does not match an external
+// source. A balance absent from one side is reported as zero.
+type Discrepancy struct {
+	LoanID      string
+	Kind        DiscrepancyKind
+	Outstanding float64 // the engine's outstanding balance
+	External    float64 // the external outstanding balance
+	Delta       float64 // External minus Outstanding
+}
+
+// Reconcile compares the engine's outstanding balances against authoritative
+// external ones, keyed by loan ID, and returns the discrepancies sorted by loan
+// ID: loans whose balances differ by more than ReconcileTolerance, and loans
+// present in only one of the two sources
+func (e *Engine) Reconcile(external map[string]float64) []Discrepancy {
+	unlock := e.rlockAll()
+	defer unlock()
+
+	var discrepancies []Discrepancy
+	for id, loan := range e.loans {
+		outstanding := loan.GetOutstanding()
+		balance, exists := external[id]
+		switch {
+		case !exists:
+			discrepancies = append(discrepancies, Discrepancy{LoanID: id, Kind: MissingExternally, Outstanding: outstanding, Delta: -outstanding})
+		case math.Abs(balance-outstanding) > ReconcileTolerance:
+			discrepancies = append(discrepancies, Discrepancy{LoanID: id, Kind: BalanceMismatch, Outstanding: outstanding, External: balance, Delta: balance - outstanding})
+		}
+	}
+	for id, balance := range external {
+		if _, exists := e.loans[id]; !exists {
+			discrepancies = append(discrepancies, Discrepancy{LoanID: id, Kind: MissingInEngine, External: balance, Delta: balance})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].LoanID < discrepancies[j].LoanID
+	})
+
+	return discrepancies
+}
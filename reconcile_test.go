@@ -0,0 +1,37 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Reconcile(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("matching"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	_, _ = engine.CreateLoan(WithLoanID("within-tolerance"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	mismatched, _ := engine.CreateLoan(WithLoanID("mismatched"), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	_ = mismatched.MakePayment(22000)
+	_, _ = engine.CreateLoan(WithLoanID("engine-only"), WithLoanConfig(Config{Principal: 500000, InterestRate: 0.10, TotalWeeks: 50}))
+
+	discrepancies := engine.Reconcile(map[string]float64{
+		"matching":         1100000,
+		"within-tolerance": 1100000.005,
+		"mismatched":       1100000,
+		"external-only":    250000,
+	})
+
+	assert.Equal(t, []Discrepancy{
+		{LoanID: "engine-only", Kind: MissingExternally, Outstanding: 550000, Delta: -550000},
+		{LoanID: "external-only", Kind: MissingInEngine, External: 250000, Delta: 250000},
+		{LoanID: "mismatched", Kind: BalanceMismatch, Outstanding: 1078000, External: 1100000, Delta: 22000},
+	}, discrepancies)
+	assert.Equal(t, "BalanceMismatch", discrepancies[2].Kind.String())
+
+	assert.Empty(t, engine.Reconcile(map[string]float64{
+		"matching":         1100000,
+		"within-tolerance": 1100000,
+		"mismatched":       1078000,
+		"engine-only":      550000,
+	}), "Matching balances should reconcile cleanly")
+}
@@ -0,0 +1,80 @@
+package billing
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+// recordDateFormat is the ISO 8601 date format used in structured records
+const recordDateFormat = "2006-01-02"
+
+// StructuredRecord is a loan mapped to a simplified ISO 20022-like structure,
+// suitable for serializing to XML or JSON for banking system interop
+type StructuredRecord struct {
+	XMLName        xml.Name               `json:"-" xml:"LnRcrd"`
+	Identification string                 `json:"Id" xml:"Id"`
+	Counterparty   PartyIdentification    `json:"Cpty" xml:"Cpty"`
+	Principal      ActiveCurrencyAmount   `json:"PrncplAmt" xml:"PrncplAmt"`
+	Outstanding    ActiveCurrencyAmount   `json:"OutsdngAmt" xml:"OutsdngAmt"`
+	InterestRate   float64                `json:"IntrstRate" xml:"IntrstRate"`
+	StartDate      string                 `json:"StartDt" xml:"StartDt"`
+	TermWeeks      int                    `json:"TermWks" xml:"TermWks"`
+	Status         string                 `json:"Sts" xml:"Sts"`
+	Schedule       []ScheduledInstallment `json:"Schdl" xml:"Schdl>Instlmt"`
+}
+
+// PartyIdentification identifies the counterparty of a loan
+type PartyIdentification struct {
+	Identification string `json:"Id,omitempty" xml:"Id,omitempty"`
+}
+
+// ActiveCurrencyAmount is an amount with its currency
+type ActiveCurrencyAmount struct {
+	Amount   float64 `json:"Amt" xml:",chardata"`
+	Currency string  `json:"Ccy" xml:"Ccy,attr"`
+}
+
+// ScheduledInstallment is a single installment of a structured record's schedule
+type ScheduledInstallment struct {
+	Number  int                  `json:"Nb" xml:"Nb"`
+	DueDate string               `json:"DueDt" xml:"DueDt"`
+	Amount  ActiveCurrencyAmount `json:"Amt" xml:"Amt"`
+}
+
+// ToStructuredRecord maps the loan to a structured record
+func (l *Loan) ToStructuredRecord() StructuredRecord {
+	schedule := l.billingSchedule()
+	installments := make([]ScheduledInstallment, len(schedule))
+	for week, amount := range schedule {
+		installments[week] = ScheduledInstallment{
+			Number:  week + 1,
+			DueDate: l.dueDate(week).Format(recordDateFormat),
+			Amount:  ActiveCurrencyAmount{Amount: amount, Currency: l.currency},
+		}
+	}
+
+	return StructuredRecord{
+		Identification: l.id,
+		Counterparty:   PartyIdentification{Identification: l.borrowerID},
+		Principal:      ActiveCurrencyAmount{Amount: l.principal, Currency: l.currency},
+		Outstanding:    ActiveCurrencyAmount{Amount: l.outstandingDebt, Currency: l.currency},
+		InterestRate:   l.interestRate,
+		StartDate:      l.startDate.Format(recordDateFormat),
+		TermWeeks:      l.totalWeeks,
+		Status:         l.status.String(),
+		Schedule:       installments,
+	}
+}
+
+// ToStructuredRecord maps a specific loan to a structured record
+func (e *Engine) ToStructuredRecord(id string) (StructuredRecord, error) {
+	unlock := e.rlockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return StructuredRecord{}, errors.New("loan not found")
+	}
+
+	return loan.ToStructuredRecord(), nil
+}
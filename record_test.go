@@ -0,0 +1,58 @@
+package billing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_ToStructuredRecord(t *testing.T) {
+	loan := NewLoan(WithLoanID("loan1"), WithClock(newMockClock()), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	_ = loan.MakePayment(22000)
+
+	record := loan.ToStructuredRecord()
+
+	assert.Equal(t, "loan1", record.Identification)
+	assert.Equal(t, ActiveCurrencyAmount{Amount: 1000000, Currency: "IDR"}, record.Principal)
+	assert.Equal(t, ActiveCurrencyAmount{Amount: 1078000, Currency: "IDR"}, record.Outstanding)
+	assert.Equal(t, 0.10, record.InterestRate)
+	assert.Equal(t, "2024-01-01", record.StartDate)
+	assert.Equal(t, 50, record.TermWeeks)
+	assert.Equal(t, "Active", record.Status)
+	assert.Len(t, record.Schedule, 50)
+	assert.Equal(t, ScheduledInstallment{
+		Number:  2,
+		DueDate: "2024-01-15",
+		Amount:  ActiveCurrencyAmount{Amount: 22000, Currency: "IDR"},
+	}, record.Schedule[1])
+
+	data, err := json.Marshal(record)
+	assert.NoError(t, err)
+	assert.True(t, json.Valid(data))
+
+	var decoded StructuredRecord
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, record.Identification, decoded.Identification)
+	assert.Equal(t, record.Schedule, decoded.Schedule)
+
+	_, err = xml.Marshal(record)
+	assert.NoError(t, err)
+}
+
+func TestEngine_ToStructuredRecord(t *testing.T) {
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+
+	record, err := engine.ToStructuredRecord("loan1")
+	assert.NoError(t, err)
+	assert.Equal(t, "loan1", record.Identification)
+
+	_, err = engine.ToStructuredRecord("non-existent")
+	assert.Error(t, err)
+}
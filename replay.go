@@ -0,0 +1,125 @@
+package billing
+
+import (
+	"fmt"
+	"time"
+)
+
+// replayClock is a clock whose time is set explicitly, e.g. to each event's time
+// while replaying
+type replayClock struct {
+	now time.Time
+}
+
+// Now returns the time the clock was set to
+func (c *replayClock) Now() time.Time {
+	return c.now
+}
+
+// ReplayEvents reconstructs loans by applying an event log, such as one captured
+// with Subscribe, in order. Every event is applied at the time it was recorded,
+// except failed automatic payments, which changed nothing and are ignored.
+// Replayed events are not re-emitted. Replay stops at the first event that does
+// not apply cleanly, e.g. a payment for a loan that has not been created or the
+// creation of a loan whose options were not recorded; the events before it
+// remain applied. Replayed loans keep the status they had at their last event
+// unless the engine was created WithStatusRefreshOnLoad.
+func (e *Engine) ReplayEvents(events []Event) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	clock := &replayClock{}
+	replayed := make(map[string]*Loan)
+	defer func() {
+		for _, loan := range replayed {
+			loan.clock = systemClock{}
+			if e.refreshOnLoad {
+				loan.RefreshStatus()
+			}
+		}
+	}()
+
+	for i, event := range events {
+		clock.now = event.Time
+
+		switch event.Type {
+		case EventAutoPayFailed:
+			continue
+		case EventLoanCreated:
+			loan, err := e.replayCreation(event, clock)
+			if err != nil {
+				return fmt.Errorf("event %d: %v", i, err)
+			}
+			e.store(loan)
+			replayed[event.LoanID] = loan
+			continue
+		}
+
+		loan, exists := replayed[event.LoanID]
+		if !exists {
+			return fmt.Errorf("event %d: loan %s was not created by an earlier event", i, event.LoanID)
+		}
+
+		var err error
+		switch event.Type {
+		case EventPaymentMade:
+			err = loan.MakePayment(event.Amount)
+		case EventPaymentReturned:
+			err = loan.ReturnPayment(event.Index, event.Amount, event.Reason)
+		case EventPaymentReversed:
+			err = loan.ReverseLastPayment()
+		case EventAdjusted:
+			err = loan.Adjust(event.Amount, event.Reason)
+		case EventDiscountApplied:
+			err = loan.ApplyDiscount(event.Amount, event.Reason)
+		case EventSuspended:
+			err = loan.Suspend(event.Reason)
+		case EventResumed:
+			err = loan.Resume()
+		case EventInterestRateChanged:
+			err = loan.ChangeInterestRate(event.Rate, event.Week)
+		case EventStartDateChanged:
+			err = loan.SetStartDate(event.From)
+		case EventInstallmentRescheduled:
+			err = loan.RescheduleInstallment(event.Week)
+		case EventReliefPeriodDeclared:
+			err = loan.AddReliefPeriod(event.From, event.To)
+		case EventLoanDeleted:
+			e.remove(event.LoanID)
+			delete(replayed, event.LoanID)
+		default:
+			err = fmt.Errorf("unknown event type %d", event.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("event %d: loan %s: %v", i, event.LoanID, err)
+		}
+	}
+
+	return nil
+}
+
+// replayCreation recreates the loan announced by a creation event from the
+// options it records
+func (e *Engine) replayCreation(event Event, clock Clock) (*Loan, error) {
+	if event.Creation == nil {
+		return nil, fmt.Errorf("creation of loan %s does not record its options", event.LoanID)
+	}
+	if event.Creation.Config == nil {
+		return nil, fmt.Errorf("creation of loan %s has no config", event.LoanID)
+	}
+	if _, exists := e.loans[event.LoanID]; exists {
+		return nil, fmt.Errorf("loan %s already exists", event.LoanID)
+	}
+
+	entry := *event.Creation
+	entry.LoanID = event.LoanID
+	options, err := creationOptions(entry, clock)
+	if err != nil {
+		return nil, fmt.Errorf("creation of loan %s: %v", event.LoanID, err)
+	}
+	loan, err := NewLoanValidated(options...)
+	if err != nil {
+		return nil, fmt.Errorf("creation of loan %s: %v", event.LoanID, err)
+	}
+	return loan, nil
+}
@@ -0,0 +1,159 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_ReplayEvents(t *testing.T) {
+	clock := newMockClock()
+	var log []Event
+	source := NewEngine(WithEventHandler(func(event Event) {
+		log = append(log, event)
+	}))
+
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+	_, _ = source.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(config))
+	_, _ = source.CreateLoan(WithLoanID("loan2"), WithClock(clock), WithLoanConfig(config))
+
+	assert.NoError(t, source.MakePayment("loan1", 22000))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	assert.NoError(t, source.MakePayment("loan1", 22000))
+	assert.NoError(t, source.Adjust("loan1", 5000, "goodwill"))
+	assert.NoError(t, source.Suspend("loan2", "hardship"))
+	clock.Advance(DaysPerWeek * HoursPerDay * time.Hour)
+	assert.NoError(t, source.Resume("loan2"))
+
+	replayed := NewEngine()
+	assert.NoError(t, replayed.ReplayEvents(log))
+
+	for _, id := range []string{"loan1", "loan2"} {
+		original, _ := source.GetLoan(id)
+		rebuilt, err := replayed.GetLoan(id)
+		assert.NoError(t, err)
+		assert.Empty(t, DiffSnapshots(original.Snapshot(), rebuilt.Snapshot()), "Replayed %s should match the original", id)
+		assert.Equal(t, original.GetAdjustments(), rebuilt.GetAdjustments())
+	}
+}
+
+func TestEngine_ReplayEvents_EveryMutation(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	clock := newMockClock()
+	var log []Event
+	source := NewEngine(WithEventHandler(func(event Event) {
+		log = append(log, event)
+	}))
+
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+	_, _ = source.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithLoanConfig(config), WithCurrency("USD"),
+		WithBorrowerID("borrower1"), WithPurpose(Business), WithSubsidy(2000), WithPaymentPolicy(LenientPaymentPolicy{}))
+	_, _ = source.CreateLoan(WithLoanID("loan2"), WithClock(clock), WithLoanConfig(config))
+	_, _ = source.CreateLoan(WithLoanID("loan3"), WithClock(clock))
+
+	assert.NoError(t, source.SetStartDate("loan2", clock.Now().Add(-24*time.Hour)))
+	assert.NoError(t, source.MakePayment("loan1", 20000))
+	assert.NoError(t, source.Transaction(func(tx *Txn) error {
+		if err := tx.ReversePayment("loan1"); err != nil {
+			return err
+		}
+		return tx.ChangeInterestRate("loan2", 0.12, 5)
+	}))
+	clock.Advance(2*week + 24*time.Hour)
+	assert.NoError(t, source.RescheduleInstallment("loan1", 0))
+	assert.NoError(t, source.DeclareReliefPeriod(clock.Now(), clock.Now().Add(week), []string{"loan1", "loan2"}))
+	assert.NoError(t, source.DeleteLoan("loan3"))
+
+	replayed := NewEngine()
+	assert.NoError(t, replayed.ReplayEvents(log))
+
+	_, err := replayed.GetLoan("loan3")
+	assert.Error(t, err, "The deleted loan should stay deleted")
+	for _, id := range []string{"loan1", "loan2"} {
+		original, _ := source.GetLoan(id)
+		rebuilt, err := replayed.GetLoan(id)
+		assert.NoError(t, err)
+		assert.Empty(t, DiffSnapshots(original.Snapshot(), rebuilt.Snapshot()), "Replayed %s should match the original", id)
+		assert.Equal(t, original.GetBillingSchedule(), rebuilt.GetBillingSchedule())
+		assert.Equal(t, original.GetStartDate(), rebuilt.GetStartDate())
+		assert.Equal(t, original.GetCurrency(), rebuilt.GetCurrency())
+		assert.Equal(t, original.GetBorrowerID(), rebuilt.GetBorrowerID())
+		assert.Equal(t, original.GetPurpose(), rebuilt.GetPurpose())
+		assert.Equal(t, original.SubsidyInstallment(), rebuilt.SubsidyInstallment())
+		assert.Equal(t, original.paymentPolicy, rebuilt.paymentPolicy)
+		assert.Equal(t, original.reliefPeriods, rebuilt.reliefPeriods)
+	}
+}
+
+func TestEngine_ReplayEvents_Inconsistent(t *testing.T) {
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+
+	tests := []struct {
+		name          string
+		events        []Event
+		expectedError string
+	}{
+		{
+			"Payment before creation",
+			[]Event{{Type: EventPaymentMade, LoanID: "loan1", Amount: 22000}},
+			"event 0: loan loan1 was not created by an earlier event",
+		},
+		{
+			"Duplicate creation",
+			[]Event{
+				{Type: EventLoanCreated, LoanID: "loan1", Creation: &WALEntry{Config: &config}},
+				{Type: EventLoanCreated, LoanID: "loan1", Creation: &WALEntry{Config: &config}},
+			},
+			"event 1: loan loan1 already exists",
+		},
+		{
+			"Creation without options",
+			[]Event{{Type: EventLoanCreated, LoanID: "loan1", Config: &config}},
+			"event 0: creation of loan loan1 does not record its options",
+		},
+		{
+			"Creation without config",
+			[]Event{{Type: EventLoanCreated, LoanID: "loan1", Creation: &WALEntry{}}},
+			"event 0: creation of loan loan1 has no config",
+		},
+		{
+			"Resume without suspension",
+			[]Event{
+				{Type: EventLoanCreated, LoanID: "loan1", Creation: &WALEntry{Config: &config}},
+				{Type: EventResumed, LoanID: "loan1"},
+			},
+			"event 1: loan loan1: loan is not suspended",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine()
+			assert.EqualError(t, engine.ReplayEvents(tt.events), tt.expectedError)
+		})
+	}
+}
+
+func TestEngine_ReplayEvents_StatusRefreshOnLoad(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+	events := []Event{
+		{Type: EventLoanCreated, LoanID: "stale", Time: start, Creation: &WALEntry{Config: &config}},
+		{Type: EventPaymentMade, LoanID: "stale", Amount: 22000, Time: start},
+	}
+
+	engine := NewEngine()
+	assert.NoError(t, engine.ReplayEvents(events))
+	loan, _ := engine.GetLoan("stale")
+	assert.Equal(t, Active, loan.GetStatus(), "The status should be left as of the last event by default")
+
+	engine.RefreshAllStatuses()
+	assert.Equal(t, Delinquent, loan.GetStatus())
+
+	engine = NewEngine(WithStatusRefreshOnLoad())
+	assert.NoError(t, engine.ReplayEvents(events))
+	loan, _ = engine.GetLoan("stale")
+	assert.Equal(t, Delinquent, loan.GetStatus(), "A loan weeks overdue should load as Delinquent")
+}
@@ -0,0 +1,85 @@
+package billing
+
+import (
+	"errors"
+	"time"
+)
+
+// ReturnedPayment records a payment that bounced, e.g. a returned check, and
+// the fee charged for it
+type ReturnedPayment struct {
+	Payment Payment
+	Fee     float64
+	Reason  string
+	Date    time.Time
+}
+
+// ReturnPayment reverses the payment at the given index because it bounced,
+// adds the returned-payment fee to the outstanding debt, billing it with the
+// first unsettled installment, and records the return.
+// The status is recomputed without the payment, so a loan that relied on it to
+// stay current may become past due or delinquent.
+func (l *Loan) ReturnPayment(index int, fee float64, reason string) error {
+	if fee < 0 {
+		return errors.New("returned payment fee must not be negative")
+	}
+
+	payment, err := l.ReversePaymentAt(index)
+	if err != nil {
+		return err
+	}
+
+	l.returnedPayments = append(l.returnedPayments, ReturnedPayment{Payment: payment, Fee: fee, Reason: reason, Date: l.clock.Now()})
+	l.outstandingDebt += fee
+	l.billFee(fee)
+	l.updateStatus()
+
+	return nil
+}
+
+// billFee adds a fee to the first unsettled installment, so that it is paid
+// with it
+func (l *Loan) billFee(fee float64) {
+	if fee <= 0 || l.totalWeeks <= 0 {
+		return
+	}
+
+	week := l.settledInstallments()
+	if week >= l.totalWeeks {
+		week = l.totalWeeks - 1
+	}
+	if l.installmentOverrides == nil {
+		l.installmentOverrides = make(map[int]float64)
+	}
+	l.installmentOverrides[week] = l.installmentForWeek(week) + fee
+	l.invalidateSchedule()
+}
+
+// GetReturnedPayments returns a copy of the returned payments slice
+func (l *Loan) GetReturnedPayments() []ReturnedPayment {
+	returnedCopy := make([]ReturnedPayment, len(l.returnedPayments))
+	copy(returnedCopy, l.returnedPayments)
+	return returnedCopy
+}
+
+// ReturnPayment records that a payment of a specific loan bounced. The event
+// emitted carries the fee as its amount.
+func (e *Engine) ReturnPayment(id string, index int, fee float64, reason string) error {
+	unlock := e.lockLoan(id)
+	defer unlock()
+
+	loan, exists := e.loans[id]
+	if !exists {
+		return errors.New("loan not found")
+	}
+
+	err := e.mutate(loan, WALEntry{Op: WALReturnPayment, Amount: fee, Index: index, Reason: reason}, func(l *Loan) error {
+		return l.ReturnPayment(index, fee, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.emit(Event{Type: EventPaymentReturned, LoanID: id, Amount: fee, Index: index, Reason: reason, Time: loan.clock.Now()})
+	return nil
+}
@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_ReturnPayment(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, loan.MakePayment(22000))
+		clock.Advance(week)
+	}
+	clock.Advance(week + 2*24*time.Hour)
+	loan.RefreshStatus()
+	assert.Equal(t, PastDue, loan.GetStatus())
+
+	assert.NoError(t, loan.ReturnPayment(2, 15000, "returned check"))
+
+	assert.Equal(t, Delinquent, loan.GetStatus(), "Without the bounced payment the loan should be delinquent")
+	assert.Len(t, loan.GetPayments(), 2)
+	assert.Equal(t, 1100000.0-2*22000+15000, loan.GetOutstanding(), "The payment should be reversed and the fee added")
+	assert.Equal(t, []ReturnedPayment{{
+		Payment: Payment{Amount: 22000, Date: clock.Now().Add(-2*week - 2*24*time.Hour), Installments: 1},
+		Fee:     15000,
+		Reason:  "returned check",
+		Date:    clock.Now(),
+	}}, loan.GetReturnedPayments())
+}
+
+func TestLoan_ReturnPayment_Invalid(t *testing.T) {
+	loan := NewLoan()
+	assert.NoError(t, loan.MakePayment(loan.GetWeeklyPayment()))
+
+	assert.EqualError(t, loan.ReturnPayment(0, -1, ""), "returned payment fee must not be negative")
+	assert.EqualError(t, loan.ReturnPayment(1, 0, ""), "payment index 1 is out of range")
+	assert.Len(t, loan.GetPayments(), 1)
+	assert.Empty(t, loan.GetReturnedPayments())
+}
+
+func TestEngine_ReturnPayment(t *testing.T) {
+	var events []Event
+	engine := NewEngine(WithEventHandler(func(event Event) { events = append(events, event) }))
+	_, _ = engine.CreateLoan(WithLoanID("loan1"))
+	_ = engine.MakePayment("loan1", 110000)
+
+	assert.NoError(t, engine.ReturnPayment("loan1", 0, 25000, "insufficient funds"))
+	assert.Equal(t, EventPaymentReturned, events[2].Type)
+	assert.Equal(t, 25000.0, events[2].Amount)
+	assert.Equal(t, "insufficient funds", events[2].Reason)
+
+	outstanding, _ := engine.GetOutstanding("loan1")
+
+	replayed := NewEngine()
+	assert.NoError(t, replayed.ReplayEvents(events))
+	replayedOutstanding, _ := replayed.GetOutstanding("loan1")
+	assert.Equal(t, outstanding, replayedOutstanding, "A returned payment should replay")
+
+	assert.EqualError(t, engine.ReturnPayment("non-existent", 0, 0, ""), "loan not found")
+}
+
+func TestLoan_ReturnPayment_BillsFee(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000, InterestRate: 0.10, TotalWeeks: 4}))
+	assert.NoError(t, loan.MakePayment(275))
+	assert.NoError(t, loan.ReturnPayment(0, 50, "returned check"))
+
+	assert.Equal(t, []float64{325, 275, 275, 275}, loan.GetBillingSchedule(), "The fee should be billed with the first unsettled installment")
+	assert.EqualError(t, loan.MakePayment(275), "payment amount must be at least 325.00 for 1 missed payments")
+
+	clock.Advance(3 * week)
+	assert.EqualError(t, loan.MakePayment(1200), "payment amount must not exceed the outstanding balance of 1150.00")
+	assert.NoError(t, loan.MakePayment(1150))
+	assert.Equal(t, Closed, loan.GetStatus())
+	assert.Equal(t, 0.0, loan.GetOutstanding())
+}
+
+func TestLoan_ReturnPayment_Charges(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	assert.NoError(t, loan.AddCharge("insurance", 1000, true))
+	assert.NoError(t, loan.AddCharge("admin fee", 5000, false))
+
+	assert.NoError(t, loan.MakePayment(28000))
+	assert.Equal(t, 6000.0, loan.ChargesCollected())
+	assert.Equal(t, 6000.0, loan.GetPayments()[0].Charges)
+
+	assert.NoError(t, loan.ReturnPayment(0, 0, "returned check"))
+	assert.Equal(t, 0.0, loan.ChargesCollected(), "The charges paid with a returned payment should be reversed")
+	assert.False(t, loan.GetCharges()[1].Collected, "The one-off charge should be due again")
+
+	assert.EqualError(t, loan.MakePayment(23000), "payment amount must be at least 28000.00 for 1 missed payments")
+	assert.NoError(t, loan.MakePayment(28000))
+	assert.Equal(t, 6000.0, loan.ChargesCollected())
+}
@@ -0,0 +1,49 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// validateLossInputs checks that every probability of default and the loss
+// given default lie in [0, 1]
+func validateLossInputs(pdByStatus map[LoanStatus]float64, lgd float64) error {
+	if lgd < 0 || lgd > 1 {
+		return errors.New("loss given default must be between 0 and 1")
+	}
+	for status, pd := range pdByStatus {
+		if pd < 0 || pd > 1 {
+			return fmt.Errorf("probability of default for %s must be between 0 and 1", status)
+		}
+	}
+	return nil
+}
+
+// ExpectedLoss returns PD × LGD × outstanding, taking the probability of default
+// for the loan's current status from pdByStatus. Statuses missing from the
+// table have a probability of default of zero.
+func (l *Loan) ExpectedLoss(pdByStatus map[LoanStatus]float64, lgd float64) (float64, error) {
+	if err := validateLossInputs(pdByStatus, lgd); err != nil {
+		return 0, err
+	}
+
+	return pdByStatus[l.status] * lgd * l.outstandingDebt, nil
+}
+
+// TotalExpectedLoss returns the sum of the expected loss of the engine's loans
+// in each currency, keyed by ISO 4217 code
+func (e *Engine) TotalExpectedLoss(pdByStatus map[LoanStatus]float64, lgd float64) (map[string]float64, error) {
+	if err := validateLossInputs(pdByStatus, lgd); err != nil {
+		return nil, err
+	}
+
+	unlock := e.rlockAll()
+	defer unlock()
+
+	totals := make(map[string]float64)
+	for _, loan := range e.loans {
+		loss, _ := loan.ExpectedLoss(pdByStatus, lgd)
+		totals[loan.currency] += loss
+	}
+	return totals, nil
+}
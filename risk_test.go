@@ -0,0 +1,46 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_TotalExpectedLoss(t *testing.T) {
+	pd := map[LoanStatus]float64{
+		Active:     0.01,
+		PastDue:    0.10,
+		Delinquent: 0.50,
+	}
+
+	engine := NewEngine()
+	_, _ = engine.CreateLoan(WithLoanID("active"))
+	pastDue, _ := engine.CreateLoan(WithLoanID("pastdue"))
+	pastDue.status = PastDue
+	delinquent, _ := engine.CreateLoan(WithLoanID("delinquent"))
+	delinquent.status = Delinquent
+	suspended, _ := engine.CreateLoan(WithLoanID("suspended"))
+	suspended.status = Suspended
+	usd, _ := engine.CreateLoan(WithLoanID("usd"), WithCurrency("USD"), WithLoanConfig(Config{Principal: 1000, InterestRate: 0.10, TotalWeeks: 10}))
+	usd.status = Delinquent
+
+	loss, err := delinquent.ExpectedLoss(pd, 0.40)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.50*0.40*5500000, loss, 1e-6)
+
+	totals, err := engine.TotalExpectedLoss(pd, 0.40)
+	assert.NoError(t, err)
+	assert.Len(t, totals, 2)
+	assert.InDelta(t, (0.01+0.10+0.50)*0.40*5500000, totals["IDR"], 1e-6, "Statuses without a PD should add no loss")
+	assert.InDelta(t, 0.50*0.40*1100, totals["USD"], 1e-6, "Losses should be totalled by currency")
+}
+
+func TestEngine_TotalExpectedLoss_Invalid(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.TotalExpectedLoss(map[LoanStatus]float64{Active: 0.01}, 1.5)
+	assert.EqualError(t, err, "loss given default must be between 0 and 1")
+
+	_, err = engine.TotalExpectedLoss(map[LoanStatus]float64{Delinquent: -0.1}, 0.4)
+	assert.EqualError(t, err, "probability of default for Delinquent must be between 0 and 1")
+}
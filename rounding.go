@@ -0,0 +1,65 @@
+package billing
+
+import (
+	"errors"
+	"math"
+)
+
+// roundToMinorUnit rounds amount to the nearest multiple of the loan's minor
+// unit. It returns amount unchanged when no minor unit is configured.
+func (l *Loan) roundToMinorUnit(amount float64) float64 {
+	if l.minorUnit <= 0 {
+		return amount
+	}
+	return math.Round(amount/l.minorUnit) * l.minorUnit
+}
+
+// residualFor returns the difference between total and weeks installments of
+// the given amount. It is zero when installments are not rounded.
+func (l *Loan) residualFor(total, installment float64, weeks int) float64 {
+	if l.minorUnit <= 0 {
+		return 0
+	}
+	return total - installment*float64(weeks)
+}
+
+// RoundingResidual returns the total rounding difference that is swept into
+// the final installment, i.e. the final installment minus the regular one.
+// It is zero when installments are not rounded, except for a balloon loan, whose
+// final installment is the balloon.
+func (l *Loan) RoundingResidual() float64 {
+	return l.roundingResidual
+}
+
+// WithBanknoteRounding rounds every installment to a multiple of the given
+// banknote denomination, collecting the residual in the final installment.
+// It acts as the loan's minor unit and must follow WithLoanConfig. A
+// denomination that is not positive or is larger than the installment, or a
+// WithLoanConfig that comes later, is reported by NewLoanValidated.
+func WithBanknoteRounding(denomination float64) LoanOption {
+	return func(l *Loan) {
+		if denomination <= 0 {
+			l.optionErr = errors.New("banknote denomination must be positive")
+			return
+		}
+		if l.totalWeeks <= 0 || denomination > l.outstandingDebt/float64(l.totalWeeks) {
+			l.optionErr = errors.New("banknote denomination must not exceed the weekly installment")
+			return
+		}
+
+		l.minorUnit = denomination
+		l.followConfig("WithBanknoteRounding")
+		if l.interestMethod == DecliningBalance {
+			l.applyDecliningBalance()
+			return
+		}
+
+		total := l.outstandingDebt
+		l.weeklyPayment = l.roundToMinorUnit(total / float64(l.totalWeeks))
+		l.roundingResidual = l.residualFor(total, l.weeklyPayment, l.totalWeeks)
+		if l.balloon > 0 {
+			l.applyBalloon()
+		}
+		l.invalidateSchedule()
+	}
+}
@@ -0,0 +1,140 @@
+package billing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_RoundingResidual(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   30,
+		MinorUnit:    1,
+	}))
+
+	schedule := loan.GetBillingSchedule()
+
+	assert.Equal(t, 36667.0, loan.GetWeeklyPayment(), "Installment should be rounded to whole IDR")
+	assert.NotZero(t, loan.RoundingResidual())
+	assert.InDelta(t, -10, loan.RoundingResidual(), 1e-6)
+	for _, installment := range schedule[:29] {
+		assert.Equal(t, 36667.0, installment)
+	}
+	assert.InDelta(t, 36657, schedule[29], 1e-6, "Final installment should absorb the residual")
+
+	total := 0.0
+	for _, installment := range schedule {
+		total += installment
+	}
+	assert.InDelta(t, 1100000, total, 1e-6, "Schedule should sum to the total repayable")
+}
+
+func TestLoan_RoundingResidual_AfterRateChange(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   30,
+		MinorUnit:    1,
+	}))
+
+	assert.NoError(t, loan.ChangeInterestRate(0.15, 10))
+
+	total := 0.0
+	for _, installment := range loan.GetBillingSchedule() {
+		total += installment
+	}
+	assert.Equal(t, loan.roundToMinorUnit(loan.GetWeeklyPayment()), loan.GetWeeklyPayment())
+	assert.InDelta(t, loan.GetOutstanding(), total, 1e-6, "Schedule should still sum to the outstanding")
+}
+
+func TestLoan_RoundingResidual_Disabled(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   30,
+	}))
+
+	assert.InDelta(t, 1100000.0/30, loan.GetWeeklyPayment(), 1e-9)
+	assert.Zero(t, loan.RoundingResidual())
+}
+
+func TestLoan_VariableFinalInstallment(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal float64
+		rate      float64
+		minorUnit float64
+		weekly    float64
+	}{
+		{"Whole units", 1000000, 0.10, 1, 157143},
+		{"Cents with a fractional total", 1000000.07, 0.13, 0.01, 161428.58},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loan := NewLoan(WithLoanConfig(Config{
+				Principal:    tt.principal,
+				InterestRate: tt.rate,
+				TotalWeeks:   7,
+				MinorUnit:    tt.minorUnit,
+			}))
+			total := tt.principal * (1 + tt.rate)
+
+			schedule := loan.GetBillingSchedule()
+			for week := 0; week < 6; week++ {
+				assert.InDelta(t, tt.weekly, schedule[week], 1e-6)
+			}
+			assert.InDelta(t, total-6*tt.weekly, schedule[6], 1e-6, "Final installment should be the residual")
+			assert.NotEqual(t, schedule[0], schedule[6])
+
+			for week := 0; week < 6; week++ {
+				assert.NoError(t, loan.MakePayment(tt.weekly))
+			}
+			assert.Error(t, loan.MakePayment(tt.weekly), "Final week should require exactly the residual")
+			assert.NoError(t, loan.MakePayment(total-6*tt.weekly))
+
+			assert.Equal(t, 0.0, loan.GetOutstanding())
+			assert.Equal(t, Closed, loan.GetStatus())
+		})
+	}
+}
+
+func TestLoan_WithBanknoteRounding(t *testing.T) {
+	loan, err := NewLoanValidated(WithLoanConfig(Config{
+		Principal:    1234567,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}), WithBanknoteRounding(1000))
+	assert.NoError(t, err)
+
+	schedule := loan.GetBillingSchedule()
+	total := 0.0
+	for week, installment := range schedule {
+		if week < len(schedule)-1 {
+			assert.Equal(t, 0.0, math.Mod(installment, 1000), "Installment %d should be a multiple of 1000", week)
+		}
+		total += installment
+	}
+
+	assert.Equal(t, 27000.0, schedule[0])
+	assert.InDelta(t, 1358023.7, total, 1e-6, "Schedule should sum to the total owed")
+	assert.InDelta(t, 1358023.7-49*27000, schedule[49], 1e-6)
+}
+
+func TestLoan_WithBanknoteRounding_Invalid(t *testing.T) {
+	_, err := NewLoanValidated(WithBanknoteRounding(0))
+	assert.EqualError(t, err, "banknote denomination must be positive")
+
+	_, err = NewLoanValidated(WithBanknoteRounding(1000000))
+	assert.EqualError(t, err, "banknote denomination must not exceed the weekly installment")
+
+	_, err = NewLoanValidated(WithBanknoteRounding(1000), WithLoanConfig(Config{
+		Principal:    1234567,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	assert.EqualError(t, err, "WithBanknoteRounding must follow WithLoanConfig", "A later configuration should not silently drop the rounding")
+}
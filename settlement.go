@@ -0,0 +1,41 @@
+package billing
+
+import "errors"
+
+// SettlementQuote returns the amount that settles the loan with a discount of
+// discountPct off the outstanding debt, e.g. 0.3 for 30% off
+func (l *Loan) SettlementQuote(discountPct float64) (float64, error) {
+	if discountPct < 0 || discountPct >= 1 {
+		return 0, errors.New("discount must be at least 0 and less than 1")
+	}
+	return l.outstandingDebt * (1 - discountPct), nil
+}
+
+// Settle closes the loan for a negotiated amount less than or equal to the
+// outstanding debt. The amount is recorded as a payment and the remainder is
+// written off as an adjustment with the given reason.
+func (l *Loan) Settle(amount float64, reason string) error {
+	if l.immutable {
+		return ErrLoanImmutable
+	}
+
+	if l.outstandingDebt <= 0 {
+		return errors.New("loan is already fully paid")
+	}
+	if amount <= 0 {
+		return errors.New("settlement amount must be positive")
+	}
+	if amount > l.outstandingDebt+paymentTolerance {
+		return errors.New("settlement amount must not exceed the outstanding debt")
+	}
+
+	now := l.clock.Now()
+	l.recordPayment(Payment{Amount: amount, Date: now, Installments: l.unsettledInstallments()})
+	if writeOff := l.outstandingDebt - amount; writeOff > paymentTolerance {
+		l.adjustments = append(l.adjustments, Adjustment{Amount: writeOff, Reason: reason, Date: now})
+	}
+	l.outstandingDebt = 0
+	l.updateStatus()
+
+	return nil
+}
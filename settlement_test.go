@@ -0,0 +1,40 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_Settle(t *testing.T) {
+	clock := newMockClock()
+	loan := NewLoan(WithClock(clock), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	clock.Advance(3 * DaysPerWeek * HoursPerDay * time.Hour)
+	loan.RefreshStatus()
+	assert.Equal(t, Delinquent, loan.GetStatus())
+
+	_, err := loan.SettlementQuote(1)
+	assert.EqualError(t, err, "discount must be at least 0 and less than 1")
+	_, err = loan.SettlementQuote(-0.1)
+	assert.Error(t, err)
+
+	quote, err := loan.SettlementQuote(0.3)
+	assert.NoError(t, err)
+	assert.InDelta(t, 770000, quote, 1e-6)
+
+	assert.EqualError(t, loan.Settle(2000000, "collections"), "settlement amount must not exceed the outstanding debt")
+	assert.NoError(t, loan.Settle(quote, "collections"))
+
+	assert.Equal(t, Closed, loan.GetStatus())
+	assert.Equal(t, 0.0, loan.GetOutstanding())
+	assert.Equal(t, []Payment{{Amount: quote, Date: clock.Now(), Installments: 50}}, loan.GetPayments())
+	assert.Equal(t, []Adjustment{{Amount: 330000, Reason: "collections", Date: clock.Now()}}, loan.GetAdjustments(),
+		"The remainder should be written off")
+
+	assert.EqualError(t, loan.Settle(1000, "again"), "loan is already fully paid")
+}
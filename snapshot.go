@@ -0,0 +1,85 @@
+package billing
+
+import "time"
+
+// LoanSnapshot is a point-in-time copy of a loan's state
+type LoanSnapshot struct {
+	ID            string
+	Principal     float64
+	InterestRate  float64
+	TotalWeeks    int
+	WeeklyPayment float64
+	StartDate     time.Time
+	Outstanding   float64
+	Status        LoanStatus
+	Payments      []Payment
+	Purpose       Purpose
+	ProductType   string
+}
+
+// FieldChange describes a single field that differs between two snapshots
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+// Snapshot returns a point-in-time copy of the loan's state
+func (l *Loan) Snapshot() LoanSnapshot {
+	return LoanSnapshot{
+		ID:            l.id,
+		Principal:     l.principal,
+		InterestRate:  l.interestRate,
+		TotalWeeks:    l.totalWeeks,
+		WeeklyPayment: l.weeklyPayment,
+		StartDate:     l.startDate,
+		Outstanding:   l.outstandingDebt,
+		Status:        l.status,
+		Payments:      l.GetPayments(),
+		Purpose:       l.purpose,
+		ProductType:   l.productType,
+	}
+}
+
+// DiffSnapshots returns the fields that differ between two snapshots of the same loan.
+// Payments are compared by count and reported as the "PaymentCount" field, so the
+// number of added payments is New minus Old.
+func DiffSnapshots(a, b LoanSnapshot) []FieldChange {
+	var changes []FieldChange
+
+	if a.ID != b.ID {
+		changes = append(changes, FieldChange{Field: "ID", Old: a.ID, New: b.ID})
+	}
+	if a.Principal != b.Principal {
+		changes = append(changes, FieldChange{Field: "Principal", Old: a.Principal, New: b.Principal})
+	}
+	if a.InterestRate != b.InterestRate {
+		changes = append(changes, FieldChange{Field: "InterestRate", Old: a.InterestRate, New: b.InterestRate})
+	}
+	if a.TotalWeeks != b.TotalWeeks {
+		changes = append(changes, FieldChange{Field: "TotalWeeks", Old: a.TotalWeeks, New: b.TotalWeeks})
+	}
+	if a.WeeklyPayment != b.WeeklyPayment {
+		changes = append(changes, FieldChange{Field: "WeeklyPayment", Old: a.WeeklyPayment, New: b.WeeklyPayment})
+	}
+	if !a.StartDate.Equal(b.StartDate) {
+		changes = append(changes, FieldChange{Field: "StartDate", Old: a.StartDate, New: b.StartDate})
+	}
+	if a.Outstanding != b.Outstanding {
+		changes = append(changes, FieldChange{Field: "Outstanding", Old: a.Outstanding, New: b.Outstanding})
+	}
+	if a.Status != b.Status {
+		changes = append(changes, FieldChange{Field: "Status", Old: a.Status, New: b.Status})
+	}
+	if a.Purpose != b.Purpose {
+		changes = append(changes, FieldChange{Field: "Purpose", Old: a.Purpose, New: b.Purpose})
+	}
+	if a.ProductType != b.ProductType {
+		changes = append(changes, FieldChange{Field: "ProductType", Old: a.ProductType, New: b.ProductType})
+	}
+	if len(a.Payments) != len(b.Payments) {
+		changes = append(changes, FieldChange{Field: "PaymentCount", Old: len(a.Payments), New: len(b.Payments)})
+	}
+
+	return changes
+}
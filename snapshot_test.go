@@ -0,0 +1,46 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_Snapshot(t *testing.T) {
+	loan := NewLoan(WithLoanID("loan1"), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+	_ = loan.MakePayment(22000)
+
+	snapshot := loan.Snapshot()
+
+	assert.Equal(t, "loan1", snapshot.ID)
+	assert.Equal(t, 1000000.0, snapshot.Principal)
+	assert.Equal(t, 1078000.0, snapshot.Outstanding)
+	assert.Len(t, snapshot.Payments, 1)
+
+	snapshot.Payments[0].Amount = 0
+	assert.Equal(t, 22000.0, loan.GetPayments()[0].Amount, "Snapshot payments should be a copy")
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	loan := NewLoan(WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   50,
+	}))
+
+	before := loan.Snapshot()
+	_ = loan.MakePayment(22000)
+	after := loan.Snapshot()
+
+	changes := DiffSnapshots(before, after)
+
+	assert.Equal(t, []FieldChange{
+		{Field: "Outstanding", Old: 1100000.0, New: 1078000.0},
+		{Field: "PaymentCount", Old: 0, New: 1},
+	}, changes)
+	assert.Empty(t, DiffSnapshots(after, after), "Identical snapshots should have no changes")
+}
@@ -0,0 +1,49 @@
+package billing
+
+import (
+	"errors"
+	"math"
+)
+
+// WithSubsidy has a third party pay perPeriod of every installment. The loan
+// still amortizes on the full installment, but MakePayment takes the
+// borrower's net amount and credits the subsidy on top of it. A negative
+// subsidy is reported by NewLoanValidated.
+func WithSubsidy(perPeriod float64) LoanOption {
+	return func(l *Loan) {
+		if perPeriod < 0 {
+			l.optionErr = errors.New("subsidy must not be negative")
+			return
+		}
+		l.subsidy = perPeriod
+	}
+}
+
+// BorrowerInstallment returns the part of the next installment the borrower
+// pays out of pocket
+func (l *Loan) BorrowerInstallment() float64 {
+	return l.installmentForWeek(l.settledInstallments()) - l.SubsidyInstallment()
+}
+
+// SubsidyInstallment returns the part of the next installment paid by the
+// subsidy, which never exceeds the installment itself
+func (l *Loan) SubsidyInstallment() float64 {
+	return l.subsidyFor(1)
+}
+
+// subsidyFor returns the subsidy covering the next n installments, at least one
+func (l *Loan) subsidyFor(n int) float64 {
+	if l.subsidy <= 0 {
+		return 0
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	total := 0.0
+	settled := l.settledInstallments()
+	for week := settled; week < settled+n && week < l.totalWeeks; week++ {
+		total += math.Min(l.subsidy, l.installmentForWeek(week))
+	}
+	return total
+}
@@ -0,0 +1,30 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoan_Subsidy(t *testing.T) {
+	_, err := NewLoanValidated(WithSubsidy(-1))
+	assert.EqualError(t, err, "subsidy must not be negative")
+
+	loan := NewLoan(WithClock(newMockClock()), WithSubsidy(30000), WithLoanConfig(Config{
+		Principal:    1000000,
+		InterestRate: 0.10,
+		TotalWeeks:   10,
+	}))
+
+	assert.Equal(t, 110000.0, loan.GetWeeklyPayment(), "The loan should amortize on the full installment")
+	assert.Equal(t, 80000.0, loan.BorrowerInstallment())
+	assert.Equal(t, 30000.0, loan.SubsidyInstallment())
+
+	assert.EqualError(t, loan.MakePayment(50000), "payment amount must be at least 80000.00 for 1 missed payments")
+	assert.NoError(t, loan.MakePayment(80000))
+
+	assert.Equal(t, 990000.0, loan.GetOutstanding(), "Net payment plus subsidy should settle the installment")
+	assert.Equal(t, 0, loan.missedPayments())
+	assert.Equal(t, []Payment{{Amount: 110000, Date: newMockClock().Now(), Subsidy: 30000, Installments: 1}}, loan.GetPayments())
+	assert.Equal(t, 0.0, NewLoan().SubsidyInstallment())
+}
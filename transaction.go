@@ -0,0 +1,204 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Txn is a set of engine mutations applied by Engine.Transaction. Its methods
+// mirror the engine's but must only be called from within the transaction.
+type Txn struct {
+	engine  *Engine
+	saved   map[string]loanState
+	events  []Event
+	entries []WALEntry
+}
+
+// Transaction runs fn while holding the engine's write lock. If fn returns an
+// error, every loan touched through tx is restored to its state before the
+// transaction and the error is returned. Events are emitted, and the
+// transaction's mutations appended to the engine's WAL, only once the
+// transaction succeeds; if the WAL fails, the transaction is rolled back.
+// Likewise, balances served from the read cache only change once the
+// transaction commits, so readers never see a balance that is rolled back.
+func (e *Engine) Transaction(fn func(tx *Txn) error) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	tx := &Txn{engine: e, saved: make(map[string]loanState)}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	if err := e.appendWALBatch(tx.entries); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	tx.publish()
+	for _, event := range tx.events {
+		e.emit(event)
+	}
+	return nil
+}
+
+// rollback restores every loan touched through tx to its state before the
+// transaction. Their read caches were held back, so they still hold the
+// balances from before the transaction.
+func (tx *Txn) rollback() {
+	for id, state := range tx.saved {
+		loan := tx.engine.loans[id]
+		loan.loanState = state
+		loan.invalidateSchedule()
+	}
+}
+
+// publish reattaches the read cache of every loan touched through tx and
+// publishes the balance the transaction left it with
+func (tx *Txn) publish() {
+	for id, state := range tx.saved {
+		loan := tx.engine.loans[id]
+		loan.outstandingCache = state.outstandingCache
+		loan.publishOutstanding()
+	}
+}
+
+// log records a mutation of a loan to be appended to the WAL when the
+// transaction succeeds
+func (tx *Txn) log(loan *Loan, entry WALEntry) {
+	entry.LoanID = loan.id
+	entry.Time = loan.clock.Now()
+	tx.entries = append(tx.entries, entry)
+}
+
+// touch returns the loan with the given ID. The first time it is touched, its
+// state is saved so that it can be rolled back, and its read cache is detached
+// so that the transaction's changes are not published before it commits.
+func (tx *Txn) touch(id string) (*Loan, error) {
+	loan, exists := tx.engine.loans[id]
+	if !exists {
+		return nil, fmt.Errorf("loan not found: %s", id)
+	}
+
+	if _, saved := tx.saved[id]; !saved {
+		tx.saved[id] = loan.loanState.clone()
+		loan.outstandingCache = nil
+	}
+	return loan, nil
+}
+
+// GetLoan retrieves a loan by its ID. Changes made directly to the returned
+// loan are not rolled back; use the Txn methods instead.
+func (tx *Txn) GetLoan(id string) (*Loan, error) {
+	loan, exists := tx.engine.loans[id]
+	if !exists {
+		return nil, errors.New("loan not found")
+	}
+	return loan, nil
+}
+
+// MakePayment makes a payment for a specific loan
+func (tx *Txn) MakePayment(id string, amount float64) error {
+	loan, err := tx.touch(id)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.engine.prePayment(loan, amount); err != nil {
+		return err
+	}
+	if err := loan.MakePayment(amount); err != nil {
+		return err
+	}
+
+	tx.log(loan, WALEntry{Op: WALPayment, Amount: amount})
+	tx.events = append(tx.events, Event{Type: EventPaymentMade, LoanID: id, Amount: amount, Time: loan.clock.Now()})
+	return nil
+}
+
+// ReversePayment reverses the most recent payment of a specific loan
+func (tx *Txn) ReversePayment(id string) error {
+	loan, err := tx.touch(id)
+	if err != nil {
+		return err
+	}
+
+	if err := loan.ReverseLastPayment(); err != nil {
+		return err
+	}
+
+	tx.log(loan, WALEntry{Op: WALReversePayment})
+	tx.events = append(tx.events, Event{Type: EventPaymentReversed, LoanID: id, Time: loan.clock.Now()})
+	return nil
+}
+
+// Adjust applies a manual adjustment to the outstanding balance of a specific loan
+func (tx *Txn) Adjust(id string, amount float64, reason string) error {
+	loan, err := tx.touch(id)
+	if err != nil {
+		return err
+	}
+
+	if err := loan.Adjust(amount, reason); err != nil {
+		return err
+	}
+
+	tx.log(loan, WALEntry{Op: WALAdjust, Amount: amount, Reason: reason})
+	tx.events = append(tx.events, Event{Type: EventAdjusted, LoanID: id, Amount: amount, Reason: reason, Time: loan.clock.Now()})
+	return nil
+}
+
+// ChangeInterestRate changes the interest rate of a specific loan
+func (tx *Txn) ChangeInterestRate(id string, rate float64, week int) error {
+	loan, err := tx.touch(id)
+	if err != nil {
+		return err
+	}
+
+	if err := loan.ChangeInterestRate(rate, week); err != nil {
+		return err
+	}
+
+	tx.log(loan, WALEntry{Op: WALChangeInterestRate, Rate: rate, Week: week})
+	tx.events = append(tx.events, Event{Type: EventInterestRateChanged, LoanID: id, Rate: rate, Week: week, Time: loan.clock.Now()})
+	return nil
+}
+
+// clone returns a copy of the state that shares no slices or maps with it
+func (s loanState) clone() loanState {
+	c := s
+	c.payments = append([]Payment(nil), s.payments...)
+	c.installmentChanges = append([]installmentChange(nil), s.installmentChanges...)
+	c.deferrals = append([]deferral(nil), s.deferrals...)
+	c.impairments = append([]Impairment(nil), s.impairments...)
+	c.discounts = append([]Discount(nil), s.discounts...)
+	c.creditApplications = append([]Payment(nil), s.creditApplications...)
+	c.suspensions = append([]suspension(nil), s.suspensions...)
+	c.adjustments = append([]Adjustment(nil), s.adjustments...)
+	c.reliefPeriods = append([]period(nil), s.reliefPeriods...)
+	c.charges = append([]Charge(nil), s.charges...)
+	c.disbursements = append([]Disbursement(nil), s.disbursements...)
+	c.returnedPayments = append([]ReturnedPayment(nil), s.returnedPayments...)
+	c.statusHistory = append([]StatusChange(nil), s.statusHistory...)
+
+	if s.installmentOverrides != nil {
+		c.installmentOverrides = make(map[int]float64, len(s.installmentOverrides))
+		for week, amount := range s.installmentOverrides {
+			c.installmentOverrides[week] = amount
+		}
+	}
+	if s.metadata != nil {
+		c.metadata = make(map[string]string, len(s.metadata))
+		for key, value := range s.metadata {
+			c.metadata[key] = value
+		}
+	}
+	if s.guarantor != nil {
+		guarantor := *s.guarantor
+		guarantor.Calls = append([]GuaranteeCall(nil), s.guarantor.Calls...)
+		c.guarantor = &guarantor
+	}
+
+	return c
+}
@@ -0,0 +1,83 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Transaction(t *testing.T) {
+	var events []Event
+	engine := NewEngine(WithEventHandler(func(event Event) {
+		events = append(events, event)
+	}))
+	loanA, _ := engine.CreateLoan(WithLoanID("loanA"), WithClock(newMockClock()))
+	loanB, _ := engine.CreateLoan(WithLoanID("loanB"), WithClock(newMockClock()))
+	assert.NoError(t, engine.MakePayment("loanA", 110000))
+	events = nil
+
+	// Move loan A's payment to loan B
+	err := engine.Transaction(func(tx *Txn) error {
+		if err := tx.ReversePayment("loanA"); err != nil {
+			return err
+		}
+		return tx.MakePayment("loanB", 110000)
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, loanA.GetPayments())
+	assert.Equal(t, 5500000.0, loanA.GetOutstanding())
+	assert.Equal(t, 5390000.0, loanB.GetOutstanding())
+	assert.Len(t, events, 2, "Events should be emitted once the transaction succeeds")
+	assert.Equal(t, EventPaymentReversed, events[0].Type)
+	assert.Equal(t, EventPaymentMade, events[1].Type)
+}
+
+func TestEngine_Transaction_Rollback(t *testing.T) {
+	var events []Event
+	engine := NewEngine(WithEventHandler(func(event Event) {
+		events = append(events, event)
+	}))
+	loanA, _ := engine.CreateLoan(WithLoanID("loanA"), WithClock(newMockClock()))
+	_, _ = engine.CreateLoan(WithLoanID("loanB"), WithClock(newMockClock()))
+	assert.NoError(t, engine.MakePayment("loanA", 110000))
+	before := loanA.Snapshot()
+	events = nil
+
+	err := engine.Transaction(func(tx *Txn) error {
+		if err := tx.ReversePayment("loanA"); err != nil {
+			return err
+		}
+		if err := tx.Adjust("loanA", 1000, "fee waiver"); err != nil {
+			return err
+		}
+		return tx.MakePayment("loanB", 1)
+	})
+
+	assert.EqualError(t, err, "payment amount must be at least 110000.00 for 1 missed payments")
+	assert.Empty(t, DiffSnapshots(before, loanA.Snapshot()), "Loan A should be rolled back")
+	assert.Len(t, loanA.GetPayments(), 1)
+	assert.Empty(t, loanA.GetAdjustments())
+	assert.Empty(t, events, "No events should be emitted for a failed transaction")
+
+	err = engine.Transaction(func(tx *Txn) error {
+		return errors.New("aborted")
+	})
+	assert.EqualError(t, err, "aborted")
+
+	err = engine.Transaction(func(tx *Txn) error {
+		return tx.MakePayment("non-existent", 1)
+	})
+	assert.EqualError(t, err, "loan not found: non-existent")
+}
+
+func TestLoan_ReverseLastPayment(t *testing.T) {
+	loan := NewLoan(WithClock(newMockClock()))
+	assert.EqualError(t, loan.ReverseLastPayment(), "loan has no payments to reverse")
+
+	assert.NoError(t, loan.MakePayment(110000))
+	assert.NoError(t, loan.ReverseLastPayment())
+	assert.Empty(t, loan.GetPayments())
+	assert.Equal(t, 5500000.0, loan.GetOutstanding())
+}
@@ -0,0 +1,386 @@
+package billing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WAL is an append-only write-ahead log of the mutations made through an
+// engine. Implementations must be safe for concurrent use, since operations on
+// different loans append concurrently.
+type WAL interface {
+	// Append durably records an entry. The mutation it describes is only
+	// applied once Append has returned without error.
+	Append(entry WALEntry) error
+}
+
+// WALOp identifies the mutation recorded by a WAL entry. Ops are persisted by
+// value, so new ones must only ever be added at the end.
+type WALOp int
+
+// WAL ops
+const (
+	WALCreateLoan WALOp = iota
+	WALDeleteLoan
+	WALPayment
+	WALReversePayment
+	WALReturnPayment
+	WALAdjust
+	WALDiscount
+	WALSuspend
+	WALResume
+	WALChangeInterestRate
+	WALSetStartDate
+	WALRescheduleInstallment
+	WALReliefPeriod
+	WALTransaction
+)
+
+// WALEntry describes a single mutation of a loan. Only the fields its op needs
+// are set.
+type WALEntry struct {
+	Op     WALOp
+	LoanID string
+	Time   time.Time // when the mutation was applied, by the loan's clock
+	Amount float64   // set for payments, adjustments, discounts and returned payment fees
+	Rate   float64   // set for interest rate changes
+	Week   int       // set for interest rate changes and rescheduled installments
+	Index  int       // set for returned payments
+	Reason string    // set for adjustments, discounts, suspensions and returned payments
+	From   time.Time // set for relief periods, and to the new start date of a start date change
+	To     time.Time // set for relief periods
+
+	// Set for transactions and other mutations of several loans, whose entries
+	// are recorded together so that they are replayed all or not at all
+	Entries []WALEntry
+
+	// Set for loan creation, which is recorded by the loan's configuration and
+	// the options below, so that replay recreates the loan exactly
+	Config               *Config
+	Currency             string
+	BorrowerID           string
+	Purpose              Purpose
+	ProductType          string
+	Subsidy              float64
+	OverpaymentCredit    bool
+	AlignFirstPayment    bool
+	BillingDay           time.Weekday // set with AlignFirstPayment
+	Guarantor            *Guarantor
+	PaymentPolicy        string // "Lenient", or empty for the default strict policy
+	UndrawnPrincipal     float64
+	Immutable            bool
+	RepayableTotal       float64 // set with RepayableInstallment by WithRepayableAndInstallment
+	RepayableInstallment float64
+}
+
+// WithWAL makes the engine append an entry to wal before applying every
+// mutation made through its methods, including transactions, automatic
+// payments, distributed payments and loans created by ImportCSV. A mutation
+// that fails is not logged, and one whose entry cannot be appended is not
+// applied. A transaction, or a relief period declared for several loans, is
+// appended as a single WALTransaction entry. ReplayEvents is not logged, nor
+// are changes made directly to a loan returned by the engine. A loan with a
+// custom PaymentPolicy cannot be logged, so it cannot be created in an engine
+// with a WAL.
+func WithWAL(wal WAL) EngineOption {
+	return func(e *Engine) {
+		e.wal = wal
+	}
+}
+
+// appendWAL appends an entry to the engine's WAL, if it has one
+func (e *Engine) appendWAL(entry WALEntry) error {
+	if e.wal == nil {
+		return nil
+	}
+	if err := e.wal.Append(entry); err != nil {
+		return fmt.Errorf("write-ahead log: %v", err)
+	}
+	return nil
+}
+
+// appendWALBatch appends the entries of a mutation of several loans to the
+// engine's WAL, if it has one, as a single transaction entry so that a failure
+// cannot leave only some of them logged
+func (e *Engine) appendWALBatch(entries []WALEntry) error {
+	switch len(entries) {
+	case 0:
+		return nil
+	case 1:
+		return e.appendWAL(entries[0])
+	default:
+		return e.appendWAL(WALEntry{Op: WALTransaction, Time: entries[len(entries)-1].Time, Entries: entries})
+	}
+}
+
+// mutate applies op to the loan. With a WAL, op is applied to a copy of the
+// loan's state first, so that a failed op is not logged, and the result is only
+// installed once entry has been appended. The entry's loan ID and time are
+// filled in from the loan.
+func (e *Engine) mutate(loan *Loan, entry WALEntry, op func(*Loan) error) error {
+	if e.wal == nil {
+		return op(loan)
+	}
+
+	draft := loan.draft()
+	if err := op(draft); err != nil {
+		return err
+	}
+
+	entry.LoanID = loan.id
+	entry.Time = loan.clock.Now()
+	if err := e.appendWAL(entry); err != nil {
+		return err
+	}
+
+	loan.install(draft)
+	return nil
+}
+
+// draft returns a detached copy of the loan, e.g. to apply a mutation to before
+// it is logged
+func (l *Loan) draft() *Loan {
+	draft := &Loan{loanState: l.loanState.clone()}
+	draft.outstandingCache = nil
+	return draft
+}
+
+// install replaces the loan's state with that of a draft it was copied to, and
+// publishes the resulting balance
+func (l *Loan) install(draft *Loan) {
+	draft.outstandingCache = l.outstandingCache
+	l.loanState = draft.loanState
+	l.invalidateSchedule()
+	l.publishOutstanding()
+}
+
+// logCreation appends the entry recording the creation of a loan to the
+// engine's WAL, if it has one
+func (e *Engine) logCreation(loan *Loan) error {
+	if e.wal == nil {
+		return nil
+	}
+
+	entry, err := creationEntry(loan)
+	if err != nil {
+		return fmt.Errorf("write-ahead log: %v", err)
+	}
+	return e.appendWAL(entry)
+}
+
+// creationEntry returns the WAL entry recording the creation of a loan, which
+// must not have changed since it was created
+func creationEntry(loan *Loan) (WALEntry, error) {
+	config := loan.configuration()
+	entry := WALEntry{
+		Op:                   WALCreateLoan,
+		LoanID:               loan.id,
+		Time:                 loan.startDate,
+		Config:               &config,
+		Currency:             loan.currency,
+		BorrowerID:           loan.borrowerID,
+		Purpose:              loan.purpose,
+		ProductType:          loan.productType,
+		Subsidy:              loan.subsidy,
+		OverpaymentCredit:    loan.overpaymentCredit,
+		AlignFirstPayment:    loan.alignFirstPayment,
+		BillingDay:           loan.billingDay,
+		Guarantor:            loan.GetGuarantor(),
+		UndrawnPrincipal:     loan.undrawn,
+		Immutable:            loan.immutable,
+		RepayableTotal:       loan.repayableTotal,
+		RepayableInstallment: loan.repayableInstallment,
+	}
+
+	switch loan.paymentPolicy.(type) {
+	case StrictPaymentPolicy:
+	case LenientPaymentPolicy:
+		entry.PaymentPolicy = "Lenient"
+	default:
+		return WALEntry{}, fmt.Errorf("payment policy %T cannot be logged", loan.paymentPolicy)
+	}
+
+	return entry, nil
+}
+
+// creationOptions returns the options that recreate the loan recorded by a
+// creation entry, in the order the options require
+func creationOptions(entry WALEntry, clock Clock) ([]LoanOption, error) {
+	options := []LoanOption{WithLoanID(entry.LoanID), WithClock(clock), WithLoanConfig(*entry.Config),
+		WithCurrency(entry.Currency), WithBorrowerID(entry.BorrowerID), WithPurpose(entry.Purpose),
+		WithProductType(entry.ProductType)}
+
+	if entry.RepayableTotal > 0 {
+		options = append(options, WithRepayableAndInstallment(entry.RepayableTotal, entry.RepayableInstallment))
+	}
+	if entry.UndrawnPrincipal > 0 {
+		options = append(options, WithUndrawnPrincipal(entry.UndrawnPrincipal))
+	}
+	if entry.Subsidy > 0 {
+		options = append(options, WithSubsidy(entry.Subsidy))
+	}
+	if entry.OverpaymentCredit {
+		options = append(options, WithOverpaymentCredit())
+	}
+	if entry.AlignFirstPayment {
+		options = append(options, WithAlignedFirstPayment(entry.BillingDay))
+	}
+	if entry.Guarantor != nil {
+		options = append(options, WithGuarantor(entry.Guarantor.Name, entry.Guarantor.Amount))
+	}
+
+	switch entry.PaymentPolicy {
+	case "":
+	case "Lenient":
+		options = append(options, WithPaymentPolicy(LenientPaymentPolicy{}))
+	default:
+		return nil, fmt.Errorf("unknown payment policy %q", entry.PaymentPolicy)
+	}
+
+	if entry.Immutable {
+		options = append(options, WithImmutable())
+	}
+	return options, nil
+}
+
+// FileWAL is a WAL that appends entries to a file, one JSON object per line,
+// syncing the file after each entry
+type FileWAL struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// OpenFileWAL opens the WAL file at path for appending, creating it if needed
+func OpenFileWAL(path string) (*FileWAL, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileWAL{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Append writes an entry to the file and syncs it to stable storage
+func (w *FileWAL) Append(entry WALEntry) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.encoder.Encode(entry); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Close closes the WAL file
+func (w *FileWAL) Close() error {
+	return w.file.Close()
+}
+
+// ReplayWAL reconstructs an engine, created with the given options, from a log
+// written by a FileWAL. Each entry is applied at the time it was recorded, those
+// of a transaction one after another, and
+// is not appended to the new engine's WAL again, so the engine may be given the
+// WAL the log was read from to continue it. Replay stops at the first entry that
+// does not apply cleanly. Replayed loans keep the status they had at their last
+// entry unless the engine is created WithStatusRefreshOnLoad.
+func ReplayWAL(r io.Reader, options ...EngineOption) (*Engine, error) {
+	engine := NewEngine(options...)
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	clock := &replayClock{}
+	defer func() {
+		for _, loan := range engine.loans {
+			loan.clock = systemClock{}
+			if engine.refreshOnLoad {
+				loan.RefreshStatus()
+			}
+		}
+	}()
+
+	decoder := json.NewDecoder(r)
+	for i := 0; ; i++ {
+		var entry WALEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return engine, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+
+		entries := []WALEntry{entry}
+		if entry.Op == WALTransaction {
+			entries = entry.Entries
+		}
+		for _, entry := range entries {
+			clock.now = entry.Time
+			if err := engine.applyWALEntry(entry, clock); err != nil {
+				return nil, fmt.Errorf("entry %d: loan %s: %v", i, entry.LoanID, err)
+			}
+		}
+	}
+}
+
+// applyWALEntry applies a logged mutation to the engine. The caller must hold
+// the write lock.
+func (e *Engine) applyWALEntry(entry WALEntry, clock Clock) error {
+	if entry.Op == WALCreateLoan {
+		if entry.Config == nil {
+			return errors.New("creation has no config")
+		}
+		if _, exists := e.loans[entry.LoanID]; exists {
+			return errors.New("loan already exists")
+		}
+
+		options, err := creationOptions(entry, clock)
+		if err != nil {
+			return err
+		}
+		loan, err := NewLoanValidated(options...)
+		if err != nil {
+			return err
+		}
+		e.store(loan)
+		return nil
+	}
+
+	loan, exists := e.loans[entry.LoanID]
+	if !exists {
+		return errors.New("loan was not created by an earlier entry")
+	}
+
+	switch entry.Op {
+	case WALDeleteLoan:
+		e.remove(entry.LoanID)
+		return nil
+	case WALPayment:
+		return loan.MakePayment(entry.Amount)
+	case WALReversePayment:
+		return loan.ReverseLastPayment()
+	case WALReturnPayment:
+		return loan.ReturnPayment(entry.Index, entry.Amount, entry.Reason)
+	case WALAdjust:
+		return loan.Adjust(entry.Amount, entry.Reason)
+	case WALDiscount:
+		return loan.ApplyDiscount(entry.Amount, entry.Reason)
+	case WALSuspend:
+		return loan.Suspend(entry.Reason)
+	case WALResume:
+		return loan.Resume()
+	case WALChangeInterestRate:
+		return loan.ChangeInterestRate(entry.Rate, entry.Week)
+	case WALSetStartDate:
+		return loan.SetStartDate(entry.From)
+	case WALRescheduleInstallment:
+		return loan.RescheduleInstallment(entry.Week)
+	case WALReliefPeriod:
+		return loan.AddReliefPeriod(entry.From, entry.To)
+	default:
+		return fmt.Errorf("unknown op %d", entry.Op)
+	}
+}
@@ -0,0 +1,239 @@
+package billing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryWAL is a WAL that keeps its entries in memory and can be made to fail
+type memoryWAL struct {
+	entries []WALEntry
+	err     error
+}
+
+func (w *memoryWAL) Append(entry WALEntry) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.entries = append(w.entries, entry)
+	return nil
+}
+
+func TestEngine_WAL_Replay(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	path := filepath.Join(t.TempDir(), "billing.wal")
+	wal, err := OpenFileWAL(path)
+	assert.NoError(t, err)
+
+	clock := newMockClock()
+	engine := NewEngine(WithWAL(wal))
+	_, _ = engine.CreateLoan(WithLoanID("loan1"), WithClock(clock), WithBorrowerID("borrower1"), WithPurpose(Business),
+		WithLoanConfig(Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}))
+	_, _ = engine.CreateLoan(WithLoanID("loan2"), WithClock(clock), WithCurrency("USD"))
+	_, _ = engine.CreateLoan(WithLoanID("loan3"), WithClock(clock))
+
+	assert.NoError(t, engine.MakePayment("loan1", 22000))
+	assert.NoError(t, engine.MakePayment("loan2", 110000))
+	assert.Error(t, engine.MakePayment("loan1", 1), "A failed payment should not be logged")
+	clock.Advance(week)
+	assert.NoError(t, engine.MakePayment("loan1", 22000))
+	assert.NoError(t, engine.Transaction(func(tx *Txn) error {
+		return tx.MakePayment("loan2", 110000)
+	}))
+	assert.NoError(t, engine.Adjust("loan1", 5000, "goodwill"))
+	assert.NoError(t, engine.ApplyDiscount("loan2", 1000, "promo"))
+	assert.NoError(t, engine.ChangeInterestRate("loan1", 0.12, 10))
+	clock.Advance(3 * week)
+	assert.NoError(t, engine.ReturnPayment("loan1", 1, 15000, "returned check"))
+	assert.NoError(t, engine.Suspend("loan2", "dispute"))
+	clock.Advance(week)
+	assert.NoError(t, engine.Resume("loan2"))
+	assert.NoError(t, engine.Transaction(func(tx *Txn) error {
+		if err := tx.Adjust("loan1", 1000, "fee waiver"); err != nil {
+			return err
+		}
+		return tx.Adjust("loan2", 1000, "fee waiver")
+	}))
+	assert.NoError(t, engine.DeclareReliefPeriod(clock.Now(), clock.Now().Add(week), []string{"loan1", "loan2"}))
+	assert.NoError(t, engine.DeleteLoan("loan3"))
+	assert.Error(t, engine.Transaction(func(tx *Txn) error {
+		_ = tx.MakePayment("loan1", 22000)
+		return errors.New("abort")
+	}), "A rolled back transaction should not be logged")
+	assert.NoError(t, wal.Close())
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	replayed, err := ReplayWAL(file)
+	assert.NoError(t, err)
+
+	_, err = replayed.GetLoan("loan3")
+	assert.Error(t, err, "The deleted loan should stay deleted")
+
+	for _, id := range []string{"loan1", "loan2"} {
+		original, _ := engine.GetLoan(id)
+		loan, err := replayed.GetLoan(id)
+		assert.NoError(t, err)
+
+		assert.Empty(t, DiffSnapshots(original.Snapshot(), loan.Snapshot()), "Loan %s should be reconstructed identically", id)
+		assert.Equal(t, original.GetCurrency(), loan.GetCurrency())
+		assert.Equal(t, original.GetBorrowerID(), loan.GetBorrowerID())
+		assert.Equal(t, original.GetAdjustments(), loan.GetAdjustments())
+		assert.Equal(t, original.GetDiscounts(), loan.GetDiscounts())
+		assert.Equal(t, original.GetReturnedPayments(), loan.GetReturnedPayments())
+		assert.Equal(t, original.GetBillingSchedule(), loan.GetBillingSchedule())
+	}
+}
+
+// customPolicy is a payment policy the WAL cannot record
+type customPolicy struct{}
+
+func (customPolicy) Apply(l *Loan, amount float64) error {
+	return l.makeScheduledPayment(amount)
+}
+
+func TestEngine_WAL_CreationOptions(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+
+	wal := &memoryWAL{}
+	clock := newMockClock()
+	config := Config{Principal: 1000000, InterestRate: 0.10, TotalWeeks: 50}
+	engine := NewEngine(WithWAL(wal))
+
+	_, _ = engine.CreateLoan(WithLoanID("subsidy"), WithClock(clock), WithLoanConfig(config), WithSubsidy(2000))
+	_, _ = engine.CreateLoan(WithLoanID("credit"), WithClock(clock), WithLoanConfig(config), WithOverpaymentCredit())
+	_, _ = engine.CreateLoan(WithLoanID("options"), WithClock(clock), WithLoanConfig(config),
+		WithAlignedFirstPayment(time.Friday), WithGuarantor("guarantor", 100000), WithUndrawnPrincipal(400000),
+		WithPaymentPolicy(LenientPaymentPolicy{}))
+	_, _ = engine.CreateLoan(WithLoanID("repayable"), WithClock(clock), WithLoanConfig(config),
+		WithRepayableAndInstallment(1050000, 25000))
+	_, _ = engine.CreateLoan(WithLoanID("immutable"), WithClock(clock), WithLoanConfig(config), WithImmutable())
+	imported, errs := engine.ImportCSV(bytes.NewBufferString("id,principal,rate,weeks,start_date\nimported,1000000,0.10,50,2024-01-01\n"))
+	assert.Equal(t, 1, imported)
+	assert.Empty(t, errs)
+
+	_, err := engine.CreateLoan(WithLoanID("custom"), WithPaymentPolicy(customPolicy{}))
+	assert.EqualError(t, err, "write-ahead log: payment policy billing.customPolicy cannot be logged")
+
+	assert.NoError(t, engine.MakePayment("subsidy", 20000))
+	assert.NoError(t, engine.MakePayment("credit", 44000))
+	assert.NoError(t, engine.MakePayment("repayable", 25000))
+	clock.Advance(2 * week)
+	assert.NoError(t, engine.MakePayment("subsidy", 40000))
+	assert.NoError(t, engine.MakePayment("credit", 22000))
+	assert.NoError(t, engine.MakePayment("options", 10000), "The lenient policy should accept a partial payment")
+	assert.NoError(t, engine.Adjust("imported", 5000, "goodwill"))
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range wal.entries {
+		assert.NoError(t, encoder.Encode(entry))
+	}
+	replayed, err := ReplayWAL(&buf)
+	assert.NoError(t, err)
+
+	for _, id := range []string{"subsidy", "credit", "options", "repayable", "immutable", "imported"} {
+		original, _ := engine.GetLoan(id)
+		loan, err := replayed.GetLoan(id)
+		assert.NoError(t, err)
+
+		assert.Empty(t, DiffSnapshots(original.Snapshot(), loan.Snapshot()), "Loan %s should be reconstructed identically", id)
+		assert.Equal(t, original.GetPayments(), loan.GetPayments(), "Loan %s", id)
+		assert.Equal(t, original.GetBillingSchedule(), loan.GetBillingSchedule(), "Loan %s", id)
+		assert.Equal(t, original.GetCreditBalance(), loan.GetCreditBalance(), "Loan %s", id)
+		assert.Equal(t, original.SubsidyInstallment(), loan.SubsidyInstallment(), "Loan %s", id)
+		assert.Equal(t, original.GetGuarantor(), loan.GetGuarantor(), "Loan %s", id)
+		assert.Equal(t, original.DisbursedPrincipal(), loan.DisbursedPrincipal(), "Loan %s", id)
+		assert.Equal(t, original.IsImmutable(), loan.IsImmutable(), "Loan %s", id)
+		assert.Equal(t, original.GetStartDate(), loan.GetStartDate(), "Loan %s", id)
+	}
+
+	loan, _ := replayed.GetLoan("credit")
+	assert.Equal(t, 22000.0, loan.GetCreditBalance()+loan.creditApplications[0].Amount, "The overpayment should be held as credit on replay")
+	loan, _ = replayed.GetLoan("options")
+	assert.Equal(t, LenientPaymentPolicy{}, loan.paymentPolicy)
+}
+
+func TestEngine_WAL_AppendFailure(t *testing.T) {
+	wal := &memoryWAL{}
+	engine := NewEngine(WithWAL(wal))
+	loan, _ := engine.CreateLoan(WithLoanID("loan1"))
+
+	wal.err = errors.New("disk full")
+	err := engine.MakePayment("loan1", loan.GetWeeklyPayment())
+	assert.EqualError(t, err, "write-ahead log: disk full")
+	assert.Empty(t, loan.GetPayments(), "A payment that was not logged should not be applied")
+	assert.Equal(t, 5500000.0, loan.GetOutstanding())
+
+	_, err = engine.CreateLoan(WithLoanID("loan2"))
+	assert.EqualError(t, err, "write-ahead log: disk full")
+	_, err = engine.GetLoan("loan2")
+	assert.Error(t, err, "A loan whose creation was not logged should not be stored")
+
+	err = engine.Transaction(func(tx *Txn) error {
+		return tx.MakePayment("loan1", loan.GetWeeklyPayment())
+	})
+	assert.EqualError(t, err, "write-ahead log: disk full")
+	assert.Empty(t, loan.GetPayments(), "A transaction that was not logged should be rolled back")
+
+	assert.Len(t, wal.entries, 1)
+	assert.Equal(t, WALCreateLoan, wal.entries[0].Op)
+}
+
+func TestEngine_WAL_MultiLoanMutations(t *testing.T) {
+	wal := &memoryWAL{}
+	engine := NewEngine(WithWAL(wal))
+	loan1, _ := engine.CreateLoan(WithLoanID("loan1"))
+	loan2, _ := engine.CreateLoan(WithLoanID("loan2"))
+	_, _ = engine.CreateLoan(WithLoanID("frozen"), WithImmutable())
+	wal.entries = nil
+
+	assert.NoError(t, engine.Transaction(func(tx *Txn) error {
+		if err := tx.MakePayment("loan1", loan1.GetWeeklyPayment()); err != nil {
+			return err
+		}
+		return tx.MakePayment("loan2", loan2.GetWeeklyPayment())
+	}))
+	assert.Len(t, wal.entries, 1, "A transaction should be logged as a single entry")
+	assert.Equal(t, WALTransaction, wal.entries[0].Op)
+	assert.Len(t, wal.entries[0].Entries, 2)
+
+	from := loan1.GetStartDate()
+	err := engine.DeclareReliefPeriod(from, from.AddDate(0, 0, 14), []string{"loan1", "frozen"})
+	assert.ErrorIs(t, err, ErrLoanImmutable)
+	assert.Empty(t, loan1.reliefPeriods, "No loan should be changed when the period cannot be added to one of them")
+	assert.Len(t, wal.entries, 1)
+
+	assert.NoError(t, engine.DeclareReliefPeriod(from, from.AddDate(0, 0, 14), []string{"loan1", "loan2"}))
+	assert.Len(t, loan1.reliefPeriods, 1)
+	assert.Len(t, loan2.reliefPeriods, 1)
+	assert.Len(t, wal.entries, 2)
+	assert.Equal(t, WALTransaction, wal.entries[1].Op)
+
+	wal.err = errors.New("disk full")
+	assert.Error(t, engine.DeclareReliefPeriod(from.AddDate(0, 0, 21), from.AddDate(0, 0, 28), []string{"loan1", "loan2"}))
+	assert.Len(t, loan1.reliefPeriods, 1, "A relief period that was not logged should not be applied")
+	assert.Len(t, loan2.reliefPeriods, 1)
+}
+
+func TestReplayWAL_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	_ = encoder.Encode(WALEntry{Op: WALPayment, LoanID: "loan1", Amount: 22000})
+
+	_, err := ReplayWAL(&buf)
+	assert.EqualError(t, err, "entry 0: loan loan1: loan was not created by an earlier entry")
+
+	_, err = ReplayWAL(bytes.NewBufferString("not json"))
+	assert.Error(t, err)
+}